@@ -0,0 +1,169 @@
+// Command configref generates the configuration reference (every key, its
+// MCP_ environment variable, type, default and deprecated aliases) and
+// .env.example from internal/config, so neither drifts from what Load reads.
+//
+//	go run ./cmd/configref              # regenerate
+//	go run ./cmd/configref -check       # fail if a generated file is stale
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"modelo-mcp/internal/config"
+)
+
+const header = "Code generated by configref. DO NOT EDIT."
+
+func main() {
+	doc := flag.String("doc", "docs/configuration.md", "configuration reference to write")
+	env := flag.String("env", ".env.example", "example environment file to write")
+	check := flag.Bool("check", false, "do not write files; exit 1 if a generated file is stale")
+	flag.Parse()
+
+	keys := config.Keys()
+	outputs := []struct {
+		path string
+		data []byte
+	}{
+		{*doc, renderDoc(keys)},
+		{*env, renderEnv(keys)},
+	}
+
+	stale := false
+	for _, o := range outputs {
+		current, err := os.ReadFile(o.path)
+		if err != nil && !os.IsNotExist(err) {
+			fatalf("read %s: %v", o.path, err)
+		}
+		if bytes.Equal(current, o.data) {
+			continue
+		}
+		if *check {
+			fmt.Fprintf(os.Stderr, "configref: %s is stale, run make config-docs\n", o.path)
+			stale = true
+			continue
+		}
+		if err := os.WriteFile(o.path, o.data, 0o644); err != nil {
+			fatalf("write %s: %v", o.path, err)
+		}
+		fmt.Printf("configref: wrote %s\n", o.path)
+	}
+	if stale {
+		os.Exit(1)
+	}
+}
+
+func renderDoc(keys []config.Key) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<!-- %s -->\n\n", header)
+	b.WriteString("# Configuração\n\n")
+	b.WriteString("Cada chave de `configs/config.yaml` pode ser definida por uma variável de ambiente\n")
+	b.WriteString("`" + config.EnvPrefix + "_<SEÇÃO>_<CAMPO>`: o caminho da chave em maiúsculas, com `.` trocado por `_`.\n\n")
+	b.WriteString("Precedência (maior primeiro):\n\n")
+	b.WriteString("1. variável `" + config.EnvPrefix + "_*`\n")
+	b.WriteString("2. alias legado (obsoleto, registra um aviso no log)\n")
+	b.WriteString("3. bloco do perfil (`development:`, `production:`) nos arquivos abaixo\n")
+	b.WriteString("4. `configs/config.<perfil>.yaml`\n")
+	b.WriteString("5. `configs/config.yaml`\n")
+	b.WriteString("6. default\n\n")
+	b.WriteString("O perfil é o `environment`, com `dev`/`local` lidos como `development` e `prod`\n")
+	b.WriteString("como `production`. `modelo-mcp config show` e `GET /admin/config` (JWT com role\n")
+	b.WriteString("admin) mostram a configuração efetiva e a origem de cada chave.\n\n")
+	b.WriteString("Qualquer valor pode ser uma referência `secret://<provider>/<path>[#<key>]`\n")
+	b.WriteString("(`file`, `env` ou `vault`), resolvida ao carregar e mantida em cache por\n")
+	b.WriteString("`secrets.cache_ttl`; veja `configs/config.yaml`.\n\n")
+	b.WriteString("Listas de strings aceitam valores separados por vírgula. Listas de objetos\n")
+	b.WriteString("(`list`) só podem ser definidas no YAML.\n\n")
+	b.WriteString("Chaves marcadas em \"Recarga\" são aplicadas sem restart quando um arquivo de\n")
+	b.WriteString("configuração muda ou o processo recebe SIGHUP; mudanças nas demais são ignoradas\n")
+	b.WriteString("com um aviso no log até o próximo restart.\n\n")
+	b.WriteString("| Chave | Variável | Tipo | Default | Aliases obsoletos | Recarga |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, k := range keys {
+		env := "—"
+		if k.Env != "" {
+			env = "`" + k.Env + "`"
+		}
+		aliases := "—"
+		if len(k.Aliases) > 0 {
+			aliases = "`" + strings.Join(k.Aliases, "`, `") + "`"
+		}
+		def := "—"
+		if s := formatDefault(k.Default); s != "" {
+			def = "`" + s + "`"
+		}
+		reload := ""
+		if k.Reloadable {
+			reload = "✓"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s | %s |\n", k.Path, env, k.Type, def, aliases, reload)
+	}
+	return b.Bytes()
+}
+
+// renderEnv lists the top-level keys first, then one block per section.
+// Keys without a default are commented out so YAML values still apply.
+func renderEnv(keys []config.Key) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n", header)
+	b.WriteString("# Variáveis lidas por internal/config; veja docs/configuration.md.\n")
+
+	section := "\x00"
+	for _, group := range [][]config.Key{topLevel(keys, true), topLevel(keys, false)} {
+		for _, k := range group {
+			if k.Env == "" {
+				continue
+			}
+			if s := sectionOf(k.Path); s != section {
+				section = s
+				if s == "" {
+					b.WriteString("\n# Geral\n")
+				} else {
+					fmt.Fprintf(&b, "\n# %s\n", s)
+				}
+			}
+			if def := formatDefault(k.Default); def != "" {
+				fmt.Fprintf(&b, "%s=%s\n", k.Env, def)
+			} else {
+				fmt.Fprintf(&b, "# %s=\n", k.Env)
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+func topLevel(keys []config.Key, top bool) []config.Key {
+	var out []config.Key
+	for _, k := range keys {
+		if !strings.Contains(k.Path, ".") == top {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+func sectionOf(path string) string {
+	if i := strings.Index(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+func formatDefault(v any) string {
+	switch d := v.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(d, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "configref: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Compatibility of a schema change, seen from the consumers of a message.
+// Backward: readers using the new schema accept data written with the old
+// one. Forward: readers still on the old schema accept data written with the
+// new one.
+type Compatibility int
+
+const (
+	Full Compatibility = iota
+	Backward
+	Forward
+	Breaking
+)
+
+func (c Compatibility) String() string {
+	switch c {
+	case Full:
+		return "FULL"
+	case Backward:
+		return "BACKWARD"
+	case Forward:
+		return "FORWARD"
+	}
+	return "BREAKING"
+}
+
+func (c Compatibility) backward() bool { return c == Full || c == Backward }
+func (c Compatibility) forward() bool  { return c == Full || c == Forward }
+
+// combine returns the compatibility of applying both changes.
+func combine(a, b Compatibility) Compatibility {
+	bw := a.backward() && b.backward()
+	fw := a.forward() && b.forward()
+	switch {
+	case bw && fw:
+		return Full
+	case bw:
+		return Backward
+	case fw:
+		return Forward
+	}
+	return Breaking
+}
+
+// Change is one difference between two schema versions.
+type Change struct {
+	Path   string
+	Kind   Compatibility
+	Detail string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%-8s %s: %s", c.Kind, c.Path, c.Detail)
+}
+
+// node is the subset of JSON Schema compared by the checker.
+type node struct {
+	Type                 json.RawMessage  `json:"type"`
+	Format               string           `json:"format"`
+	Properties           map[string]*node `json:"properties"`
+	Required             []string         `json:"required"`
+	AdditionalProperties *json.RawMessage `json:"additionalProperties"`
+	Items                *node            `json:"items"`
+	Enum                 []any            `json:"enum"`
+	MinLength            *float64         `json:"minLength"`
+	MaxLength            *float64         `json:"maxLength"`
+	Minimum              *float64         `json:"minimum"`
+	Maximum              *float64         `json:"maximum"`
+	MinItems             *float64         `json:"minItems"`
+	MaxItems             *float64         `json:"maxItems"`
+}
+
+func parseNode(raw []byte) (*node, error) {
+	var n node
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (n *node) types() []string {
+	if n == nil || len(n.Type) == 0 {
+		return nil
+	}
+	var one string
+	if json.Unmarshal(n.Type, &one) == nil {
+		return []string{one}
+	}
+	var many []string
+	_ = json.Unmarshal(n.Type, &many)
+	sort.Strings(many)
+	return many
+}
+
+// additionalAllowed reports whether properties not listed are accepted. Only
+// the boolean form is understood; a schema counts as permissive.
+func (n *node) additionalAllowed() bool {
+	if n.AdditionalProperties == nil {
+		return true
+	}
+	var b bool
+	if json.Unmarshal(*n.AdditionalProperties, &b) == nil {
+		return b
+	}
+	return true
+}
+
+func (n *node) required(name string) bool {
+	for _, r := range n.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff compares two schema versions and lists every change with its
+// compatibility.
+func Diff(oldN, newN *node) []Change {
+	var out []Change
+	diffNode("", oldN, newN, &out)
+	return out
+}
+
+func diffNode(path string, o, n *node, out *[]Change) {
+	add := func(kind Compatibility, format string, args ...any) {
+		p := path
+		if p == "" {
+			p = "/"
+		}
+		*out = append(*out, Change{Path: p, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	ot, nt := o.types(), n.types()
+	if strings.Join(ot, ",") != strings.Join(nt, ",") {
+		switch {
+		case subset(ot, nt):
+			add(Backward, "type widened from %v to %v", ot, nt)
+		case subset(nt, ot):
+			add(Forward, "type narrowed from %v to %v", ot, nt)
+		default:
+			add(Breaking, "type changed from %v to %v", ot, nt)
+		}
+		return
+	}
+	if o.Format != n.Format {
+		switch {
+		case n.Format == "":
+			add(Backward, "format %q removed", o.Format)
+		case o.Format == "":
+			add(Forward, "format %q added", n.Format)
+		default:
+			add(Breaking, "format changed from %q to %q", o.Format, n.Format)
+		}
+	}
+
+	diffEnum(o, n, add)
+	diffLimit("minLength", o.MinLength, n.MinLength, true, add)
+	diffLimit("maxLength", o.MaxLength, n.MaxLength, false, add)
+	diffLimit("minimum", o.Minimum, n.Minimum, true, add)
+	diffLimit("maximum", o.Maximum, n.Maximum, false, add)
+	diffLimit("minItems", o.MinItems, n.MinItems, true, add)
+	diffLimit("maxItems", o.MaxItems, n.MaxItems, false, add)
+
+	if o.Items != nil && n.Items != nil {
+		diffNode(path+"/items", o.Items, n.Items, out)
+	}
+
+	// Tightening additionalProperties makes new readers reject fields that
+	// producers may already send, and removing a required property breaks
+	// readers that still expect it: both are breaking in every mode.
+	oa, na := o.additionalAllowed(), n.additionalAllowed()
+	if oa && !na {
+		add(Breaking, "additionalProperties tightened to false")
+	} else if !oa && na {
+		add(Backward, "additionalProperties loosened to true")
+	}
+
+	names := map[string]bool{}
+	for k := range o.Properties {
+		names[k] = true
+	}
+	for k := range n.Properties {
+		names[k] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for k := range names {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		op, np := o.Properties[name], n.Properties[name]
+		ppath := path + "/" + name
+		switch {
+		case op == nil:
+			// New data carries the field; old readers must tolerate it, new
+			// readers must not demand it from old data.
+			kind := Full
+			if !oa {
+				kind = combine(kind, Backward)
+			}
+			if n.required(name) {
+				kind = combine(kind, Forward)
+				*out = append(*out, Change{Path: ppath, Kind: kind, Detail: "required property added"})
+			} else {
+				*out = append(*out, Change{Path: ppath, Kind: kind, Detail: "optional property added"})
+			}
+		case np == nil:
+			kind := Full
+			if !na {
+				kind = combine(kind, Forward)
+			}
+			if o.required(name) {
+				*out = append(*out, Change{Path: ppath, Kind: Breaking, Detail: "required property removed"})
+			} else {
+				*out = append(*out, Change{Path: ppath, Kind: kind, Detail: "optional property removed"})
+			}
+		default:
+			or, nr := o.required(name), n.required(name)
+			if !or && nr {
+				*out = append(*out, Change{Path: ppath, Kind: Forward, Detail: "property became required"})
+			} else if or && !nr {
+				*out = append(*out, Change{Path: ppath, Kind: Backward, Detail: "property became optional"})
+			}
+			diffNode(ppath, op, np, out)
+		}
+	}
+}
+
+func diffEnum(o, n *node, add func(Compatibility, string, ...any)) {
+	if len(o.Enum) == 0 && len(n.Enum) == 0 {
+		return
+	}
+	if len(n.Enum) == 0 {
+		add(Backward, "enum restriction removed")
+		return
+	}
+	if len(o.Enum) == 0 {
+		add(Forward, "enum restriction added")
+		return
+	}
+	removed := difference(o.Enum, n.Enum)
+	added := difference(n.Enum, o.Enum)
+	if len(removed) > 0 {
+		add(Forward, "enum values removed: %v", removed)
+	}
+	if len(added) > 0 {
+		add(Backward, "enum values added: %v", added)
+	}
+}
+
+// diffLimit classifies a change to a numeric bound. lower is true for
+// minimum-style keywords, where raising the bound tightens the schema.
+func diffLimit(keyword string, o, n *float64, lower bool, add func(Compatibility, string, ...any)) {
+	switch {
+	case o == nil && n == nil:
+		return
+	case o == nil:
+		add(Forward, "%s %v added", keyword, *n)
+	case n == nil:
+		add(Backward, "%s %v removed", keyword, *o)
+	case *o == *n:
+		return
+	case (*n > *o) == lower:
+		add(Forward, "%s tightened from %v to %v", keyword, *o, *n)
+	default:
+		add(Backward, "%s loosened from %v to %v", keyword, *o, *n)
+	}
+}
+
+// subset reports whether every type in a is in b. "integer" is covered by
+// "number".
+func subset(a, b []string) bool {
+	for _, t := range a {
+		found := false
+		for _, u := range b {
+			if t == u || (t == "integer" && u == "number") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func difference(a, b []any) []any {
+	var out []any
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if fmt.Sprint(x) == fmt.Sprint(y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// Overall folds a list of changes into a single compatibility level.
+func Overall(changes []Change) Compatibility {
+	c := Full
+	for _, ch := range changes {
+		c = combine(c, ch.Kind)
+	}
+	return c
+}
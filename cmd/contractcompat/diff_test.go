@@ -0,0 +1,112 @@
+package main
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     Compatibility
+	}{
+		{
+			name: "identical",
+			old:  `{"type":"object","properties":{"a":{"type":"string"}},"required":["a"]}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string"}},"required":["a"]}`,
+			want: Full,
+		},
+		{
+			name: "optional property added",
+			old:  `{"type":"object","properties":{"a":{"type":"string"}}}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"string"}}}`,
+			want: Full,
+		},
+		{
+			name: "optional property added to closed object",
+			old:  `{"type":"object","properties":{"a":{"type":"string"}},"additionalProperties":false}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"string"}},"additionalProperties":false}`,
+			want: Backward,
+		},
+		{
+			name: "required property added",
+			old:  `{"type":"object","properties":{"a":{"type":"string"}}}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"string"}},"required":["b"]}`,
+			want: Forward,
+		},
+		{
+			name: "required property removed",
+			old:  `{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"string"}},"required":["a","b"]}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string"}},"required":["a"]}`,
+			want: Breaking,
+		},
+		{
+			name: "optional property removed",
+			old:  `{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"string"}}}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string"}}}`,
+			want: Full,
+		},
+		{
+			name: "additionalProperties tightened",
+			old:  `{"type":"object","properties":{"a":{"type":"string"}}}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string"}},"additionalProperties":false}`,
+			want: Breaking,
+		},
+		{
+			name: "additionalProperties loosened",
+			old:  `{"type":"object","properties":{"a":{"type":"string"}},"additionalProperties":false}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string"}},"additionalProperties":true}`,
+			want: Backward,
+		},
+		{
+			name: "type changed",
+			old:  `{"type":"object","properties":{"a":{"type":"string"}}}`,
+			new:  `{"type":"object","properties":{"a":{"type":"boolean"}}}`,
+			want: Breaking,
+		},
+		{
+			name: "type widened",
+			old:  `{"type":"object","properties":{"a":{"type":"integer"}}}`,
+			new:  `{"type":"object","properties":{"a":{"type":"number"}}}`,
+			want: Backward,
+		},
+		{
+			name: "property became required",
+			old:  `{"type":"object","properties":{"a":{"type":"string"}}}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string"}},"required":["a"]}`,
+			want: Forward,
+		},
+		{
+			name: "enum value added",
+			old:  `{"type":"object","properties":{"a":{"type":"string","enum":["x"]}}}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string","enum":["x","y"]}}}`,
+			want: Backward,
+		},
+		{
+			name: "maxLength tightened",
+			old:  `{"type":"object","properties":{"a":{"type":"string","maxLength":10}}}`,
+			new:  `{"type":"object","properties":{"a":{"type":"string","maxLength":5}}}`,
+			want: Forward,
+		},
+		{
+			name: "nested required property removed",
+			old:  `{"type":"object","properties":{"o":{"type":"object","properties":{"a":{"type":"string"}},"required":["a"]}}}`,
+			new:  `{"type":"object","properties":{"o":{"type":"object","properties":{}}}}`,
+			want: Breaking,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := parseNode([]byte(tt.old))
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := parseNode([]byte(tt.new))
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes := Diff(o, n)
+			if got := Overall(changes); got != tt.want {
+				t.Errorf("Overall = %v, want %v; changes: %v", got, tt.want, changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Command contractcompat checks that changes to the schemas in pkg/contracts
+// do not break the consumers of other MCPs. It compares two schema files, or
+// two contract trees matched by relative path:
+//
+//	go run ./cmd/contractcompat old.schema.json new.schema.json
+//	go run ./cmd/contractcompat -old build/contracts-base -new pkg/contracts
+//
+// The previous tree is usually extracted from git, see `make contracts-compat`.
+// Changes that do not meet -mode (backward by default) fail the check; they
+// belong in a new version directory (e.g. example/v2) instead.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const schemaSuffix = ".schema.json"
+
+func main() {
+	oldDir := flag.String("old", "", "previous contracts directory")
+	newDir := flag.String("new", "pkg/contracts", "current contracts directory")
+	mode := flag.String("mode", "backward", "required compatibility: backward, forward or full")
+	flag.Parse()
+
+	var want func(Compatibility) bool
+	switch *mode {
+	case "backward":
+		want = Compatibility.backward
+	case "forward":
+		want = Compatibility.forward
+	case "full":
+		want = func(c Compatibility) bool { return c == Full }
+	default:
+		fatalf("unknown -mode %q", *mode)
+	}
+
+	var pairs [][2]string
+	switch {
+	case flag.NArg() == 2:
+		pairs = append(pairs, [2]string{flag.Arg(0), flag.Arg(1)})
+	case *oldDir != "":
+		var err error
+		pairs, err = pairTrees(*oldDir, *newDir)
+		if err != nil {
+			fatalf("%v", err)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "usage: contractcompat [-mode m] OLD NEW | -old DIR [-new DIR]")
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, p := range pairs {
+		name := p[1]
+		if name == "" {
+			name = p[0]
+		}
+		switch {
+		case p[0] == "":
+			fmt.Printf("%s: new contract\n", name)
+			continue
+		case p[1] == "":
+			fmt.Printf("%s: BREAKING contract removed\n", name)
+			failed = failed || !want(Breaking)
+			continue
+		}
+		changes, err := diffFiles(p[0], p[1])
+		if err != nil {
+			fatalf("%s: %v", name, err)
+		}
+		overall := Overall(changes)
+		fmt.Printf("%s: %s\n", name, overall)
+		for _, c := range changes {
+			fmt.Printf("  %s\n", c)
+		}
+		if !want(overall) {
+			failed = true
+		}
+	}
+	if failed {
+		fmt.Fprintf(os.Stderr, "contractcompat: changes are not %s compatible; publish them as a new version directory\n", *mode)
+		os.Exit(1)
+	}
+}
+
+func diffFiles(oldPath, newPath string) ([]Change, error) {
+	oldRaw, err := os.ReadFile(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newRaw, err := os.ReadFile(newPath)
+	if err != nil {
+		return nil, err
+	}
+	o, err := parseNode(oldRaw)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", oldPath, err)
+	}
+	n, err := parseNode(newRaw)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", newPath, err)
+	}
+	return Diff(o, n), nil
+}
+
+// pairTrees matches schema files of both trees by relative path. A missing
+// side is returned as an empty string.
+func pairTrees(oldDir, newDir string) ([][2]string, error) {
+	oldFiles, err := schemaFiles(oldDir)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := schemaFiles(newDir)
+	if err != nil {
+		return nil, err
+	}
+	rels := map[string]bool{}
+	for r := range oldFiles {
+		rels[r] = true
+	}
+	for r := range newFiles {
+		rels[r] = true
+	}
+	sorted := make([]string, 0, len(rels))
+	for r := range rels {
+		sorted = append(sorted, r)
+	}
+	sort.Strings(sorted)
+
+	pairs := make([][2]string, 0, len(sorted))
+	for _, r := range sorted {
+		pairs = append(pairs, [2]string{oldFiles[r], newFiles[r]})
+	}
+	return pairs, nil
+}
+
+func schemaFiles(root string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, schemaSuffix) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = path
+		return nil
+	})
+	return files, err
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "contractcompat: "+format+"\n", args...)
+	os.Exit(1)
+}
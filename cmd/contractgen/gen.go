@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// schema is the subset of JSON Schema understood by the generator.
+type schema struct {
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Type        json.RawMessage `json:"type"`
+	Format      string          `json:"format"`
+	Properties  json.RawMessage `json:"properties"`
+	Required    []string        `json:"required"`
+	Items       *schema         `json:"items"`
+	Enum        []any           `json:"enum"`
+	MinLength   *int            `json:"minLength"`
+	MaxLength   *int            `json:"maxLength"`
+	Pattern     string          `json:"pattern"`
+	Minimum     *float64        `json:"minimum"`
+	Maximum     *float64        `json:"maximum"`
+	MinItems    *int            `json:"minItems"`
+	MaxItems    *int            `json:"maxItems"`
+
+	props []property
+}
+
+type property struct {
+	name   string
+	schema *schema
+}
+
+func parseSchema(raw []byte) (*schema, error) {
+	var s schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	if err := s.resolve(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// resolve decodes properties in document order so generated fields follow
+// the schema layout.
+func (s *schema) resolve() error {
+	if s.Items != nil {
+		if err := s.Items.resolve(); err != nil {
+			return err
+		}
+	}
+	if len(s.Properties) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(s.Properties))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var child schema
+		if err := dec.Decode(&child); err != nil {
+			return err
+		}
+		if err := child.resolve(); err != nil {
+			return err
+		}
+		s.props = append(s.props, property{name: tok.(string), schema: &child})
+	}
+	return nil
+}
+
+// typeName returns the JSON type and whether null is allowed.
+func (s *schema) typeName() (string, bool) {
+	if len(s.Type) == 0 {
+		if len(s.props) > 0 {
+			return "object", false
+		}
+		return "", false
+	}
+	var one string
+	if json.Unmarshal(s.Type, &one) == nil {
+		return one, false
+	}
+	var many []string
+	_ = json.Unmarshal(s.Type, &many)
+	nullable := false
+	typ := ""
+	for _, t := range many {
+		if t == "null" {
+			nullable = true
+		} else if typ == "" {
+			typ = t
+		}
+	}
+	return typ, nullable
+}
+
+func (s *schema) isRequired(name string) bool {
+	for _, r := range s.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+type generator struct {
+	pkg     string
+	imports map[string]bool
+	types   bytes.Buffer
+	regexps []string
+	names   map[string]bool
+}
+
+func newGenerator(pkg string) *generator {
+	return &generator{pkg: pkg, imports: map[string]bool{}, names: map[string]bool{}}
+}
+
+func (g *generator) addSchema(file string, raw []byte) error {
+	s, err := parseSchema(raw)
+	if err != nil {
+		return err
+	}
+	if typ, _ := s.typeName(); typ != "object" {
+		return fmt.Errorf("top-level schema must be an object, got %q", typ)
+	}
+	name := s.Title
+	if name == "" {
+		name = strings.TrimSuffix(file, schemaSuffix)
+	}
+	return g.object(exportName(name), s, "generated from "+file)
+}
+
+func (g *generator) object(name string, s *schema, origin string) error {
+	if g.names[name] {
+		return fmt.Errorf("duplicate type name %s", name)
+	}
+	g.names[name] = true
+
+	type field struct {
+		goName, jsonName, goType    string
+		required, nullable, pointer bool
+		schema                      *schema
+	}
+	var fields []field
+	var nested []func() error
+	for _, p := range s.props {
+		f := field{goName: exportName(p.name), jsonName: p.name, required: s.isRequired(p.name), schema: p.schema}
+		typ, nullable := p.schema.typeName()
+		f.nullable = nullable
+		goType, err := g.goType(name+f.goName, p.schema, &nested)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", p.name, err)
+		}
+		f.pointer = (nullable || !f.required) && typ != "array" && goType != "any" && !strings.HasPrefix(goType, "map[")
+		f.goType = goType
+		fields = append(fields, f)
+	}
+
+	w := &g.types
+	fmt.Fprintf(w, "// %s is %s.\n", name, origin)
+	if s.Description != "" {
+		fmt.Fprintf(w, "//\n")
+		writeComment(w, s.Description)
+	}
+	fmt.Fprintf(w, "type %s struct {\n", name)
+	for _, f := range fields {
+		tag := f.jsonName
+		if !f.required {
+			tag += ",omitempty"
+		}
+		typ := f.goType
+		if f.pointer {
+			typ = "*" + typ
+		}
+		fmt.Fprintf(w, "\t%s %s `json:%q`\n", f.goName, typ, tag)
+	}
+	fmt.Fprintf(w, "}\n\n")
+
+	// Constructor taking the required fields in schema order.
+	var params, assigns []string
+	for _, f := range fields {
+		if !f.required {
+			continue
+		}
+		arg := lowerFirst(f.goName)
+		if isKeyword(arg) {
+			arg += "_"
+		}
+		if f.pointer {
+			params = append(params, arg+" *"+f.goType)
+		} else {
+			params = append(params, arg+" "+f.goType)
+		}
+		assigns = append(assigns, fmt.Sprintf("%s: %s,", f.goName, arg))
+	}
+	fmt.Fprintf(w, "// New%s returns a new %s with its required fields set.\n", name, name)
+	fmt.Fprintf(w, "func New%s(%s) %s {\n\treturn %s{\n", name, strings.Join(params, ", "), name, name)
+	for _, a := range assigns {
+		fmt.Fprintf(w, "\t\t%s\n", a)
+	}
+	fmt.Fprintf(w, "\t}\n}\n\n")
+
+	// Validate enforces the constraints that the Go type cannot express.
+	fmt.Fprintf(w, "// Validate reports every constraint of the %s schema that m violates.\n", name)
+	fmt.Fprintf(w, "func (m %s) Validate() error {\n\tvar errs []error\n", name)
+	for _, f := range fields {
+		expr := "m." + f.goName
+		var body bytes.Buffer
+		g.checks(&body, f.jsonName, "v", f.goType, f.schema)
+		typ, _ := f.schema.typeName()
+		switch {
+		case f.pointer && f.required && !f.nullable:
+			fmt.Fprintf(w, "\tif %s == nil {\n\t\terrs = append(errs, errors.New(%q))\n\t}", expr, f.jsonName+": is required")
+			g.imports["errors"] = true
+			if body.Len() > 0 {
+				fmt.Fprintf(w, " else {\n\t\tv := *%s\n%s\t}", expr, body.String())
+			}
+			fmt.Fprintf(w, "\n")
+		case f.pointer:
+			if body.Len() > 0 {
+				fmt.Fprintf(w, "\tif %s != nil {\n\t\tv := *%s\n%s\t}\n", expr, expr, body.String())
+			}
+		default:
+			if f.required && typ == "array" {
+				fmt.Fprintf(w, "\tif %s == nil {\n\t\terrs = append(errs, errors.New(%q))\n\t}\n", expr, f.jsonName+": is required")
+				g.imports["errors"] = true
+			}
+			if body.Len() > 0 && !f.required && typ == "array" {
+				fmt.Fprintf(w, "\tif %s != nil {\n\t\tv := %s\n%s\t}\n", expr, expr, body.String())
+			} else if body.Len() > 0 {
+				fmt.Fprintf(w, "\t{\n\t\tv := %s\n%s\t}\n", expr, body.String())
+			}
+		}
+	}
+	fmt.Fprintf(w, "\treturn errors.Join(errs...)\n}\n\n")
+	g.imports["errors"] = true
+
+	// A zero value cannot tell a missing property from "" or 0, so presence
+	// of the required ones is checked while decoding. Emptiness is left to
+	// minLength, as in the schema.
+	var required []field
+	for _, f := range fields {
+		if f.required {
+			required = append(required, f)
+		}
+	}
+	if len(required) > 0 {
+		fmt.Fprintf(w, "// UnmarshalJSON decodes data into m, rejecting missing required properties.\n")
+		fmt.Fprintf(w, "func (m *%s) UnmarshalJSON(data []byte) error {\n", name)
+		fmt.Fprintf(w, "\tvar raw map[string]json.RawMessage\n\tif err := json.Unmarshal(data, &raw); err != nil {\n\t\treturn err\n\t}\n\tvar errs []error\n")
+		for _, f := range required {
+			if f.nullable {
+				fmt.Fprintf(w, "\tif _, ok := raw[%q]; !ok {\n", f.jsonName)
+			} else {
+				fmt.Fprintf(w, "\tif v, ok := raw[%q]; !ok || string(v) == \"null\" {\n", f.jsonName)
+			}
+			fmt.Fprintf(w, "\t\terrs = append(errs, errors.New(%q))\n\t}\n", f.jsonName+": is required")
+		}
+		fmt.Fprintf(w, "\tif err := errors.Join(errs...); err != nil {\n\t\treturn err\n\t}\n")
+		fmt.Fprintf(w, "\ttype plain %s\n\treturn json.Unmarshal(data, (*plain)(m))\n}\n\n", name)
+		g.imports["encoding/json"] = true
+	}
+
+	for _, n := range nested {
+		if err := n(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// goType maps a property schema to a Go type, queueing nested object types.
+func (g *generator) goType(name string, s *schema, nested *[]func() error) (string, error) {
+	typ, _ := s.typeName()
+	switch typ {
+	case "string":
+		if s.Format == "date-time" {
+			g.imports["time"] = true
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		return "int64", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "[]any", nil
+		}
+		elem, err := g.goType(name+"Item", s.Items, nested)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elem, nil
+	case "object":
+		if len(s.props) == 0 {
+			return "map[string]any", nil
+		}
+		*nested = append(*nested, func() error {
+			return g.object(name, s, "a nested object")
+		})
+		return name, nil
+	case "":
+		return "any", nil
+	}
+	return "", fmt.Errorf("unsupported type %q", typ)
+}
+
+// checks writes the constraint checks for a value v of goType into w.
+func (g *generator) checks(w *bytes.Buffer, path, v, goType string, s *schema) {
+	typ, _ := s.typeName()
+	fail := func(cond, msg string, args ...string) {
+		if len(args) == 0 {
+			fmt.Fprintf(w, "\t\tif %s {\n\t\t\terrs = append(errs, errors.New(%q))\n\t\t}\n", cond, path+": "+msg)
+			return
+		}
+		fmt.Fprintf(w, "\t\tif %s {\n\t\t\terrs = append(errs, fmt.Errorf(%q, %s))\n\t\t}\n", cond, path+": "+msg, strings.Join(args, ", "))
+		g.imports["fmt"] = true
+	}
+	switch typ {
+	case "string":
+		if goType != "string" {
+			break
+		}
+		if s.MinLength != nil {
+			g.imports["unicode/utf8"] = true
+			fail(fmt.Sprintf("utf8.RuneCountInString(%s) < %d", v, *s.MinLength), fmt.Sprintf("must be at least %d character(s)", *s.MinLength))
+		}
+		if s.MaxLength != nil {
+			g.imports["unicode/utf8"] = true
+			fail(fmt.Sprintf("utf8.RuneCountInString(%s) > %d", v, *s.MaxLength), fmt.Sprintf("must be at most %d character(s)", *s.MaxLength))
+		}
+		if s.Pattern != "" {
+			g.imports["regexp"] = true
+			g.regexps = append(g.regexps, s.Pattern)
+			fail(fmt.Sprintf("!pattern%d.MatchString(%s)", len(g.regexps)-1, v), "must match %s", strconv.Quote(s.Pattern))
+		}
+		if len(s.Enum) > 0 {
+			var alts []string
+			for _, e := range s.Enum {
+				if str, ok := e.(string); ok {
+					alts = append(alts, fmt.Sprintf("%s != %q", v, str))
+				}
+			}
+			if len(alts) > 0 {
+				fail(strings.Join(alts, " && "), "must be one of %v", fmt.Sprintf("%#v", enumStrings(s.Enum)))
+			}
+		}
+	case "integer", "number":
+		if s.Minimum != nil {
+			fail(fmt.Sprintf("%s < %s", v, num(*s.Minimum, goType)), "must be >= "+num(*s.Minimum, goType))
+		}
+		if s.Maximum != nil {
+			fail(fmt.Sprintf("%s > %s", v, num(*s.Maximum, goType)), "must be <= "+num(*s.Maximum, goType))
+		}
+	case "array":
+		if s.MinItems != nil {
+			fail(fmt.Sprintf("len(%s) < %d", v, *s.MinItems), fmt.Sprintf("must have at least %d item(s)", *s.MinItems))
+		}
+		if s.MaxItems != nil {
+			fail(fmt.Sprintf("len(%s) > %d", v, *s.MaxItems), fmt.Sprintf("must have at most %d item(s)", *s.MaxItems))
+		}
+		if s.Items != nil {
+			if it, _ := s.Items.typeName(); it == "object" && len(s.Items.props) > 0 {
+				g.imports["fmt"] = true
+				fmt.Fprintf(w, "\t\tfor i, item := range %s {\n\t\t\tif err := item.Validate(); err != nil {\n\t\t\t\terrs = append(errs, fmt.Errorf(\"%s[%%d]: %%w\", i, err))\n\t\t\t}\n\t\t}\n", v, path)
+			}
+		}
+	case "object":
+		if len(s.props) > 0 {
+			g.imports["fmt"] = true
+			fmt.Fprintf(w, "\t\tif err := %s.Validate(); err != nil {\n\t\t\terrs = append(errs, fmt.Errorf(\"%s: %%w\", err))\n\t\t}\n", v, path)
+		}
+	}
+}
+
+func (g *generator) format() ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by contractgen. DO NOT EDIT.\n\npackage %s\n\n", g.pkg)
+	if len(g.imports) > 0 {
+		imps := make([]string, 0, len(g.imports))
+		for imp := range g.imports {
+			imps = append(imps, imp)
+		}
+		sort.Strings(imps)
+		fmt.Fprintf(&out, "import (\n")
+		for _, imp := range imps {
+			fmt.Fprintf(&out, "\t%q\n", imp)
+		}
+		fmt.Fprintf(&out, ")\n\n")
+	}
+	if len(g.regexps) > 0 {
+		fmt.Fprintf(&out, "var (\n")
+		for i, p := range g.regexps {
+			fmt.Fprintf(&out, "\tpattern%d = regexp.MustCompile(%q)\n", i, p)
+		}
+		fmt.Fprintf(&out, ")\n\n")
+	}
+	out.Write(g.types.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w\n%s", err, out.String())
+	}
+	return src, nil
+}
+
+var initialisms = map[string]string{"id": "ID", "url": "URL", "uri": "URI", "api": "API", "http": "HTTP", "json": "JSON", "uuid": "UUID", "ip": "IP"}
+
+// exportName converts snake_case, kebab-case and dotted names to Go
+// identifiers, e.g. tenant_id -> TenantID.
+func exportName(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.' || r == ' '
+	})
+	var b strings.Builder
+	for _, p := range parts {
+		if up, ok := initialisms[strings.ToLower(p)]; ok {
+			b.WriteString(up)
+			continue
+		}
+		r := []rune(p)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return b.String()
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	for up := range reverseInitialisms() {
+		if strings.HasPrefix(s, up) && (len(s) == len(up) || unicode.IsUpper(rune(s[len(up)]))) {
+			return strings.ToLower(up) + s[len(up):]
+		}
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func reverseInitialisms() map[string]bool {
+	m := make(map[string]bool, len(initialisms))
+	for _, up := range initialisms {
+		m[up] = true
+	}
+	return m
+}
+
+func writeComment(w *bytes.Buffer, text string) {
+	line := "//"
+	for _, word := range strings.Fields(text) {
+		if len(line)+len(word)+1 > 78 {
+			fmt.Fprintln(w, line)
+			line = "//"
+		}
+		line += " " + word
+	}
+	fmt.Fprintln(w, line)
+}
+
+func enumStrings(enum []any) []string {
+	out := make([]string, 0, len(enum))
+	for _, e := range enum {
+		if s, ok := e.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func num(f float64, goType string) string {
+	if goType == "int64" {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func isKeyword(s string) bool {
+	switch s {
+	case "break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough",
+		"for", "func", "go", "goto", "if", "import", "interface", "map", "package", "range",
+		"return", "select", "struct", "switch", "type", "var":
+		return true
+	}
+	return false
+}
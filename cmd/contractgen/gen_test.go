@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+func TestGeneratedExampleIsCurrent(t *testing.T) {
+	dir := filepath.Join("..", "..", "pkg", "contracts", "example", "v1")
+	code, err := generateDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := os.ReadFile(filepath.Join(dir, outputName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, current) {
+		t.Fatalf("%s is stale, run go generate ./pkg/contracts/...", outputName)
+	}
+}
+
+// schemaDriver decodes each stdin line {"type","data"} into the generated
+// type and prints "ok" or the error, the way nats.Handle accepts a request.
+const schemaDriver = `package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func check[T interface{ Validate() error }](data []byte) error {
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return v.Validate()
+}
+
+func main() {
+	checks := map[string]func([]byte) error{%s}
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		var c struct {
+			Type string
+			Data json.RawMessage
+		}
+		if err := json.Unmarshal(in.Bytes(), &c); err != nil {
+			panic(err)
+		}
+		if err := checks[c.Type](c.Data); err != nil {
+			fmt.Printf("%%q\n", err.Error())
+		} else {
+			fmt.Println("\"ok\"")
+		}
+	}
+}
+`
+
+// TestValidateAgreesWithSchema runs generated code against the schema it
+// came from: a payload the schema accepts must decode and validate, and one
+// it rejects must not.
+func TestValidateAgreesWithSchema(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not found")
+	}
+	schemas := map[string]string{
+		"Plain":    `{"type":"object","properties":{"v":{"type":"string"}},"required":["v"]}`,
+		"NonEmpty": `{"type":"object","properties":{"v":{"type":"string","minLength":1}},"required":["v"]}`,
+		"Optional": `{"type":"object","properties":{"v":{"type":"string","minLength":1}}}`,
+		"Nullable": `{"type":"object","properties":{"v":{"type":["string","null"]}},"required":["v"]}`,
+		"Stamp":    `{"type":"object","properties":{"v":{"type":"string","format":"date-time"}},"required":["v"]}`,
+		"Count":    `{"type":"object","properties":{"v":{"type":"integer"}},"required":["v"]}`,
+		"List":     `{"type":"object","properties":{"v":{"type":"array","items":{"type":"string"}}},"required":["v"]}`,
+	}
+	cases := []struct{ schema, data string }{
+		{"Plain", `{}`},
+		{"Plain", `{"v":""}`},
+		{"Plain", `{"v":"a"}`},
+		{"Plain", `{"v":null}`},
+		{"NonEmpty", `{}`},
+		{"NonEmpty", `{"v":""}`},
+		{"NonEmpty", `{"v":"a"}`},
+		{"Optional", `{}`},
+		{"Optional", `{"v":""}`},
+		{"Optional", `{"v":"a"}`},
+		{"Nullable", `{}`},
+		{"Nullable", `{"v":null}`},
+		{"Nullable", `{"v":""}`},
+		{"Stamp", `{}`},
+		{"Stamp", `{"v":""}`},
+		{"Stamp", `{"v":"0001-01-01T00:00:00Z"}`},
+		{"Stamp", `{"v":"2024-05-06T07:08:09Z"}`},
+		{"Count", `{}`},
+		{"Count", `{"v":0}`},
+		{"List", `{}`},
+		{"List", `{"v":null}`},
+		{"List", `{"v":[]}`},
+	}
+
+	g := newGenerator("main")
+	compiled := map[string]*jsonschema.Schema{}
+	var entries []string
+	for _, name := range []string{"Count", "List", "NonEmpty", "Nullable", "Optional", "Plain", "Stamp"} {
+		raw := schemas[name]
+		if err := g.addSchema(name+schemaSuffix, []byte(raw)); err != nil {
+			t.Fatal(err)
+		}
+		c := jsonschema.NewCompiler()
+		c.Draft = jsonschema.Draft2020
+		c.AssertFormat = true
+		if err := c.AddResource(name, strings.NewReader(raw)); err != nil {
+			t.Fatal(err)
+		}
+		s, err := c.Compile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compiled[name] = s
+		entries = append(entries, fmt.Sprintf("%q: check[%s]", name, name))
+	}
+	code, err := g.format()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":    "module contractgentest\n\ngo 1.21\n",
+		"types.go":  string(code),
+		"driver.go": fmt.Sprintf(schemaDriver, strings.Join(entries, ", ")),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdin bytes.Buffer
+	for _, c := range cases {
+		line, _ := json.Marshal(map[string]any{"type": c.schema, "data": json.RawMessage(c.data)})
+		stdin.Write(append(line, '\n'))
+	}
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off")
+	cmd.Stdin = &stdin
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			t.Fatalf("go run: %v\n%s", err, ee.Stderr)
+		}
+		t.Fatal(err)
+	}
+
+	results := bufio.NewScanner(bytes.NewReader(out))
+	for _, c := range cases {
+		if !results.Scan() {
+			t.Fatal("driver output ended early")
+		}
+		var got string
+		if err := json.Unmarshal(results.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		var doc any
+		if err := json.Unmarshal([]byte(c.data), &doc); err != nil {
+			t.Fatal(err)
+		}
+		schemaErr := compiled[c.schema].Validate(doc)
+		if (schemaErr == nil) != (got == "ok") {
+			t.Errorf("%s %s: schema says %v, Go says %s", c.schema, c.data, schemaErr, got)
+		}
+	}
+}
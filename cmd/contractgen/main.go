@@ -0,0 +1,132 @@
+// Command contractgen generates Go types, constructors and Validate methods
+// from the JSON schemas in pkg/contracts, keeping the schemas the single
+// source of truth for message DTOs.
+//
+//	go run ./cmd/contractgen              # regenerate
+//	go run ./cmd/contractgen -check       # fail if generated code is stale
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	schemaSuffix = ".schema.json"
+	outputName   = "contracts_gen.go"
+)
+
+func main() {
+	dir := flag.String("dir", "pkg/contracts", "root directory scanned for *"+schemaSuffix+" files")
+	check := flag.Bool("check", false, "do not write files; exit 1 if generated code is stale")
+	flag.Parse()
+
+	dirs, err := schemaDirs(*dir)
+	if err != nil {
+		fatalf("scan %s: %v", *dir, err)
+	}
+	if len(dirs) == 0 {
+		fatalf("no %s files under %s", schemaSuffix, *dir)
+	}
+
+	stale := false
+	for _, d := range dirs {
+		code, err := generateDir(d)
+		if err != nil {
+			fatalf("%s: %v", d, err)
+		}
+		out := filepath.Join(d, outputName)
+		current, err := os.ReadFile(out)
+		if err != nil && !os.IsNotExist(err) {
+			fatalf("read %s: %v", out, err)
+		}
+		if bytes.Equal(current, code) {
+			continue
+		}
+		if *check {
+			fmt.Fprintf(os.Stderr, "contractgen: %s is stale, run go generate ./pkg/contracts/...\n", out)
+			stale = true
+			continue
+		}
+		if err := os.WriteFile(out, code, 0o644); err != nil {
+			fatalf("write %s: %v", out, err)
+		}
+		fmt.Printf("contractgen: wrote %s\n", out)
+	}
+	if stale {
+		os.Exit(1)
+	}
+}
+
+// schemaDirs returns every directory under root holding at least one schema.
+func schemaDirs(root string) ([]string, error) {
+	seen := map[string]bool{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, schemaSuffix) {
+			seen[filepath.Dir(path)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	dirs := make([]string, 0, len(seen))
+	for d := range seen {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+func generateDir(dir string) ([]byte, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+schemaSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	g := newGenerator(packageName(dir))
+	for _, f := range files {
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if err := g.addSchema(filepath.Base(f), raw); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(f), err)
+		}
+	}
+	return g.format()
+}
+
+// packageName reuses the package clause of a hand-written file in dir, or
+// falls back to the directory name.
+func packageName(dir string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, m := range matches {
+		if filepath.Base(m) == outputName || strings.HasSuffix(m, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), m, nil, parser.PackageClauseOnly)
+		if err == nil {
+			return f.Name.Name
+		}
+	}
+	abs, _ := filepath.Abs(dir)
+	return strings.ReplaceAll(filepath.Base(abs), "-", "")
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "contractgen: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"modelo-mcp/internal/config"
+	"modelo-mcp/internal/database"
+	"modelo-mcp/internal/log"
+)
+
+const usage = `usage: modelo-mcp [command]
+
+Without a command the service starts.
+
+Commands:
+  config check          validate the configuration and exit
+  config show [-json]   print the effective configuration, secrets
+                        redacted, with the source of every key
+  migrate up            apply the pending database migrations
+  migrate down [-steps n]
+                        roll back the last n migrations (default 1)
+  migrate status        list migrations and whether they are applied
+  migrate create [-dir d] <name>
+                        write empty up/down files for a new migration
+                        (default dir: migrations)
+`
+
+// runCommand runs a CLI subcommand and returns the process exit code.
+func runCommand(args []string) int {
+	switch {
+	case len(args) == 2 && args[0] == "config" && args[1] == "check":
+		return configCheck()
+	case len(args) >= 2 && args[0] == "config" && args[1] == "show":
+		return configShow(args[2:])
+	case len(args) >= 2 && args[0] == "migrate":
+		return migrate(args[1], args[2:])
+	case args[0] == "help" || args[0] == "-h" || args[0] == "--help":
+		fmt.Print(usage)
+		return 0
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return 2
+}
+
+func configCheck() int {
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+	fmt.Printf("configuration OK (service=%s, environment=%s)\n", cfg.ServiceName, cfg.Environment)
+	return 0
+}
+
+func configShow(args []string) int {
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+	settings := cfg.Effective()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(settings)
+		return 0
+	}
+	// Values come last: lists of streams would otherwise widen every column.
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSOURCE\tVALUE")
+	for _, s := range settings {
+		var value bytes.Buffer
+		enc := json.NewEncoder(&value)
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(s.Value)
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, s.Source, bytes.TrimSpace(value.Bytes()))
+	}
+	_ = tw.Flush()
+	return 0
+}
+
+func migrate(cmd string, args []string) int {
+	fs := flag.NewFlagSet("migrate "+cmd, flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	dir := fs.String("dir", "migrations", "directory of the migration files")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if cmd == "create" {
+		if fs.NArg() != 1 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		up, down, err := database.CreateMigration(*dir, fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate create: %v\n", err)
+			return 1
+		}
+		fmt.Printf("created %s\ncreated %s\n", up, down)
+		return 0
+	}
+	if cmd != "up" && cmd != "down" && cmd != "status" {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	logger := log.New(cfg.Environment, cfg.ServiceName, cfg.LogLevel)
+	db, err := database.NewConnection(cfg.Database, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	defer database.Close(db)
+	m, err := database.NewMigrator(db, database.MigrationSource(cfg.Database.MigrationsPath), logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+
+	switch cmd {
+	case "up":
+		n, err := m.Up(ctx)
+		fmt.Printf("%d migration(s) applied\n", n)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate up: %v\n", err)
+			return 1
+		}
+	case "down":
+		n, err := m.Down(ctx, *steps)
+		fmt.Printf("%d migration(s) rolled back\n", n)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate down: %v\n", err)
+			return 1
+		}
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate status: %v\n", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, s := range status {
+			at := ""
+			if s.AppliedAt != nil {
+				at = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%06d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, at)
+		}
+		_ = tw.Flush()
+	}
+	return 0
+}
+
+func loadConfig() (*config.Config, bool) {
+	cfg, err := config.Load(config.NewSecrets(0))
+	if err != nil {
+		var verr *config.ValidationError
+		if errors.As(err, &verr) {
+			fmt.Fprintln(os.Stderr, verr)
+		} else {
+			fmt.Fprintf(os.Stderr, "load config: %v\n", err)
+		}
+		return nil, false
+	}
+	return cfg, true
+}
@@ -3,45 +3,39 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"github.com/nats-io/nats.go"
 
+	"modelo-mcp/internal/bootstrap"
 	"modelo-mcp/internal/config"
-	"modelo-mcp/internal/log"
+	"modelo-mcp/internal/database"
+	"modelo-mcp/internal/health"
 	"modelo-mcp/internal/metrics"
 	natsx "modelo-mcp/internal/nats"
-	"modelo-mcp/internal/otel"
 	httpx "modelo-mcp/internal/transport/http"
-	"modelo-mcp/internal/version"
 )
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	cfg := config.Load()
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
 
-	logger := log.New(cfg.Env, cfg.ServiceName)
-	logger.Info("starting service", "service", cfg.ServiceName, "version", version.Version, "commit", version.Commit)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-	// OTEL (tracing and metrics)
-	tp, mp, err := otel.SetupOTEL(ctx, cfg)
+	app, err := bootstrap.New(ctx)
 	if err != nil {
-		logger.Error("OTEL setup failed", "error", err)
+		fmt.Fprintf(os.Stderr, "modelo-mcp: %v\n", err)
+		os.Exit(1)
 	}
-	defer func() {
-		if tp != nil { _ = tp.Shutdown(context.Background()) }
-		if mp != nil { _ = mp.Shutdown(context.Background()) }
-	}()
+	cfg, logger := app.Config, app.Logger
 
-	// Metrics registry
-	promReg := metrics.NewRegistry(cfg)
+	// Readiness waits for the handlers to be subscribed
+	warmup := &health.Gate{}
+	app.Health.Register("handlers", warmup.Check)
 
 	// NATS JetStream
 	nc, jsm, err := natsx.Connect(ctx, cfg, logger)
@@ -49,36 +43,97 @@ func main() {
 		logger.Error("failed to connect NATS", "error", err)
 		os.Exit(1)
 	}
-	defer nc.Drain()
+	app.OnShutdown(func(context.Context) error { return nc.Drain() })
+	app.Health.Register("nats", natsx.ConnCheck(nc))
+	app.Health.Register("jetstream", natsx.StreamCheck(jsm, cfg.NATS.Stream))
+
+	// Dead-letter stream and retry policy for handlers
+	dlq := natsx.NewDLQ(jsm, cfg.NATS.Stream, logger)
+	// Without the DLQ every dead-lettered message would be nak'd forever.
+	if err := dlq.Ensure(); err != nil {
+		logger.Error("failed to ensure DLQ stream", "error", err)
+		os.Exit(1)
+	}
+	retrier := natsx.NewRetrier(natsx.NewRetryPolicies(cfg.NATS.Retry), dlq, logger)
 
 	// HTTP server
-	r := httpx.Router(cfg, logger, promReg)
-	server := &http.Server{
-		Addr:              fmt.Sprintf(":%d", cfg.HTTPPort),
-		Handler:           otelhttp.NewHandler(r, cfg.ServiceName),
-		ReadTimeout:       10 * time.Second,
-		ReadHeaderTimeout: 10 * time.Second,
-		WriteTimeout:      20 * time.Second,
-		IdleTimeout:       120 * time.Second,
+	// Dead letters carry message payloads and can be replayed: admins only.
+	app.Router.With(httpx.AdminOnly(app.Auth)).Mount("/admin/dlq", httpx.DLQRoutes(dlq, logger))
+	app.Start()
+
+	// Contracts from pkg/contracts
+	schemas, err := natsx.NewSchemaValidator()
+	if err != nil {
+		logger.Error("failed to load contract schemas", "error", err)
+		os.Exit(1)
 	}
-	go func() {
-		logger.Info("http server listening", "port", cfg.HTTPPort)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error("http server failed", "error", err)
-		}
-	}()
 
 	// Handlers (example)
-	natsx.RegisterExampleHandlers(ctx, jsm, cfg, logger)
+	handlers := natsx.NewRegistry(nc, jsm, natsx.NewConsumerPolicies(cfg.NATS.Consumers), retrier, logger)
+	handlers.Use(
+		natsx.Recover(logger),
+		natsx.Tracing(),
+		natsx.Metrics(metrics.NewNATSMetrics(app.Metrics)),
+		natsx.Logging(logger),
+		natsx.ValidateRequests(schemas),
+	)
+	if cfg.IsDevelopment() {
+		handlers.ValidateReplies(schemas)
+	}
+	idem, err := newIdempotencyStore(cfg, jsm, app.Health)
+	if err != nil {
+		logger.Error("failed to set up idempotency store", "error", err)
+		os.Exit(1)
+	}
+	natsx.RegisterExampleHandlers(handlers, cfg, idem)
+	// In-flight messages keep their context on SIGTERM; Stop lets them finish.
+	if err := handlers.Start(context.WithoutCancel(ctx)); err != nil {
+		logger.Error("failed to start handlers", "error", err)
+		os.Exit(1)
+	}
+	app.OnShutdown(func(context.Context) error { handlers.Stop(); return nil })
 
-	// Signals
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-	<-sig
-	logger.Info("shutdown signal received")
+	// Transactional outbox relay
+	if cfg.Outbox.Enabled {
+		db, err := database.NewConnection(cfg.Database, logger)
+		if err != nil {
+			logger.Error("failed to connect to database", "error", err)
+			os.Exit(1)
+		}
+		app.OnShutdown(func(context.Context) error { return database.Close(db) })
+		go database.ReportPoolStats(ctx, db, cfg.Database.StatsInterval, metrics.NewDatabaseMetrics(app.Metrics).SetConnections)
+		if cfg.AutoMigrate {
+			if err := database.RunMigrations(ctx, db, cfg.Database, logger); err != nil {
+				logger.Error("failed to run database migrations", "error", err)
+				os.Exit(1)
+			}
+		}
+		app.Health.Register("postgres", database.PostgresCheck(db))
+		relay := natsx.NewOutboxRelay(db, jsm, cfg.ServiceName, cfg.Outbox, metrics.NewOutboxMetrics(app.Metrics), logger)
+		go relay.Run(ctx)
+	}
+	warmup.Open()
 
-	shutdownCtx, cancel2 := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel2()
-	_ = server.Shutdown(shutdownCtx)
-	logger.Info("bye")
+	if err := app.Wait(ctx); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newIdempotencyStore builds the deduplication store selected by
+// nats.idempotency.backend. A Redis backend is registered as a readiness
+// check.
+func newIdempotencyStore(cfg *config.Config, js nats.JetStreamContext, checks *health.Registry) (natsx.IdempotencyStore, error) {
+	idem := cfg.NATS.Idempotency
+	switch idem.Backend {
+	case "redis":
+		client, err := database.NewRedisClient(cfg.Redis.URL)
+		if err != nil {
+			return nil, err
+		}
+		checks.Register("redis", database.RedisCheck(client))
+		return natsx.NewRedisIdempotencyStore(client, idem.RedisPrefix), nil
+	case "kv", "":
+		return natsx.NewKVIdempotencyStore(js, idem.Bucket, idem.TTL)
+	}
+	return nil, fmt.Errorf("unknown idempotency backend %q", idem.Backend)
 }
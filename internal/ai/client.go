@@ -0,0 +1,70 @@
+// Package ai holds the client the AI services reach their provider with.
+// Its settings come from the ai section and are swapped atomically on config
+// reload, so a new model, key or endpoint applies from the next request on
+// without a restart.
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"modelo-mcp/internal/config"
+)
+
+// ErrDisabled is returned for requests while ai.enabled is false.
+var ErrDisabled = errors.New("ai is disabled")
+
+// Client sends requests to the configured AI provider. It is safe for
+// concurrent use, including with Reconfigure.
+type Client struct {
+	http *http.Client
+	cfg  atomic.Pointer[config.AIConfig]
+}
+
+// NewClient returns a client using cfg. A nil client uses
+// http.DefaultClient.
+func NewClient(cfg config.AIConfig, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := &Client{http: client}
+	c.Reconfigure(cfg)
+	return c
+}
+
+// Config returns the settings in use.
+func (c *Client) Config() config.AIConfig {
+	return *c.cfg.Load()
+}
+
+// Reconfigure switches to cfg. Requests already built keep the settings
+// they were built with.
+func (c *Client) Reconfigure(cfg config.AIConfig) {
+	c.cfg.Store(&cfg)
+}
+
+// NewRequest builds a request for path below ai.base_url, authenticated
+// with ai.api_key, from one consistent snapshot of the settings.
+func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	cfg := c.cfg.Load()
+	if !cfg.Enabled {
+		return nil, ErrDisabled
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(cfg.BaseURL, "/")+"/"+strings.TrimLeft(path, "/"), body)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+	return req, nil
+}
+
+// Do sends req, built by NewRequest.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.http.Do(req)
+}
@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"modelo-mcp/internal/config"
+)
+
+func TestClientReconfigure(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.Path+" "+r.Header.Get("Authorization"))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	c := NewClient(config.AIConfig{Enabled: true, BaseURL: srv.URL + "/v1/", APIKey: "old", Model: "m1"}, srv.Client())
+	send := func() error {
+		req, err := c.NewRequest(context.Background(), http.MethodPost, "/chat", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.Body.Close()
+	}
+
+	if err := send(); err != nil {
+		t.Fatal(err)
+	}
+	c.Reconfigure(config.AIConfig{Enabled: true, BaseURL: srv.URL + "/v2", APIKey: "new", Model: "m2"})
+	if err := send(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Config().Model; got != "m2" {
+		t.Errorf("model = %q, want m2", got)
+	}
+	want := []string{"/v1/chat Bearer old", "/v2/chat Bearer new"}
+	if len(seen) != 2 || seen[0] != want[0] || seen[1] != want[1] {
+		t.Errorf("requests = %q, want %q", seen, want)
+	}
+
+	c.Reconfigure(config.AIConfig{BaseURL: srv.URL})
+	if err := send(); !errors.Is(err, ErrDisabled) {
+		t.Errorf("disabled: err = %v, want ErrDisabled", err)
+	}
+}
+
+// TestClientReconfigureConcurrent is meant for -race: reloads swap the
+// settings while requests are being built.
+func TestClientReconfigureConcurrent(t *testing.T) {
+	c := NewClient(config.AIConfig{Enabled: true, BaseURL: "http://a", APIKey: "a"}, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				c.Reconfigure(config.AIConfig{Enabled: true, BaseURL: "http://b", APIKey: "b"})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				req, err := c.NewRequest(context.Background(), http.MethodGet, "x", nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// Endpoint and key always come from the same snapshot.
+				if host, key := req.URL.Host, req.Header.Get("Authorization"); key != "Bearer "+host {
+					t.Errorf("host %s with key %q", host, key)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,144 @@
+// Package auth verifies the bearer JWTs of API and admin requests. Tokens
+// are signed with the shared HMAC secret or with an asymmetric key from a
+// PEM file or a JWKS endpoint; issuer, audience and time claims are checked
+// with the configured leeway.
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"modelo-mcp/internal/config"
+)
+
+const jwksTimeout = 10 * time.Second
+
+// ErrKeyNotFound is returned for a token no configured key can verify.
+var ErrKeyNotFound = errors.New("no key for token")
+
+var (
+	hmacAlgorithms      = []string{"HS256", "HS384", "HS512"}
+	publicKeyAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+)
+
+// Verifier checks bearer tokens against the jwt configuration. It is safe
+// for concurrent use.
+type Verifier struct {
+	secret []byte
+	keys   []any // from jwt.public_key_file
+	jwks   *jwks // nil without jwt.jwks_url
+	parser *jwt.Parser
+}
+
+// NewVerifier loads the keys of cfg. The JWKS is fetched on first use and
+// then every cfg.JWKSRefresh, or sooner when a token names an unknown kid,
+// so rotated keys are picked up without a restart. A nil client uses one
+// with a 10s timeout.
+func NewVerifier(cfg config.JWTConfig, client *http.Client, logger *slog.Logger) (*Verifier, error) {
+	v := &Verifier{}
+	if cfg.Secret != "" {
+		v.secret = []byte(cfg.Secret)
+	}
+	if cfg.PublicKeyFile != "" {
+		keys, err := LoadPublicKeys(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+	if cfg.JWKSURL != "" {
+		if client == nil {
+			client = &http.Client{Timeout: jwksTimeout}
+		}
+		v.jwks = newJWKS(cfg.JWKSURL, cfg.JWKSRefresh, client, logger)
+	}
+
+	algs := cfg.Algorithms
+	if len(algs) == 0 {
+		if v.secret != nil {
+			algs = append(algs, hmacAlgorithms...)
+		}
+		if v.keys != nil || v.jwks != nil {
+			algs = append(algs, publicKeyAlgorithms...)
+		}
+	}
+	if len(algs) == 0 {
+		return nil, errors.New("no secret, public key file or JWKS URL configured")
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(algs), jwt.WithLeeway(cfg.Leeway), jwt.WithIssuedAt()}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+// Verify parses tokenString and returns its claims when the signature,
+// algorithm, issuer, audience and time claims are all valid. ctx bounds a
+// JWKS fetch the token may trigger.
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		return v.key(ctx, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// key picks the verification key for t: the secret for HMAC tokens, else
+// the JWKS key named by kid, else the first configured key that fits the
+// algorithm. Key types never cross, so a public key cannot be used as an
+// HMAC secret.
+func (v *Verifier) key(ctx context.Context, t *jwt.Token) (any, error) {
+	alg := t.Method.Alg()
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		if v.secret == nil {
+			return nil, fmt.Errorf("%w: %s without jwt.secret", ErrKeyNotFound, alg)
+		}
+		return v.secret, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	if v.jwks != nil {
+		if k, ok := v.jwks.key(ctx, kid, t.Method); ok {
+			return k, nil
+		}
+	}
+	for _, k := range v.keys {
+		if fits(t.Method, k) {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: alg %s, kid %q", ErrKeyNotFound, alg, kid)
+}
+
+// fits reports whether key can verify signatures made with method.
+func fits(method jwt.SigningMethod, key any) bool {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		k, ok := key.(*ecdsa.PublicKey)
+		return ok && k.Curve.Params().BitSize == m.CurveBits
+	case *jwt.SigningMethodEd25519:
+		_, ok := key.(ed25519.PublicKey)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,406 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"modelo-mcp/internal/config"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// signer is a key pair of one of the supported key types.
+type signer struct {
+	method jwt.SigningMethod
+	key    crypto.Signer
+	kid    string
+}
+
+var (
+	keysOnce sync.Once
+	rsaKey   *rsa.PrivateKey
+	ecKey    *ecdsa.PrivateKey
+	edKey    ed25519.PrivateKey
+)
+
+// signers returns an RS256, an ES256 and an EdDSA signer, generated once
+// per test binary.
+func signers(t *testing.T) []signer {
+	t.Helper()
+	keysOnce.Do(func() {
+		var err error
+		if rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			panic(err)
+		}
+		if ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			panic(err)
+		}
+		if _, edKey, err = ed25519.GenerateKey(rand.Reader); err != nil {
+			panic(err)
+		}
+	})
+	return []signer{
+		{method: jwt.SigningMethodRS256, key: rsaKey, kid: "rsa"},
+		{method: jwt.SigningMethodES256, key: ecKey, kid: "ec"},
+		{method: jwt.SigningMethodEdDSA, key: edKey, kid: "ed"},
+	}
+}
+
+func (s signer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(s.method, claims)
+	tok.Header["kid"] = s.kid
+	signed, err := tok.SignedString(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// jwk renders the public key of s as a JWK.
+func (s signer) jwk() map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": s.kid, "use": "sig", "alg": s.method.Alg(),
+			"n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return map[string]string{"kty": "EC", "kid": s.kid, "crv": pub.Curve.Params().Name,
+			"x": b64(pub.X.FillBytes(make([]byte, size))), "y": b64(pub.Y.FillBytes(make([]byte, size)))}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": s.kid, "crv": "Ed25519", "x": b64(pub)}
+	}
+	panic("unsupported key")
+}
+
+// writePEM writes the public keys of ss to a PEM file.
+func writePEM(t *testing.T, ss ...signer) string {
+	t.Helper()
+	var data []byte
+	for _, s := range ss {
+		der, err := x509.MarshalPKIXPublicKey(s.key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...)
+	}
+	path := filepath.Join(t.TempDir(), "keys.pem")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// jwksServer serves the JWKS of the signers it currently holds and counts
+// the fetches.
+type jwksServer struct {
+	*httptest.Server
+	hits atomic.Int32
+	mu   sync.Mutex
+	keys []signer
+}
+
+func newJWKSServer(t *testing.T, ss ...signer) *jwksServer {
+	s := &jwksServer{keys: ss}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var body struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for _, k := range s.keys {
+			body.Keys = append(body.Keys, k.jwk())
+		}
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) set(ss ...signer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = ss
+}
+
+func newVerifier(t *testing.T, cfg config.JWTConfig) *Verifier {
+	t.Helper()
+	if cfg.JWKSRefresh == 0 {
+		cfg.JWKSRefresh = time.Hour
+	}
+	v, err := NewVerifier(cfg, nil, discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{"sub": "u1", "iat": now.Unix(), "exp": now.Add(time.Hour).Unix()}
+}
+
+func TestVerifyPublicKeys(t *testing.T) {
+	ss := signers(t)
+	pemFile := writePEM(t, ss...)
+	srv := newJWKSServer(t, ss...)
+
+	sources := map[string]config.JWTConfig{
+		"pem":  {PublicKeyFile: pemFile},
+		"jwks": {JWKSURL: srv.URL},
+	}
+	for name, cfg := range sources {
+		v := newVerifier(t, cfg)
+		for _, s := range ss {
+			t.Run(name+"/"+s.method.Alg(), func(t *testing.T) {
+				claims, err := v.Verify(context.Background(), s.sign(t, validClaims()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if claims["sub"] != "u1" {
+					t.Errorf("sub = %v", claims["sub"])
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	ss := signers(t)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newVerifier(t, config.JWTConfig{PublicKeyFile: writePEM(t, ss...)})
+
+	forged := signer{method: jwt.SigningMethodES256, key: other, kid: "ec"}.sign(t, validClaims())
+	if _, err := v.Verify(context.Background(), forged); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("err = %v, want an invalid signature", err)
+	}
+}
+
+func TestVerifyJWKSRefetch(t *testing.T) {
+	ss := signers(t)
+	rs, ec := ss[0], ss[1]
+	srv := newJWKSServer(t, rs)
+	v := newVerifier(t, config.JWTConfig{JWKSURL: srv.URL})
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, rs.sign(t, validClaims())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(ctx, rs.sign(t, validClaims())); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.hits.Load(); n != 1 {
+		t.Fatalf("%d fetches for a cached kid, want 1", n)
+	}
+
+	// The IdP rotates to a new key. Until jwksMinInterval has passed since
+	// the last fetch, tokens naming it are rejected without a fetch.
+	srv.set(ec)
+	if _, err := v.Verify(ctx, ec.sign(t, validClaims())); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("err = %v, want ErrKeyNotFound", err)
+	}
+	if n := srv.hits.Load(); n != 1 {
+		t.Fatalf("%d fetches within jwksMinInterval, want 1", n)
+	}
+
+	v.jwks.mu.Lock()
+	v.jwks.triedAt = time.Now().Add(-jwksMinInterval)
+	v.jwks.mu.Unlock()
+	if _, err := v.Verify(ctx, ec.sign(t, validClaims())); err != nil {
+		t.Fatalf("unknown kid after the interval: %v", err)
+	}
+	if n := srv.hits.Load(); n != 2 {
+		t.Fatalf("%d fetches, want a refetch for the unknown kid", n)
+	}
+	// The rotated-out key is gone.
+	if _, err := v.Verify(ctx, rs.sign(t, validClaims())); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("rotated-out key: err = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestVerifyJWKSRefresh(t *testing.T) {
+	rs := signers(t)[0]
+	srv := newJWKSServer(t, rs)
+	v := newVerifier(t, config.JWTConfig{JWKSURL: srv.URL, JWKSRefresh: time.Nanosecond})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		v.jwks.mu.Lock()
+		v.jwks.triedAt = time.Time{}
+		v.jwks.mu.Unlock()
+		if _, err := v.Verify(ctx, rs.sign(t, validClaims())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := srv.hits.Load(); n != 2 {
+		t.Errorf("%d fetches, want one per expired refresh", n)
+	}
+
+	// A failing endpoint keeps the cached keys.
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	v.jwks.mu.Lock()
+	v.jwks.triedAt = time.Time{}
+	v.jwks.mu.Unlock()
+	if _, err := v.Verify(ctx, rs.sign(t, validClaims())); err != nil {
+		t.Errorf("JWKS down: %v, want the cached key", err)
+	}
+}
+
+func TestVerifyIssuerAudience(t *testing.T) {
+	rs := signers(t)[0]
+	v := newVerifier(t, config.JWTConfig{PublicKeyFile: writePEM(t, rs), Issuer: "https://idp.example.com", Audience: "mcp"})
+
+	tests := []struct {
+		name     string
+		iss, aud any
+		wantErr  error
+	}{
+		{name: "match", iss: "https://idp.example.com", aud: "mcp"},
+		{name: "audience list", iss: "https://idp.example.com", aud: []string{"other", "mcp"}},
+		{name: "wrong issuer", iss: "https://evil.example.com", aud: "mcp", wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "no issuer", aud: "mcp", wantErr: jwt.ErrTokenRequiredClaimMissing},
+		{name: "wrong audience", iss: "https://idp.example.com", aud: "other", wantErr: jwt.ErrTokenInvalidAudience},
+		{name: "no audience", iss: "https://idp.example.com", wantErr: jwt.ErrTokenRequiredClaimMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			if tt.iss != nil {
+				claims["iss"] = tt.iss
+			}
+			if tt.aud != nil {
+				claims["aud"] = tt.aud
+			}
+			_, err := v.Verify(context.Background(), rs.sign(t, claims))
+			if tt.wantErr == nil && err != nil {
+				t.Fatal(err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyLeeway(t *testing.T) {
+	rs := signers(t)[0]
+	v := newVerifier(t, config.JWTConfig{PublicKeyFile: writePEM(t, rs), Leeway: 30 * time.Second})
+	now := time.Now()
+	at := func(d time.Duration) int64 { return now.Add(d).Unix() }
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr error
+	}{
+		{name: "expired within leeway", claims: jwt.MapClaims{"exp": at(-20 * time.Second)}},
+		{name: "expired beyond leeway", claims: jwt.MapClaims{"exp": at(-40 * time.Second)}, wantErr: jwt.ErrTokenExpired},
+		{name: "not yet valid within leeway", claims: jwt.MapClaims{"nbf": at(20 * time.Second)}},
+		{name: "not yet valid beyond leeway", claims: jwt.MapClaims{"nbf": at(40 * time.Second)}, wantErr: jwt.ErrTokenNotValidYet},
+		{name: "issued ahead within leeway", claims: jwt.MapClaims{"iat": at(20 * time.Second)}},
+		{name: "issued ahead beyond leeway", claims: jwt.MapClaims{"iat": at(40 * time.Second)}, wantErr: jwt.ErrTokenUsedBeforeIssued},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), rs.sign(t, tt.claims))
+			if tt.wantErr == nil && err != nil {
+				t.Fatal(err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestVerifyHMACConfusion signs HS256 tokens with the public key as the
+// secret, as an attacker who knows it would.
+func TestVerifyHMACConfusion(t *testing.T) {
+	rs := signers(t)[0]
+	pemFile := writePEM(t, rs)
+	pemBytes, err := os.ReadFile(pemFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(rs.key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := func(secret []byte) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.JWTConfig
+	}{
+		{name: "default algorithms", cfg: config.JWTConfig{PublicKeyFile: pemFile}},
+		{name: "HS256 allowed without secret", cfg: config.JWTConfig{PublicKeyFile: pemFile, Algorithms: []string{"RS256", "HS256"}}},
+		{name: "with a secret", cfg: config.JWTConfig{PublicKeyFile: pemFile, Secret: "the-real-shared-secret-of-32-bytes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newVerifier(t, tt.cfg)
+			for _, secret := range [][]byte{pemBytes, der} {
+				if _, err := v.Verify(context.Background(), hs(secret)); err == nil {
+					t.Fatal("HS256 token signed with the public key verified")
+				}
+			}
+		})
+	}
+
+	v := newVerifier(t, tests[2].cfg)
+	if _, err := v.Verify(context.Background(), hs([]byte(tests[2].cfg.Secret))); err != nil {
+		t.Errorf("HS256 with the secret: %v", err)
+	}
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	ss := signers(t)
+	v := newVerifier(t, config.JWTConfig{PublicKeyFile: writePEM(t, ss...), Algorithms: []string{"ES256"}})
+	if _, err := v.Verify(context.Background(), ss[1].sign(t, validClaims())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(context.Background(), ss[0].sign(t, validClaims())); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("RS256 outside jwt.algorithms: err = %v", err)
+	}
+
+	if _, err := NewVerifier(config.JWTConfig{}, nil, discard); err == nil {
+		t.Error("NewVerifier without keys: want an error")
+	}
+}
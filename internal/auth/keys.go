@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksMinInterval spaces out fetches, so tokens with made-up kids cannot
+// make every request hit the JWKS endpoint.
+const jwksMinInterval = 10 * time.Second
+
+// LoadPublicKeys reads the RSA, ECDSA and Ed25519 public keys of a PEM file.
+// It accepts PUBLIC KEY, RSA PUBLIC KEY and CERTIFICATE blocks and skips
+// any other.
+func LoadPublicKeys(path string) ([]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read public key file: %w", err)
+	}
+	var keys []any
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		var key any
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s block: %w", path, block.Type, err)
+		}
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("%s: unsupported %T", path, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no public keys", path)
+	}
+	return keys, nil
+}
+
+// jwk is a verification key from the JWKS.
+type jwk struct {
+	kid string
+	alg string // empty when the JWK does not pin one
+	key any
+}
+
+// jwks caches the keys of a JWKS endpoint. Keys are refetched once they are
+// older than refresh, or when a token names a kid the cache lacks; a failed
+// fetch keeps the previous keys.
+type jwks struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	logger  *slog.Logger
+
+	fetchMu sync.Mutex // one fetch at a time
+
+	mu        sync.RWMutex
+	keys      []jwk
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+func newJWKS(url string, refresh time.Duration, client *http.Client, logger *slog.Logger) *jwks {
+	return &jwks{url: url, refresh: refresh, client: client, logger: logger.With("jwks_url", url)}
+}
+
+// key returns the key named kid, or with no kid the first one fitting
+// method, refetching the set when needed.
+func (s *jwks) key(ctx context.Context, kid string, method jwt.SigningMethod) (any, bool) {
+	s.mu.RLock()
+	keys, fetchedAt := s.keys, s.fetchedAt
+	s.mu.RUnlock()
+
+	k, ok := find(keys, kid, method)
+	if !ok || time.Since(fetchedAt) >= s.refresh {
+		keys = s.fetch(ctx)
+		k, ok = find(keys, kid, method)
+	}
+	return k, ok
+}
+
+func find(keys []jwk, kid string, method jwt.SigningMethod) (any, bool) {
+	for _, k := range keys {
+		if kid != "" && k.kid != kid {
+			continue
+		}
+		if (k.alg == "" || k.alg == method.Alg()) && fits(method, k.key) {
+			return k.key, true
+		}
+	}
+	return nil, false
+}
+
+// fetch refreshes the cache unless another fetch happened in the last
+// jwksMinInterval, and returns the cached keys.
+func (s *jwks) fetch(ctx context.Context) []jwk {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	s.mu.RLock()
+	keys, triedAt := s.keys, s.triedAt
+	s.mu.RUnlock()
+	if time.Since(triedAt) < jwksMinInterval {
+		return keys
+	}
+
+	fresh, err := s.get(ctx)
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.triedAt = now
+	if err != nil {
+		s.logger.Warn("jwks fetch failed, keeping cached keys", "keys", len(s.keys), "error", err)
+		return s.keys
+	}
+	s.keys, s.fetchedAt = fresh, now
+	return fresh
+}
+
+func (s *jwks) get(ctx context.Context) ([]jwk, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: %s", resp.Status)
+	}
+
+	var body struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := make([]jwk, 0, len(body.Keys))
+	for _, raw := range body.Keys {
+		k, err := parseJWK(raw)
+		if err != nil {
+			// One unusable key, e.g. an encryption key, must not hide the others.
+			s.logger.Debug("jwks key skipped", "error", err)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks: no usable signing keys in %d", len(body.Keys))
+	}
+	return keys, nil
+}
+
+func parseJWK(raw json.RawMessage) (jwk, error) {
+	var j struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		Crv string `json:"crv"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+	if err := json.Unmarshal(raw, &j); err != nil {
+		return jwk{}, err
+	}
+	if j.Use != "" && j.Use != "sig" {
+		return jwk{}, fmt.Errorf("kid %q: use %q", j.Kid, j.Use)
+	}
+
+	var key any
+	var err error
+	switch j.Kty {
+	case "RSA":
+		key, err = rsaJWK(j.N, j.E)
+	case "EC":
+		key, err = ecJWK(j.Crv, j.X, j.Y)
+	case "OKP":
+		key, err = okpJWK(j.Crv, j.X)
+	default:
+		err = fmt.Errorf("unsupported kty %q", j.Kty)
+	}
+	if err != nil {
+		return jwk{}, fmt.Errorf("kid %q: %w", j.Kid, err)
+	}
+	return jwk{kid: j.Kid, alg: j.Alg, key: key}, nil
+}
+
+func rsaJWK(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, fmt.Errorf("n: %w", err)
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, fmt.Errorf("e: %w", err)
+	}
+	exp := new(big.Int).SetBytes(eb)
+	if len(nb) == 0 || !exp.IsInt64() || exp.Int64() < 2 || exp.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid RSA key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
+
+func ecJWK(crv, x, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var check ecdh.Curve
+	switch crv {
+	case "P-256":
+		curve, check = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, check = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, check = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported crv %q", crv)
+	}
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	yb, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, fmt.Errorf("y: %w", err)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(xb) != size || len(yb) != size {
+		return nil, fmt.Errorf("invalid %s point", crv)
+	}
+	// ecdh rejects points that are not on the curve.
+	if _, err := check.NewPublicKey(append(append([]byte{4}, xb...), yb...)); err != nil {
+		return nil, fmt.Errorf("invalid %s point: %w", crv, err)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(xb), Y: new(big.Int).SetBytes(yb)}, nil
+}
+
+func okpJWK(crv, x string) (ed25519.PublicKey, error) {
+	if crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported crv %q", crv)
+	}
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	if len(xb) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 key")
+	}
+	return ed25519.PublicKey(xb), nil
+}
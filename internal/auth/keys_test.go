@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPublicKeys(t *testing.T) {
+	ss := signers(t)
+	rsaPub := ss[0].key.Public().(*rsa.PublicKey)
+
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "idp"},
+		NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, ss[2].key.Public(), ss[2].key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkix1, err := x509.MarshalPKIXPublicKey(ss[1].key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := func(blocks ...*pem.Block) string {
+		var data []byte
+		for _, b := range blocks {
+			data = append(data, pem.EncodeToMemory(b)...)
+		}
+		path := filepath.Join(t.TempDir(), "keys.pem")
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	keys, err := LoadPublicKeys(file(
+		&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(rsaPub)},
+		&pem.Block{Type: "PUBLIC KEY", Bytes: pkix1},
+		&pem.Block{Type: "CERTIFICATE", Bytes: cert},
+		&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("skipped")},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("got %d keys, want 3", len(keys))
+	}
+	if _, ok := keys[2].(ed25519.PublicKey); !ok {
+		t.Errorf("certificate key is %T", keys[2])
+	}
+
+	if _, err := LoadPublicKeys(file(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("x")})); err == nil {
+		t.Error("file without public keys: want an error")
+	}
+	if _, err := LoadPublicKeys(file(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("garbage")})); err == nil {
+		t.Error("corrupt block: want an error")
+	}
+	if _, err := LoadPublicKeys(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Error("missing file: want an error")
+	}
+}
+
+func TestParseJWK(t *testing.T) {
+	ss := signers(t)
+	ec := ss[1].jwk()
+
+	tests := []struct {
+		name    string
+		jwk     map[string]string
+		wantErr string
+	}{
+		{name: "rsa", jwk: ss[0].jwk()},
+		{name: "ec", jwk: ec},
+		{name: "ed25519", jwk: ss[2].jwk()},
+		{name: "encryption key", jwk: with(ss[0].jwk(), "use", "enc"), wantErr: `use "enc"`},
+		{name: "unknown kty", jwk: map[string]string{"kty": "oct", "k": "c2VjcmV0"}, wantErr: "unsupported kty"},
+		{name: "unknown curve", jwk: with(ec, "crv", "P-192"), wantErr: "unsupported crv"},
+		{name: "point off the curve", jwk: with(ec, "y", ec["x"]), wantErr: "invalid P-256 point"},
+		{name: "short coordinate", jwk: with(ec, "x", "AQ"), wantErr: "invalid P-256 point"},
+		{name: "rsa exponent 1", jwk: with(ss[0].jwk(), "e", "AQ"), wantErr: "invalid RSA key"},
+		{name: "short ed25519 key", jwk: with(ss[2].jwk(), "x", "AQ"), wantErr: "invalid Ed25519 key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.jwk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			k, err := parseJWK(raw)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if k.kid != tt.jwk["kid"] || k.key == nil {
+					t.Errorf("got %+v", k)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// with returns a copy of m with key set to value.
+func with(m map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
@@ -0,0 +1,161 @@
+// Package bootstrap builds what every MCP needs before its own transports:
+// configuration, logging, tracing, metrics, readiness checks and the infra
+// HTTP routes. NATS-first services (cmd/modelo-mcp) register handlers on top
+// of it; HTTP-first services (main.go) mount their API on App.Router.
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"modelo-mcp/internal/auth"
+	"modelo-mcp/internal/config"
+	"modelo-mcp/internal/health"
+	"modelo-mcp/internal/log"
+	"modelo-mcp/internal/metrics"
+	"modelo-mcp/internal/otel"
+	httpx "modelo-mcp/internal/transport/http"
+	"modelo-mcp/internal/version"
+)
+
+const shutdownTimeout = 15 * time.Second
+
+// App is a bootstrapped service. Config is the configuration loaded at
+// startup; Watcher.Current reflects live reloads.
+type App struct {
+	Config  *config.Config
+	Watcher *config.Watcher
+	Logger  *slog.Logger
+	Metrics *prometheus.Registry
+	Health  *health.Registry
+	Router  chi.Router
+	// Auth verifies bearer tokens against the jwt configuration.
+	Auth *auth.Verifier
+
+	servers []*http.Server
+	errs    chan error
+	hooks   []func(context.Context) error
+}
+
+// New loads the configuration and sets up logging, tracing, metrics, the
+// readiness registry and the infra router (/healthz, /readyz, /info,
+// /metrics, /admin/config). The configuration is reloaded on file changes and
+// SIGHUP until ctx is done.
+func New(ctx context.Context) (*App, error) {
+	secrets := config.NewSecrets(0)
+	cfg, err := config.Load(secrets)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+
+	logger := log.New(cfg.Environment, cfg.ServiceName, cfg.LogLevel)
+	logger.Info("starting service", "service", cfg.ServiceName, "version", version.Version, "commit", version.Commit)
+
+	a := &App{Config: cfg, Logger: logger, errs: make(chan error, 2)}
+
+	tp, _, err := otel.SetupOTEL(ctx, cfg)
+	if err != nil {
+		logger.Error("OTEL setup failed", "error", err)
+	}
+	if tp != nil {
+		a.OnShutdown(tp.Shutdown)
+	}
+
+	a.Metrics = metrics.NewRegistry()
+	a.Health = health.NewRegistry(metrics.NewHealthMetrics(a.Metrics))
+
+	a.Watcher = config.NewWatcher(cfg, secrets, logger, metrics.NewConfigMetrics(a.Metrics))
+	a.Watcher.Subscribe(func(c *config.Config) { log.SetLevel(c.LogLevel) })
+	go a.Watcher.Run(ctx)
+
+	a.Auth, err = auth.NewVerifier(cfg.JWT, nil, logger)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: %w", err)
+	}
+
+	a.Router = httpx.Router(cfg, logger, a.Metrics, a.Health)
+	a.Router.With(httpx.AdminOnly(a.Auth)).Get("/admin/config", httpx.ConfigHandler(a.Watcher.Current))
+	return a, nil
+}
+
+// OnShutdown registers fn to run after the HTTP servers stopped. Hooks run in
+// reverse registration order, so later components stop before the ones they
+// depend on.
+func (a *App) OnShutdown(fn func(context.Context) error) {
+	a.hooks = append(a.hooks, fn)
+}
+
+// Start serves Router on http_port, and /metrics alone on metrics_port when
+// that differs. Routes must be mounted before Start.
+func (a *App) Start() {
+	cfg := a.Config
+	a.serve(&http.Server{
+		Addr:              ":" + cfg.HTTPPort,
+		Handler:           otelhttp.NewHandler(a.Router, cfg.ServiceName),
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      20 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	})
+	if cfg.MetricsPort != "" && cfg.MetricsPort != cfg.HTTPPort {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler(a.Metrics))
+		a.serve(&http.Server{
+			Addr:              ":" + cfg.MetricsPort,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		})
+	}
+}
+
+func (a *App) serve(srv *http.Server) {
+	a.servers = append(a.servers, srv)
+	go func() {
+		a.Logger.Info("http server listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.errs <- fmt.Errorf("http server %s: %w", srv.Addr, err)
+		}
+	}()
+}
+
+// Wait blocks until ctx is done or a server fails, then shuts the servers
+// down and runs the shutdown hooks. Pass a context from signal.NotifyContext
+// to stop on SIGINT/SIGTERM.
+func (a *App) Wait(ctx context.Context) error {
+	var runErr error
+	select {
+	case <-ctx.Done():
+		a.Logger.Info("shutdown signal received")
+	case runErr = <-a.errs:
+		a.Logger.Error("server failed", "error", runErr)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range a.servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			a.Logger.Error("http server shutdown", "addr", srv.Addr, "error", err)
+		}
+	}
+	for i := len(a.hooks) - 1; i >= 0; i-- {
+		if err := a.hooks[i](shutdownCtx); err != nil {
+			a.Logger.Error("shutdown hook failed", "error", err)
+		}
+	}
+	a.Logger.Info("bye")
+	return runErr
+}
+
+// Run is Start followed by Wait.
+func (a *App) Run(ctx context.Context) error {
+	a.Start()
+	return a.Wait(ctx)
+}
@@ -1,17 +1,25 @@
 package config
 
 import (
-	"os"
-	"strconv"
-	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+// Config is shared by every MCP entrypoint, NATS-first (cmd/modelo-mcp) and
+// HTTP-first (main.go) alike.
 type Config struct {
+	ServiceName string `mapstructure:"service_name"`
 	Environment string `mapstructure:"environment"`
 	HTTPPort    string `mapstructure:"http_port"`
 	MetricsPort string `mapstructure:"metrics_port"`
+	EnablePprof bool   `mapstructure:"enable_pprof"`
+
+	// Usually set by the profile block of the environment (development:,
+	// production:) in config.yaml.
+	Debug       bool   `mapstructure:"debug"`
+	LogLevel    string `mapstructure:"log_level"`
+	AutoMigrate bool   `mapstructure:"auto_migrate"`
 
 	Database   DatabaseConfig   `mapstructure:"database"`
 	ClickHouse ClickHouseConfig `mapstructure:"clickhouse"`
@@ -21,23 +29,72 @@ type Config struct {
 	Security   SecurityConfig   `mapstructure:"security"`
 	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
 	AI         AIConfig         `mapstructure:"ai"`
+	Outbox     OutboxConfig     `mapstructure:"outbox"`
+	OTEL       OTELConfig       `mapstructure:"otel"`
+	Secrets    SecretsConfig    `mapstructure:"secrets"`
 
 	// Service-specific configurations (to be customized per MCP)
 	{{SERVICE_CONFIG_NAME}} {{SERVICE_CONFIG_TYPE}} `mapstructure:"{{SERVICE_CONFIG_KEY}}"`
+
+	// sources maps every key to the layer that set it; see Effective.
+	sources map[string]string
+	// secretRefs maps keys resolved from secret:// references to them.
+	secretRefs map[string]string
 }
 
+// DatabaseConfig configures the PostgreSQL pool. Replicas, when set, serve
+// reads outside transactions; every pool uses the same limits.
 type DatabaseConfig struct {
-	URL             string `mapstructure:"url"`
-	MaxOpenConns    int    `mapstructure:"max_open_conns"`
-	MaxIdleConns    int    `mapstructure:"max_idle_conns"`
-	ConnMaxLifetime string `mapstructure:"conn_max_lifetime"`
+	URL                string        `mapstructure:"url"`
+	Replicas           []string      `mapstructure:"replicas"`
+	MaxOpenConns       int           `mapstructure:"max_open_conns"`
+	MaxIdleConns       int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime    time.Duration `mapstructure:"conn_max_lifetime"`
+	LogLevel           string        `mapstructure:"log_level"`
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold"`
+	StatsInterval      time.Duration `mapstructure:"stats_interval"`
+	// MigrationsPath reads migrations from a directory instead of the set
+	// embedded in the binary.
+	MigrationsPath string `mapstructure:"migrations_path"`
 }
 
+// ClickHouseConfig configures the analytics connection. URL is a
+// comma-separated list of host:port addresses or a clickhouse:// DSN; the
+// other fields override the DSN when set.
 type ClickHouseConfig struct {
-	URL      string `mapstructure:"url"`
-	Database string `mapstructure:"database"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
+	URL         string                `mapstructure:"url"`
+	Database    string                `mapstructure:"database"`
+	Username    string                `mapstructure:"username"`
+	Password    string                `mapstructure:"password"`
+	DialTimeout time.Duration         `mapstructure:"dial_timeout"`
+	ReadTimeout time.Duration         `mapstructure:"read_timeout"`
+	Compression string                `mapstructure:"compression"`
+	TLS         ClickHouseTLSConfig   `mapstructure:"tls"`
+	Batch       ClickHouseBatchConfig `mapstructure:"batch"`
+}
+
+// ClickHouseTLSConfig enables TLS, with a custom CA and a client certificate
+// when the files are set.
+type ClickHouseTLSConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+// ClickHouseBatchConfig tunes database.BatchWriter. Rows are inserted every
+// FlushInterval or as soon as Size are buffered; a failed insert is retried
+// MaxRetries times. Writers wait up to EnqueueTimeout when BufferSize rows
+// are already pending, after which the row is dropped.
+type ClickHouseBatchConfig struct {
+	Size           int           `mapstructure:"size"`
+	FlushInterval  time.Duration `mapstructure:"flush_interval"`
+	FlushTimeout   time.Duration `mapstructure:"flush_timeout"`
+	BufferSize     int           `mapstructure:"buffer_size"`
+	EnqueueTimeout time.Duration `mapstructure:"enqueue_timeout"`
+	MaxRetries     int           `mapstructure:"max_retries"`
+	RetryBackoff   time.Duration `mapstructure:"retry_backoff"`
 }
 
 type RedisConfig struct {
@@ -47,14 +104,176 @@ type RedisConfig struct {
 }
 
 type NATSConfig struct {
-	URL     string `mapstructure:"url"`
-	Cluster string `mapstructure:"cluster"`
+	URL            string             `mapstructure:"url"`
+	Cluster        string             `mapstructure:"cluster"`
+	Stream         string             `mapstructure:"stream"`
+	Durable        string             `mapstructure:"durable"`
+	Subjects       SubjectsConfig     `mapstructure:"subjects"`
+	RequestTimeout time.Duration      `mapstructure:"request_timeout"`
+	Retry          RetryConfig        `mapstructure:"retry"`
+	Consumers      ConsumerConfig     `mapstructure:"consumers"`
+	Idempotency    IdempotencyConfig  `mapstructure:"idempotency"`
+	Provisioning   ProvisioningConfig `mapstructure:"provisioning"`
+}
+
+// SubjectsConfig names the subjects of the example request handler. Reply is
+// an optional broadcast of every reply; callers get theirs on their inbox.
+type SubjectsConfig struct {
+	Request string `mapstructure:"request"`
+	Reply   string `mapstructure:"reply"`
+}
+
+// IdempotencyConfig selects where handlers that opt into deduplication keep
+// processed keys: "redis" (redis.url) or "kv" (a JetStream KV bucket). Lease
+// is how long a claimed key stays pending; zero uses the consumer's ack_wait,
+// and a shorter lease would let a redelivery process the message again.
+type IdempotencyConfig struct {
+	Backend     string        `mapstructure:"backend"`
+	TTL         time.Duration `mapstructure:"ttl"`
+	Lease       time.Duration `mapstructure:"lease"`
+	Bucket      string        `mapstructure:"bucket"`
+	RedisPrefix string        `mapstructure:"redis_prefix"`
+}
+
+// RetryConfig is the default redelivery policy for JetStream handlers.
+// Subjects overrides it for individual subjects (wildcards allowed); zero
+// fields in an override inherit the default. Jitter spreads every backoff
+// by up to that fraction either way, so messages that failed together are
+// not all retried at once.
+type RetryConfig struct {
+	MaxDeliver     int           `mapstructure:"max_deliver"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+	Multiplier     float64       `mapstructure:"multiplier"`
+	Jitter         float64       `mapstructure:"jitter"`
+
+	Subjects []SubjectRetryConfig `mapstructure:"subjects"`
+}
+
+type SubjectRetryConfig struct {
+	Subject        string        `mapstructure:"subject"`
+	MaxDeliver     int           `mapstructure:"max_deliver"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+	Multiplier     float64       `mapstructure:"multiplier"`
+}
+
+// ConsumerConfig shapes the JetStream consumers behind registered handlers.
+// In "pull" mode each subject is served by Workers goroutines fed by batched
+// fetches, with at most MaxInFlight messages being processed at once; "push"
+// keeps the single-callback subscription. Subjects overrides the sizing for
+// individual subjects, zero fields inherit the default.
+type ConsumerConfig struct {
+	Mode          string        `mapstructure:"mode"`
+	Workers       int           `mapstructure:"workers"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	MaxInFlight   int           `mapstructure:"max_in_flight"`
+	FetchWait     time.Duration `mapstructure:"fetch_wait"`
+	AckWait       time.Duration `mapstructure:"ack_wait"`
+	MaxAckPending int           `mapstructure:"max_ack_pending"`
+
+	Subjects []SubjectConsumerConfig `mapstructure:"subjects"`
+}
+
+type SubjectConsumerConfig struct {
+	Subject       string        `mapstructure:"subject"`
+	Workers       int           `mapstructure:"workers"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	MaxInFlight   int           `mapstructure:"max_in_flight"`
+	AckWait       time.Duration `mapstructure:"ack_wait"`
+	MaxAckPending int           `mapstructure:"max_ack_pending"`
+}
+
+// ProvisioningConfig declares the JetStream streams and consumers reconciled
+// at startup. With DryRun set, drift is only reported.
+type ProvisioningConfig struct {
+	DryRun  bool           `mapstructure:"dry_run"`
+	Streams []StreamConfig `mapstructure:"streams"`
+}
+
+// StreamConfig declares one stream. Fields left empty keep the server's value
+// (or its default on creation).
+type StreamConfig struct {
+	Name        string           `mapstructure:"name"`
+	Description string           `mapstructure:"description"`
+	Subjects    []string         `mapstructure:"subjects"`
+	Retention   string           `mapstructure:"retention"` // limits, interest or workqueue
+	Storage     string           `mapstructure:"storage"`   // file or memory
+	Replicas    int              `mapstructure:"replicas"`
+	MaxAge      time.Duration    `mapstructure:"max_age"`
+	MaxBytes    int64            `mapstructure:"max_bytes"`
+	MaxMsgs     int64            `mapstructure:"max_msgs"`
+	MaxMsgSize  int32            `mapstructure:"max_msg_size"`
+	Duplicates  time.Duration    `mapstructure:"duplicates"`
+	Discard     string           `mapstructure:"discard"` // old or new
+	Consumers   []StreamConsumer `mapstructure:"consumers"`
+}
+
+// StreamConsumer declares a durable pull consumer of a stream. Handlers bind
+// to it by durable name, so ack_wait and max_ack_pending must agree with
+// nats.consumers.
+type StreamConsumer struct {
+	Durable       string        `mapstructure:"durable"`
+	Description   string        `mapstructure:"description"`
+	FilterSubject string        `mapstructure:"filter_subject"`
+	DeliverPolicy string        `mapstructure:"deliver_policy"` // all, new or last
+	AckPolicy     string        `mapstructure:"ack_policy"`     // explicit, all or none
+	AckWait       time.Duration `mapstructure:"ack_wait"`
+	MaxDeliver    int           `mapstructure:"max_deliver"`
+	MaxAckPending int           `mapstructure:"max_ack_pending"`
+}
+
+// OutboxConfig drives the relay that publishes outbox_events rows to
+// JetStream.
+type OutboxConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	Retention    time.Duration `mapstructure:"retention"`
+	// MaxAttempts is how often an event is published before it is parked
+	// with failed_at set, letting the events behind it through.
+	MaxAttempts int `mapstructure:"max_attempts"`
 }
 
+// OTELConfig configures trace export. Tracing is disabled when
+// ExporterEndpoint is empty; trace context is still propagated.
+type OTELConfig struct {
+	ExporterEndpoint string `mapstructure:"exporter_endpoint"`
+}
+
+// SecretsConfig configures how secret://<provider>/<path>[#<key>] values are
+// resolved. The file and env providers are always available; vault is
+// enabled by Vault.Address.
+type SecretsConfig struct {
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	Vault    VaultConfig   `mapstructure:"vault"`
+}
+
+// VaultConfig points the vault provider at a Vault server's HTTP API. Token
+// may itself be a secret://file or secret://env reference.
+type VaultConfig struct {
+	Address   string        `mapstructure:"address"`
+	Token     string        `mapstructure:"token"`
+	Namespace string        `mapstructure:"namespace"`
+	KVVersion int           `mapstructure:"kv_version"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+}
+
+// JWTConfig configures bearer token verification. Secret verifies HMAC
+// tokens; PublicKeyFile and JWKSURL supply the keys of RS*, PS*, ES* and
+// EdDSA tokens. Issuer and Audience, when set, must match the iss and aud
+// claims, and Leeway is the clock skew allowed on exp, nbf and iat.
 type JWTConfig struct {
 	Secret   string `mapstructure:"secret"`
 	Issuer   string `mapstructure:"issuer"`
 	Audience string `mapstructure:"audience"`
+	// Algorithms lists the accepted alg values. Empty accepts HS* when
+	// Secret is set and the asymmetric ones when keys are configured.
+	Algorithms    []string      `mapstructure:"algorithms"`
+	PublicKeyFile string        `mapstructure:"public_key_file"`
+	JWKSURL       string        `mapstructure:"jwks_url"`
+	JWKSRefresh   time.Duration `mapstructure:"jwks_refresh"`
+	Leeway        time.Duration `mapstructure:"leeway"`
 }
 
 type SecurityConfig struct {
@@ -62,10 +281,46 @@ type SecurityConfig struct {
 	APIKey         string   `mapstructure:"api_key"`
 }
 
+// RateLimitConfig configures middleware.RateLimiter. RPS and Burst are the
+// default policy; Policies override them for matching requests. Distributed
+// shares the limits of every replica through Redis.
 type RateLimitConfig struct {
-	Enabled bool `mapstructure:"enabled"`
-	RPS     int  `mapstructure:"rps"`
-	Burst   int  `mapstructure:"burst"`
+	Enabled     bool              `mapstructure:"enabled"`
+	RPS         int               `mapstructure:"rps"`
+	Burst       int               `mapstructure:"burst"`
+	Distributed bool              `mapstructure:"distributed"`
+	RedisPrefix string            `mapstructure:"redis_prefix"`
+	Policies    []RateLimitPolicy `mapstructure:"policies"`
+	// Allowlist and Denylist hold IPs and CIDRs that are never limited or
+	// always rejected.
+	Allowlist []string           `mapstructure:"allowlist"`
+	Denylist  []string           `mapstructure:"denylist"`
+	Ban       RateLimitBanConfig `mapstructure:"ban"`
+	// IdleTTL evicts in-memory limiters unused for that long.
+	IdleTTL time.Duration `mapstructure:"idle_ttl"`
+}
+
+// RateLimitPolicy applies RPS and Burst to the requests matching every
+// selector set: the tenant, the plan claim of the token, a path prefix
+// (Route) and the X-API-Key header. The policy with the most selectors set
+// wins, the first one listed on ties. Requests matching APIKey are limited
+// per key, the others per tenant.
+type RateLimitPolicy struct {
+	Name   string `mapstructure:"name"`
+	Tenant string `mapstructure:"tenant"`
+	Plan   string `mapstructure:"plan"`
+	Route  string `mapstructure:"route"`
+	APIKey string `mapstructure:"api_key"`
+	RPS    int    `mapstructure:"rps"`
+	Burst  int    `mapstructure:"burst"`
+}
+
+// RateLimitBanConfig bans a client IP for Duration after Threshold rejected
+// requests within Window. A zero Threshold disables bans.
+type RateLimitBanConfig struct {
+	Threshold int           `mapstructure:"threshold"`
+	Window    time.Duration `mapstructure:"window"`
+	Duration  time.Duration `mapstructure:"duration"`
 }
 
 type AIConfig struct {
@@ -76,104 +331,151 @@ type AIConfig struct {
 	BaseURL  string `mapstructure:"base_url"`
 }
 
-func Load() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath("./configs")
-	viper.AddConfigPath(".")
+// Load builds the configuration from these layers, each overriding the
+// previous one, and validates the result:
+//
+//  1. defaults
+//  2. configs/config.yaml (or ./config.yaml)
+//  3. configs/config.<profile>.yaml
+//  4. the <profile>: block of the files above
+//  5. MCP_* environment variables, then their deprecated aliases
+//
+// Values of the form secret://<provider>/<path>[#<key>] are then replaced by
+// the secret they reference, resolved and cached by secrets; see
+// SecretProvider. Reloads pass the same Secrets to reuse cached values.
+// The profile is the environment, with dev/local read as development and prod
+// as production. Every key can be set from the environment as
+// MCP_<SECTION>_<FIELD>, e.g. MCP_NATS_URL; see docs/configuration.md.
+func Load(secrets *Secrets) (*Config, error) {
+	v := viper.New()
+	src := sources{}
 
 	// Set defaults
-	setDefaults()
+	setDefaults(v)
+	src.setAll(v.AllKeys(), "default")
 
 	// Read environment variables
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	bindEnv(v)
+	legacy := applyLegacyEnv(v)
 
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, err
-		}
+	if err := mergeFile(v, "config", src); err != nil {
+		return nil, err
+	}
+	profile := profileName(v.GetString("environment"))
+	if err := mergeFile(v, "config."+profile, src); err != nil {
+		return nil, err
 	}
+	if err := mergeProfile(v, profile, src); err != nil {
+		return nil, err
+	}
+	src.setEnv(legacy)
 
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, err
+	}
+	config.sources = src
+	if err := resolveSecrets(&config, secrets); err != nil {
 		return nil, err
 	}
 
-	// Override with environment variables
-	overrideWithEnv(&config)
-
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
-func setDefaults() {
-	viper.SetDefault("environment", "development")
-	viper.SetDefault("http_port", "8080")
-	viper.SetDefault("metrics_port", "9090")
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("service_name", "modelo-mcp")
+	v.SetDefault("environment", "development")
+	v.SetDefault("http_port", "8080")
+	v.SetDefault("metrics_port", "9090")
+	v.SetDefault("enable_pprof", false)
+	v.SetDefault("debug", false)
+	v.SetDefault("log_level", "info")
+	v.SetDefault("auto_migrate", true)
 
 	// Database defaults
-	viper.SetDefault("database.max_open_conns", 25)
-	viper.SetDefault("database.max_idle_conns", 25)
-	viper.SetDefault("database.conn_max_lifetime", "5m")
+	v.SetDefault("database.max_open_conns", 25)
+	v.SetDefault("database.max_idle_conns", 25)
+	v.SetDefault("database.conn_max_lifetime", "5m")
+	v.SetDefault("database.log_level", "warn")
+	v.SetDefault("database.slow_query_threshold", "200ms")
+	v.SetDefault("database.stats_interval", "15s")
+
+	// ClickHouse defaults
+	v.SetDefault("clickhouse.dial_timeout", "5s")
+	v.SetDefault("clickhouse.read_timeout", "30s")
+	v.SetDefault("clickhouse.compression", "lz4")
+	v.SetDefault("clickhouse.batch.size", 1000)
+	v.SetDefault("clickhouse.batch.flush_interval", "1s")
+	v.SetDefault("clickhouse.batch.flush_timeout", "10s")
+	v.SetDefault("clickhouse.batch.buffer_size", 10000)
+	v.SetDefault("clickhouse.batch.enqueue_timeout", "100ms")
+	v.SetDefault("clickhouse.batch.max_retries", 3)
+	v.SetDefault("clickhouse.batch.retry_backoff", "500ms")
 
 	// Redis defaults
-	viper.SetDefault("redis.db", 0)
-	viper.SetDefault("redis.max_retries", 3)
+	v.SetDefault("redis.db", 0)
+	v.SetDefault("redis.max_retries", 3)
+
+	// NATS defaults
+	v.SetDefault("nats.url", "nats://localhost:4222")
+	v.SetDefault("nats.stream", "MCP_MODELO")
+	v.SetDefault("nats.durable", "modelo-consumer")
+	v.SetDefault("nats.subjects.request", "mcp.modelo.example.request")
+	v.SetDefault("nats.subjects.reply", "mcp.modelo.example.reply")
+	v.SetDefault("nats.retry.max_deliver", 5)
+	v.SetDefault("nats.retry.initial_backoff", "1s")
+	v.SetDefault("nats.retry.max_backoff", "1m")
+	v.SetDefault("nats.retry.multiplier", 2.0)
+	v.SetDefault("nats.retry.jitter", 0.2)
+	v.SetDefault("nats.request_timeout", "30s")
+	v.SetDefault("nats.consumers.mode", "pull")
+	v.SetDefault("nats.consumers.workers", 4)
+	v.SetDefault("nats.consumers.batch_size", 10)
+	v.SetDefault("nats.consumers.max_in_flight", 40)
+	v.SetDefault("nats.consumers.fetch_wait", "5s")
+	v.SetDefault("nats.consumers.ack_wait", "30s")
+	v.SetDefault("nats.consumers.max_ack_pending", 1000)
+	v.SetDefault("nats.idempotency.backend", "kv")
+	v.SetDefault("nats.idempotency.ttl", "24h")
+	v.SetDefault("nats.idempotency.lease", "0s")
+	v.SetDefault("nats.idempotency.bucket", "IDEMPOTENCY")
+	v.SetDefault("nats.idempotency.redis_prefix", "idem:")
+
+	// Outbox defaults
+	v.SetDefault("outbox.enabled", false)
+	v.SetDefault("outbox.poll_interval", "1s")
+	v.SetDefault("outbox.batch_size", 100)
+	v.SetDefault("outbox.retention", "168h")
+	v.SetDefault("outbox.max_attempts", 10)
+
+	// JWT defaults
+	v.SetDefault("jwt.jwks_refresh", "15m")
+	v.SetDefault("jwt.leeway", "30s")
 
 	// Security defaults
-	viper.SetDefault("security.allowed_origins", []string{"http://localhost:3000"})
+	v.SetDefault("security.allowed_origins", []string{"http://localhost:3000"})
 
 	// Rate limit defaults
-	viper.SetDefault("rate_limit.enabled", true)
-	viper.SetDefault("rate_limit.rps", 100)
-	viper.SetDefault("rate_limit.burst", 200)
+	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("rate_limit.rps", 100)
+	v.SetDefault("rate_limit.burst", 200)
+	v.SetDefault("rate_limit.distributed", false)
+	v.SetDefault("rate_limit.redis_prefix", "rl:")
+	v.SetDefault("rate_limit.idle_ttl", "10m")
+	v.SetDefault("rate_limit.ban.threshold", 0)
+	v.SetDefault("rate_limit.ban.window", "1m")
+	v.SetDefault("rate_limit.ban.duration", "1h")
+
+	// Secrets defaults
+	v.SetDefault("secrets.cache_ttl", "5m")
+	v.SetDefault("secrets.vault.kv_version", 2)
+	v.SetDefault("secrets.vault.timeout", "5s")
 
 	// AI defaults
-	viper.SetDefault("ai.enabled", true)
-	viper.SetDefault("ai.provider", "openai")
-	viper.SetDefault("ai.model", "gpt-4")
-}
-
-func overrideWithEnv(config *Config) {
-	if port := os.Getenv("PORT"); port != "" {
-		config.HTTPPort = port
-	}
-
-	if env := os.Getenv("ENVIRONMENT"); env != "" {
-		config.Environment = env
-	}
-
-	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
-		config.Database.URL = dbURL
-	}
-
-	if clickhouseURL := os.Getenv("CLICKHOUSE_URL"); clickhouseURL != "" {
-		config.ClickHouse.URL = clickhouseURL
-	}
-
-	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
-		config.Redis.URL = redisURL
-	}
-
-	if natsURL := os.Getenv("NATS_URL"); natsURL != "" {
-		config.NATS.URL = natsURL
-	}
-
-	if jwtSecret := os.Getenv("JWT_SECRET"); jwtSecret != "" {
-		config.JWT.Secret = jwtSecret
-	}
-
-	if apiKey := os.Getenv("API_KEY"); apiKey != "" {
-		config.Security.APIKey = apiKey
-	}
-
-	if aiAPIKey := os.Getenv("AI_API_KEY"); aiAPIKey != "" {
-		config.AI.APIKey = aiAPIKey
-	}
-
-	if rpsStr := os.Getenv("RATE_LIMIT_RPS"); rpsStr != "" {
-		if rps, err := strconv.Atoi(rpsStr); err == nil {
-			config.RateLimit.RPS = rps
-		}
-	}
+	v.SetDefault("ai.enabled", true)
+	v.SetDefault("ai.provider", "openai")
+	v.SetDefault("ai.model", "gpt-4")
 }
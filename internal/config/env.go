@@ -0,0 +1,144 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes every configuration environment variable.
+const EnvPrefix = "MCP"
+
+// legacyEnv maps keys to the unprefixed variables read before MCP_ was
+// honoured. They still work but log a deprecation warning; the first one set
+// wins, and the MCP_ variable wins over all of them.
+var legacyEnv = map[string][]string{
+	"service_name":              {"SERVICE_NAME"},
+	"environment":               {"ENVIRONMENT", "ENV"},
+	"http_port":                 {"PORT", "HTTP_PORT"},
+	"metrics_port":              {"METRICS_PORT"},
+	"enable_pprof":              {"ENABLE_PPROF"},
+	"database.url":              {"DATABASE_URL"},
+	"database.migrations_path":  {"DB_MIGRATIONS_PATH"},
+	"clickhouse.url":            {"CLICKHOUSE_URL"},
+	"redis.url":                 {"REDIS_URL"},
+	"nats.url":                  {"NATS_URL"},
+	"nats.stream":               {"NATS_JS_STREAM"},
+	"nats.durable":              {"NATS_DURABLE"},
+	"nats.subjects.request":     {"SUBJECT_REQUEST"},
+	"nats.subjects.reply":       {"SUBJECT_REPLY"},
+	"nats.request_timeout":      {"NATS_REQUEST_TIMEOUT"},
+	"nats.provisioning.dry_run": {"NATS_PROVISION_DRY_RUN"},
+	"otel.exporter_endpoint":    {"OTEL_EXPORTER_OTLP_ENDPOINT"},
+	"jwt.secret":                {"JWT_SECRET"},
+	"security.api_key":          {"API_KEY"},
+	"ai.api_key":                {"AI_API_KEY"},
+	"rate_limit.rps":            {"RATE_LIMIT_RPS"},
+	"rate_limit.distributed":    {"RATE_LIMIT_DISTRIBUTED"},
+	"rate_limit.redis_prefix":   {"RATE_LIMIT_REDIS_PREFIX"},
+	"rate_limit.allowlist":      {"RATE_LIMIT_WHITELIST"},
+	"rate_limit.ban.duration":   {"RATE_LIMIT_BLACKLIST_DURATION"},
+}
+
+// Key describes one configuration key for the generated reference.
+type Key struct {
+	Path       string
+	Env        string // empty for lists of objects, which are YAML-only
+	Type       string
+	Default    any
+	Aliases    []string
+	Reloadable bool
+}
+
+// EnvName returns the environment variable for a key path.
+func EnvName(path string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// Keys lists every configuration key, sorted by path.
+func Keys() []Key {
+	defaults := viper.New()
+	setDefaults(defaults)
+
+	var keys []Key
+	walkKeys(reflect.TypeOf(Config{}), "", func(path string, t reflect.Type) {
+		k := Key{Path: path, Type: typeName(t), Default: defaults.Get(path), Aliases: legacyEnv[path], Reloadable: IsReloadable(path)}
+		if !(t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct) {
+			k.Env = EnvName(path)
+		}
+		keys = append(keys, k)
+	})
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Path < keys[j].Path })
+	return keys
+}
+
+func walkKeys(t reflect.Type, prefix string, fn func(path string, t reflect.Type)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("mapstructure"), ",")[0]
+		if name == "" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}) {
+			walkKeys(f.Type, name, fn)
+			continue
+		}
+		fn(name, f.Type)
+	}
+}
+
+func typeName(t reflect.Type) string {
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		return "duration"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct:
+		return "list"
+	case t.Kind() == reflect.Slice:
+		return "[]" + t.Elem().Kind().String() + " (comma-separated)"
+	}
+	return t.Kind().String()
+}
+
+// bindEnv makes every key readable from its MCP_ variable, including keys
+// that have no default and are absent from the YAML file.
+func bindEnv(v *viper.Viper) {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	for _, k := range Keys() {
+		if k.Env != "" {
+			_ = v.BindEnv(k.Path, k.Env)
+		}
+	}
+}
+
+// applyLegacyEnv copies deprecated variables into v unless the MCP_ variable
+// of the key is set, and returns the variable used for each key.
+func applyLegacyEnv(v *viper.Viper) map[string]string {
+	used := map[string]string{}
+	for path, names := range legacyEnv {
+		if os.Getenv(EnvName(path)) != "" {
+			continue
+		}
+		for _, name := range names {
+			if val, ok := os.LookupEnv(name); ok && val != "" {
+				slog.Warn("deprecated environment variable", "name", name, "use", EnvName(path))
+				v.Set(path, val)
+				used[path] = name
+				break
+			}
+		}
+	}
+	return used
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv blanks every MCP_ variable and deprecated alias for the test, so
+// the calling environment cannot leak into Load.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, k := range Keys() {
+		if k.Env != "" {
+			t.Setenv(k.Env, "")
+		}
+		for _, a := range k.Aliases {
+			t.Setenv(a, "")
+		}
+	}
+}
+
+// loadFrom runs Load reading config files from dir only.
+func loadFrom(t *testing.T, dir string) (*Config, error) {
+	t.Helper()
+	old := configDirs
+	configDirs = []string{dir}
+	t.Cleanup(func() { configDirs = old })
+	return Load(NewSecrets(0))
+}
+
+func mustLoad(t *testing.T, dir string) *Config {
+	t.Helper()
+	cfg, err := loadFrom(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func sourceOf(cfg *Config, key string) string {
+	for _, s := range cfg.Effective() {
+		if s.Key == key {
+			return s.Source
+		}
+	}
+	return ""
+}
+
+func TestEnvName(t *testing.T) {
+	for path, want := range map[string]string{
+		"http_port":                       "MCP_HTTP_PORT",
+		"nats.subjects.request":           "MCP_NATS_SUBJECTS_REQUEST",
+		"rate_limit.ban.duration":         "MCP_RATE_LIMIT_BAN_DURATION",
+		"secrets.vault.kv_version":        "MCP_SECRETS_VAULT_KV_VERSION",
+		"clickhouse.batch.flush_interval": "MCP_CLICKHOUSE_BATCH_FLUSH_INTERVAL",
+	} {
+		if got := EnvName(path); got != want {
+			t.Errorf("EnvName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	keys := map[string]Key{}
+	for _, k := range Keys() {
+		keys[k.Path] = k
+	}
+	for _, path := range []string{"rate_limit.policies", "nats.provisioning.streams"} {
+		if k, ok := keys[path]; !ok || k.Env != "" || k.Type != "list" {
+			t.Errorf("%s = %+v, want a YAML-only list", path, k)
+		}
+	}
+	if k := keys["nats.consumers.ack_wait"]; k.Env != "MCP_NATS_CONSUMERS_ACK_WAIT" || k.Type != "duration" || k.Default != "30s" {
+		t.Errorf("nats.consumers.ack_wait = %+v", k)
+	}
+	if k := keys["environment"]; !reflect.DeepEqual(k.Aliases, []string{"ENVIRONMENT", "ENV"}) {
+		t.Errorf("environment aliases = %v", k.Aliases)
+	}
+	for path := range legacyEnv {
+		if _, ok := keys[path]; !ok {
+			t.Errorf("legacy alias for unknown key %q", path)
+		}
+	}
+}
+
+func TestLoadFromMCPEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("MCP_JWT_SECRET", "dev-secret")
+	t.Setenv("MCP_HTTP_PORT", "8181")
+	t.Setenv("MCP_NATS_SUBJECTS_REQUEST", "orders.request")
+	t.Setenv("MCP_DATABASE_MAX_OPEN_CONNS", "50")
+	t.Setenv("MCP_NATS_IDEMPOTENCY_TTL", "90m")
+	t.Setenv("MCP_RATE_LIMIT_ALLOWLIST", "10.0.0.1,10.0.0.0/8")
+	// No default and not in any file.
+	t.Setenv("MCP_CLICKHOUSE_URL", "clickhouse://ch:9000/events")
+	// Unprefixed names of MCP_ keys are not read.
+	t.Setenv("LOG_LEVEL", "debug")
+
+	cfg := mustLoad(t, t.TempDir())
+	if cfg.HTTPPort != "8181" || cfg.NATS.Subjects.Request != "orders.request" || cfg.Database.MaxOpenConns != 50 ||
+		cfg.NATS.Idempotency.TTL != 90*time.Minute || cfg.ClickHouse.URL != "clickhouse://ch:9000/events" {
+		t.Errorf("config = %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.RateLimit.Allowlist, []string{"10.0.0.1", "10.0.0.0/8"}) {
+		t.Errorf("allowlist = %q", cfg.RateLimit.Allowlist)
+	}
+	if cfg.LogLevel != "info" {
+		t.Errorf("log_level = %q, want the default", cfg.LogLevel)
+	}
+	for key, want := range map[string]string{
+		"http_port":      "env:MCP_HTTP_PORT",
+		"clickhouse.url": "env:MCP_CLICKHOUSE_URL",
+		"log_level":      "default",
+	} {
+		if got := sourceOf(cfg, key); got != want {
+			t.Errorf("source of %s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestLoadLegacyEnv(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		key    string
+		get    func(*Config) string
+		want   string
+		source string
+		warned string
+	}{
+		{
+			name:   "alias",
+			env:    map[string]string{"DATABASE_URL": "postgres://db/legacy"},
+			key:    "database.url",
+			get:    func(c *Config) string { return c.Database.URL },
+			want:   "postgres://db/legacy",
+			source: "env:DATABASE_URL (deprecated)",
+			warned: "DATABASE_URL",
+		},
+		{
+			name:   "MCP_ wins over the alias",
+			env:    map[string]string{"DATABASE_URL": "postgres://db/legacy", "MCP_DATABASE_URL": "postgres://db/new"},
+			key:    "database.url",
+			get:    func(c *Config) string { return c.Database.URL },
+			want:   "postgres://db/new",
+			source: "env:MCP_DATABASE_URL",
+		},
+		{
+			name:   "first alias wins",
+			env:    map[string]string{"PORT": "7000", "HTTP_PORT": "7001"},
+			key:    "http_port",
+			get:    func(c *Config) string { return c.HTTPPort },
+			want:   "7000",
+			source: "env:PORT (deprecated)",
+			warned: "PORT",
+		},
+		{
+			name:   "empty alias is skipped",
+			env:    map[string]string{"ENVIRONMENT": "", "ENV": "local"},
+			key:    "environment",
+			get:    func(c *Config) string { return c.Environment },
+			want:   "local",
+			source: "env:ENV (deprecated)",
+			warned: "ENV",
+		},
+		{
+			name:   "nested key",
+			env:    map[string]string{"NATS_JS_STREAM": "LEGACY"},
+			key:    "nats.stream",
+			get:    func(c *Config) string { return c.NATS.Stream },
+			want:   "LEGACY",
+			source: "env:NATS_JS_STREAM (deprecated)",
+			warned: "NATS_JS_STREAM",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("MCP_JWT_SECRET", "dev-secret")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var logs bytes.Buffer
+			old := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+			defer slog.SetDefault(old)
+
+			cfg := mustLoad(t, t.TempDir())
+			if got := tt.get(cfg); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.key, got, tt.want)
+			}
+			if got := sourceOf(cfg, tt.key); got != tt.source {
+				t.Errorf("source = %q, want %q", got, tt.source)
+			}
+			warned := strings.Contains(logs.String(), "deprecated environment variable")
+			if tt.warned == "" && warned {
+				t.Errorf("logs = %q, want no deprecation warning", logs.String())
+			}
+			if tt.warned != "" && !strings.Contains(logs.String(), "name="+tt.warned+" ") {
+				t.Errorf("logs = %q, want a warning for %s", logs.String(), tt.warned)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// configDirs are searched in order for config files.
+var configDirs = []string{"./configs", "."}
+
+// profileName maps an environment to the profile whose file and block apply.
+func profileName(env string) string {
+	switch env {
+	case "dev", "local":
+		return "development"
+	case "prod":
+		return "production"
+	}
+	return env
+}
+
+// mergeFile merges the first <dir>/<name>.yaml found into v. A missing file
+// is not an error.
+func mergeFile(v *viper.Viper, name string, src sources) error {
+	path := findFile(name)
+	if path == "" {
+		return nil
+	}
+	layer := viper.New()
+	layer.SetConfigFile(path)
+	if err := layer.ReadInConfig(); err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := v.MergeConfigMap(layer.AllSettings()); err != nil {
+		return fmt.Errorf("merge %s: %w", path, err)
+	}
+	src.setAll(layer.AllKeys(), "file:"+path)
+	return nil
+}
+
+func findFile(name string) string {
+	for _, dir := range configDirs {
+		for _, ext := range []string{".yaml", ".yml"} {
+			path := filepath.Join(dir, name+ext)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// mergeProfile applies the <profile>: block of the merged files on top of
+// them, e.g. development.log_level over log_level.
+func mergeProfile(v *viper.Viper, profile string, src sources) error {
+	block := v.Sub(profile)
+	if block == nil {
+		return nil
+	}
+	if err := v.MergeConfigMap(block.AllSettings()); err != nil {
+		return fmt.Errorf("apply %s profile: %w", profile, err)
+	}
+	src.setAll(block.AllKeys(), "profile:"+profile)
+	return nil
+}
+
+// sources maps keys to the layer that last set them: "default",
+// "file:<path>", "profile:<name>" or "env:<variable>".
+type sources map[string]string
+
+func (s sources) setAll(keys []string, source string) {
+	for _, k := range keys {
+		s[k] = source
+	}
+}
+
+// setEnv records the keys set from the environment; legacy maps keys to the
+// deprecated variable applied for them.
+func (s sources) setEnv(legacy map[string]string) {
+	for _, k := range Keys() {
+		if k.Env != "" && os.Getenv(k.Env) != "" {
+			s[k.Path] = "env:" + k.Env
+		}
+	}
+	for path, name := range legacy {
+		s[path] = "env:" + name + " (deprecated)"
+	}
+}
+
+// Setting is one key of the effective configuration.
+type Setting struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// Effective lists every key with its value and the layer that set it, sorted
+// by key. Secrets, including every value resolved from a secret:// reference,
+// are redacted and URLs lose their password.
+func (c *Config) Effective() []Setting {
+	var out []Setting
+	walkValues(reflect.ValueOf(c).Elem(), "", func(path string, v reflect.Value) {
+		src := c.sources[path]
+		if src == "" {
+			src = "unset"
+		}
+		value := redact(path, plain(v))
+		if ref, ok := c.secretRefs[path]; ok {
+			src += " via " + ref
+			value = "<redacted>"
+		}
+		out = append(out, Setting{Key: path, Value: value, Source: src})
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// walkValues calls fn for every key of the struct v, descending into nested
+// sections.
+func walkValues(v reflect.Value, prefix string, fn func(path string, v reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("mapstructure"), ",")[0]
+		if name == "" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}) {
+			walkValues(v.Field(i), name, fn)
+			continue
+		}
+		fn(name, v.Field(i))
+	}
+}
+
+// plain converts v to maps, slices and scalars named like the YAML keys, so
+// it prints the way it is configured.
+func plain(v reflect.Value) any {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(v.Int()).String()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		m := map[string]any{}
+		walkValues(v, "", func(path string, f reflect.Value) { m[path] = plain(f) })
+		return m
+	case reflect.Slice, reflect.Array:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = plain(v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		m := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = plain(iter.Value())
+		}
+		return m
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return plain(v.Elem())
+	}
+	return v.Interface()
+}
+
+func redact(path string, value any) any {
+	switch v := value.(type) {
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = redact(path, item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = redact(path+"."+k, item)
+		}
+		return out
+	}
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return value
+	}
+	if isURLPath(path) {
+		return redactURL(s)
+	}
+	if isSecretPath(path) {
+		return "<redacted>"
+	}
+	return s
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadLayers(t *testing.T) {
+	const base = `
+environment: local
+service_name: base
+log_level: warn
+http_port: "8001"
+metrics_port: "9001"
+development:
+  http_port: "8004"
+production:
+  http_port: "8005"
+`
+	tests := []struct {
+		name  string
+		files map[string]string
+		env   map[string]string
+		// want maps keys to "<value> from <source>"; $DIR is the config
+		// directory.
+		want map[string]string
+	}{
+		{
+			name: "no files",
+			want: map[string]string{
+				"service_name": "modelo-mcp from default",
+				"http_port":    "8080 from default",
+				"debug":        "false from default",
+			},
+		},
+		{
+			name:  "base only",
+			files: map[string]string{"config.yaml": base},
+			want: map[string]string{
+				"environment":  "local from file:$DIR/config.yaml",
+				"service_name": "base from file:$DIR/config.yaml",
+				"metrics_port": "9001 from file:$DIR/config.yaml",
+				// local reads the development profile.
+				"http_port": "8004 from profile:development",
+				"nats.url":  "nats://localhost:4222 from default",
+			},
+		},
+		{
+			name: "base, profile file, profile block, env",
+			files: map[string]string{
+				"config.yaml": base,
+				"config.development.yaml": `
+service_name: profile-file
+log_level: error
+http_port: "8002"
+development:
+  debug: true
+`,
+			},
+			env: map[string]string{"MCP_LOG_LEVEL": "debug"},
+			want: map[string]string{
+				"metrics_port": "9001 from file:$DIR/config.yaml",
+				"service_name": "profile-file from file:$DIR/config.development.yaml",
+				// Blocks of both files beat the profile file.
+				"http_port": "8004 from profile:development",
+				"debug":     "true from profile:development",
+				"log_level": "debug from env:MCP_LOG_LEVEL",
+			},
+		},
+		{
+			name:  "profile file without base",
+			files: map[string]string{"config.development.yml": "service_name: only-profile\n"},
+			want: map[string]string{
+				"service_name": "only-profile from file:$DIR/config.development.yml",
+				"http_port":    "8080 from default",
+			},
+		},
+		{
+			name: "environment from env picks the profile",
+			files: map[string]string{
+				"config.yaml":             base,
+				"config.staging.yaml":     "service_name: staging\n",
+				"config.development.yaml": "service_name: development\n",
+			},
+			env: map[string]string{"MCP_ENVIRONMENT": "staging", "MCP_JWT_SECRET": strings.Repeat("s", 32), "MCP_AI_API_KEY": "sk-staging"},
+			want: map[string]string{
+				"environment":  "staging from env:MCP_ENVIRONMENT",
+				"service_name": "staging from file:$DIR/config.staging.yaml",
+				// No staging: block, and other blocks do not apply.
+				"http_port": "8001 from file:$DIR/config.yaml",
+			},
+		},
+		{
+			name:  "prod reads the production profile",
+			files: map[string]string{"config.yaml": strings.Replace(base, "environment: local", "environment: prod", 1)},
+			env:   map[string]string{"MCP_JWT_SECRET": strings.Repeat("s", 32), "MCP_AI_API_KEY": "sk-prod"},
+			want: map[string]string{
+				"http_port": "8005 from profile:production",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("MCP_JWT_SECRET", "dev-secret")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			dir := writeFiles(t, tt.files)
+			cfg := mustLoad(t, dir)
+
+			got := map[string]string{}
+			for _, s := range cfg.Effective() {
+				got[s.Key] = fmt.Sprintf("%v from %s", s.Value, s.Source)
+			}
+			for key, want := range tt.want {
+				want = strings.ReplaceAll(want, "$DIR", dir)
+				if got[key] != want {
+					t.Errorf("%s = %s, want %s", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadLayersInvalidFile(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("MCP_JWT_SECRET", "dev-secret")
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":             "service_name: ok\n",
+		"config.development.yaml": "http_port: [8080\n",
+	})
+	_, err := loadFrom(t, dir)
+	if err == nil || !strings.Contains(err.Error(), "read "+filepath.Join(dir, "config.development.yaml")) {
+		t.Fatalf("err = %v, want the broken file named", err)
+	}
+}
+
+func TestFindFileSearchesDirsInOrder(t *testing.T) {
+	first := writeFiles(t, map[string]string{"config.yml": ""})
+	second := writeFiles(t, map[string]string{"config.yaml": "", "config.test.yaml": ""})
+	old := configDirs
+	configDirs = []string{first, second}
+	t.Cleanup(func() { configDirs = old })
+
+	for name, want := range map[string]string{
+		"config":      filepath.Join(first, "config.yml"),
+		"config.test": filepath.Join(second, "config.test.yaml"),
+		"config.prod": "",
+	} {
+		if got := findFile(name); got != want {
+			t.Errorf("findFile(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretScheme prefixes config values that reference a secret.
+const SecretScheme = "secret://"
+
+// ErrSecretNotFound is returned by providers when nothing exists at a path or
+// key.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider resolves the references of one provider, e.g. "vault" in
+// secret://vault/kv/mcp#jwt.
+type SecretProvider interface {
+	// Secret returns the secret at path, or its field key when key is set.
+	Secret(ctx context.Context, path, key string) (string, error)
+}
+
+// SecretRef is a parsed secret://<provider>/<path>[#<key>] reference.
+type SecretRef struct {
+	Provider string
+	Path     string
+	Key      string
+}
+
+func (r SecretRef) String() string {
+	s := SecretScheme + r.Provider + "/" + r.Path
+	if r.Key != "" {
+		s += "#" + r.Key
+	}
+	return s
+}
+
+// ParseSecretRef parses s; ok is false when s is not a secret reference.
+func ParseSecretRef(s string) (ref SecretRef, ok bool, err error) {
+	rest, ok := strings.CutPrefix(s, SecretScheme)
+	if !ok {
+		return SecretRef{}, false, nil
+	}
+	rest, ref.Key, _ = strings.Cut(rest, "#")
+	ref.Provider, ref.Path, _ = strings.Cut(rest, "/")
+	if ref.Provider == "" || ref.Path == "" {
+		return SecretRef{}, true, fmt.Errorf("invalid secret reference %q, want %s<provider>/<path>[#<key>]", s, SecretScheme)
+	}
+	return ref, true, nil
+}
+
+// Secrets resolves references through the registered providers and caches
+// the values for a TTL. When a refresh fails the stale value is kept.
+type Secrets struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	providers map[string]SecretProvider
+	cache     map[SecretRef]cachedSecret
+}
+
+type cachedSecret struct {
+	value   string
+	fetched time.Time
+}
+
+// NewSecrets returns a resolver with the file and env providers. A ttl of
+// zero caches values until Expire.
+func NewSecrets(ttl time.Duration) *Secrets {
+	return &Secrets{
+		ttl:       ttl,
+		providers: map[string]SecretProvider{"file": FileSecrets{}, "env": EnvSecrets{}},
+		cache:     map[SecretRef]cachedSecret{},
+	}
+}
+
+// Register adds or replaces the provider for name.
+func (s *Secrets) Register(name string, p SecretProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.providers[name] = p
+}
+
+// SetTTL changes how long resolved values are cached.
+func (s *Secrets) SetTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttl = ttl
+}
+
+// Expire makes the next Resolve of every reference fetch it again.
+func (s *Secrets) Expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ref, c := range s.cache {
+		c.fetched = time.Time{}
+		s.cache[ref] = c
+	}
+}
+
+// Resolve returns the value of ref, from the cache while it is fresh.
+func (s *Secrets) Resolve(ctx context.Context, ref SecretRef) (string, error) {
+	s.mu.Lock()
+	cached, hit := s.cache[ref]
+	fresh := hit && !cached.fetched.IsZero() && (s.ttl <= 0 || time.Since(cached.fetched) < s.ttl)
+	p := s.providers[ref.Provider]
+	s.mu.Unlock()
+	if fresh {
+		return cached.value, nil
+	}
+	if p == nil {
+		return "", fmt.Errorf("unknown secret provider %q", ref.Provider)
+	}
+
+	value, err := p.Secret(ctx, ref.Path, ref.Key)
+	if err != nil {
+		if hit && !errors.Is(err, ErrSecretNotFound) {
+			slog.Warn("secret refresh failed, keeping cached value", "ref", ref.String(), "error", err)
+			return cached.value, nil
+		}
+		return "", err
+	}
+	s.mu.Lock()
+	s.cache[ref] = cachedSecret{value: value, fetched: time.Now()}
+	s.mu.Unlock()
+	return value, nil
+}
+
+// resolveSecrets replaces every secret:// value of c with its value from
+// store. Failures are reported like validation errors, by key.
+func resolveSecrets(c *Config, store *Secrets) error {
+	store.SetTTL(c.Secrets.CacheTTL)
+	timeout := c.Secrets.Vault.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*timeout)
+	defer cancel()
+
+	resolve := func(s string) (string, bool, error) {
+		ref, ok, err := ParseSecretRef(s)
+		if !ok || err != nil {
+			return s, ok, err
+		}
+		value, err := store.Resolve(ctx, ref)
+		return value, true, err
+	}
+
+	// The vault token may itself come from a file or the environment.
+	vault := c.Secrets.Vault
+	if vault.Address == "" {
+		vault.Address = os.Getenv("VAULT_ADDR")
+	}
+	if vault.Token == "" {
+		vault.Token = os.Getenv("VAULT_TOKEN")
+	}
+	token, _, err := resolve(vault.Token)
+	if err != nil {
+		return &ValidationError{Errors: []FieldError{{Path: "secrets.vault.token", Value: vault.Token, Rule: "cannot resolve secret: " + err.Error()}}}
+	}
+	vault.Token = token
+	if vault.Address != "" {
+		store.Register("vault", NewVaultSecrets(vault, &http.Client{Timeout: timeout}))
+	}
+
+	v := &validator{}
+	c.secretRefs = map[string]string{}
+	walkStrings(reflect.ValueOf(c).Elem(), "", func(path string, field reflect.Value) {
+		value, ok, err := resolve(field.String())
+		if !ok {
+			return
+		}
+		if !field.CanSet() {
+			v.add(path, field.String(), "secret references are not supported here")
+			return
+		}
+		if err != nil {
+			v.add(path, field.String(), "cannot resolve secret: "+err.Error())
+			return
+		}
+		c.secretRefs[path] = field.String()
+		field.SetString(value)
+	})
+	if len(v.errs) > 0 {
+		return &ValidationError{Errors: v.errs}
+	}
+	return nil
+}
+
+// FileSecrets reads secrets from files, such as Docker and Kubernetes
+// secrets: secret://file/run/secrets/jwt reads /run/secrets/jwt. With a key
+// the file must hold a JSON object.
+type FileSecrets struct{}
+
+func (FileSecrets) Secret(_ context.Context, path, key string) (string, error) {
+	path = "/" + path
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%s: %w", path, ErrSecretNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return strings.TrimRight(string(raw), "\r\n"), nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", fmt.Errorf("%s: #%s needs a JSON object: %w", path, key, err)
+	}
+	return secretField(fields, path, key)
+}
+
+// EnvSecrets reads secrets from environment variables:
+// secret://env/JWT_SECRET.
+type EnvSecrets struct{}
+
+func (EnvSecrets) Secret(_ context.Context, name, key string) (string, error) {
+	if key != "" {
+		return "", fmt.Errorf("env secret %s: keys are not supported", name)
+	}
+	value := os.Getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("env %s: %w", name, ErrSecretNotFound)
+	}
+	return value, nil
+}
+
+// secretField returns fields[key] as a string, JSON-encoding non-strings.
+func secretField(fields map[string]any, path, key string) (string, error) {
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%s#%s: %w", path, key, ErrSecretNotFound)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	raw, err := json.Marshal(v)
+	return string(raw), err
+}
@@ -0,0 +1,415 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is one problem found by Validate.
+type FieldError struct {
+	Path  string
+	Value any
+	Rule  string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s = %s: %s", e.Path, formatValue(e.Path, e.Value), e.Rule)
+}
+
+// ValidationError lists every problem of a configuration, so they can all be
+// fixed in one go.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (%d problems):", len(e.Errors))
+	for _, fe := range e.Errors {
+		b.WriteString("\n  - ")
+		b.WriteString(fe.Error())
+	}
+	return b.String()
+}
+
+// Default secrets shipped in configs/config.yaml; they must be replaced
+// outside development.
+var defaultSecrets = map[string]bool{
+	"your-jwt-secret-key": true,
+	"your-api-key":        true,
+	"changeme":            true,
+	"secret":              true,
+}
+
+const minJWTSecretLen = 32
+
+// IsDevelopment reports whether the service runs in a development
+// environment, where the stricter secret rules are relaxed.
+func (c *Config) IsDevelopment() bool {
+	switch c.Environment {
+	case "development", "dev", "local":
+		return true
+	}
+	return false
+}
+
+// Validate checks the configuration and returns a *ValidationError listing
+// every problem, or nil.
+func (c *Config) Validate() error {
+	v := &validator{}
+	dev := c.IsDevelopment()
+
+	walkStrings(reflect.ValueOf(c).Elem(), "", func(path string, f reflect.Value) {
+		s := f.String()
+		if strings.Contains(s, "{{") && strings.Contains(s, "}}") {
+			v.add(path, s, "unreplaced template placeholder")
+		}
+		if !dev && strings.Contains(s, "${") {
+			v.add(path, s, "unexpanded environment reference outside development")
+		}
+	})
+
+	v.required("service_name", c.ServiceName)
+	v.oneOf("environment", c.Environment, "development", "dev", "local", "test", "staging", "production", "prod")
+	v.oneOf("log_level", strings.ToLower(c.LogLevel), "debug", "info", "warn", "error")
+	v.port("http_port", c.HTTPPort)
+	if c.MetricsPort != "" {
+		v.port("metrics_port", c.MetricsPort)
+	}
+
+	if c.Database.ConnMaxLifetime < 0 {
+		v.add("database.conn_max_lifetime", c.Database.ConnMaxLifetime, "must not be negative")
+	}
+	if c.Database.URL != "" {
+		v.url("database.url", c.Database.URL, "postgres", "postgresql")
+	}
+	for i, r := range c.Database.Replicas {
+		v.url(fmt.Sprintf("database.replicas[%d]", i), r, "postgres", "postgresql")
+	}
+	v.oneOf("database.log_level", c.Database.LogLevel, "silent", "error", "warn", "info")
+	v.positive("database.stats_interval", c.Database.StatsInterval)
+	if c.Database.MaxIdleConns > c.Database.MaxOpenConns && c.Database.MaxOpenConns > 0 {
+		v.add("database.max_idle_conns", c.Database.MaxIdleConns, "must not exceed database.max_open_conns")
+	}
+	if strings.Contains(c.ClickHouse.URL, "://") {
+		v.url("clickhouse.url", c.ClickHouse.URL, "clickhouse", "tcp", "http", "https")
+	}
+	v.oneOf("clickhouse.compression", c.ClickHouse.Compression, "none", "lz4", "zstd")
+	if (c.ClickHouse.TLS.CertFile == "") != (c.ClickHouse.TLS.KeyFile == "") {
+		v.add("clickhouse.tls.key_file", c.ClickHouse.TLS.KeyFile, "cert_file and key_file must be set together")
+	}
+	if b := c.ClickHouse.Batch; b.Size <= 0 {
+		v.add("clickhouse.batch.size", b.Size, "must be positive")
+	} else if b.BufferSize < b.Size {
+		v.add("clickhouse.batch.buffer_size", b.BufferSize, "must be at least clickhouse.batch.size")
+	}
+	v.positive("clickhouse.dial_timeout", c.ClickHouse.DialTimeout)
+	v.positive("clickhouse.batch.flush_interval", c.ClickHouse.Batch.FlushInterval)
+	v.positive("clickhouse.batch.flush_timeout", c.ClickHouse.Batch.FlushTimeout)
+	if c.Redis.URL != "" {
+		v.url("redis.url", c.Redis.URL, "redis", "rediss")
+	}
+
+	v.url("nats.url", c.NATS.URL, "nats", "tls")
+	v.required("nats.stream", c.NATS.Stream)
+	if strings.ContainsAny(c.NATS.Stream, ". *>") {
+		v.add("nats.stream", c.NATS.Stream, "must not contain '.', '*', '>' or spaces")
+	}
+	v.required("nats.subjects.request", c.NATS.Subjects.Request)
+	v.oneOf("nats.consumers.mode", c.NATS.Consumers.Mode, "pull", "push")
+	v.oneOf("nats.idempotency.backend", c.NATS.Idempotency.Backend, "kv", "redis")
+	if lease := c.NATS.Idempotency.Lease; lease < 0 {
+		v.add("nats.idempotency.lease", lease, "must not be negative")
+	} else if lease > 0 {
+		ackWait := c.NATS.Consumers.AckWait
+		for _, s := range c.NATS.Consumers.Subjects {
+			if s.AckWait > ackWait {
+				ackWait = s.AckWait
+			}
+		}
+		if lease < ackWait {
+			v.add("nats.idempotency.lease", lease, fmt.Sprintf("must be at least the consumer ack_wait (%s)", ackWait))
+		}
+	}
+	if c.NATS.Retry.Multiplier != 0 && c.NATS.Retry.Multiplier < 1 {
+		v.add("nats.retry.multiplier", c.NATS.Retry.Multiplier, "must be at least 1")
+	}
+	if c.NATS.Retry.Jitter < 0 || c.NATS.Retry.Jitter > 1 {
+		v.add("nats.retry.jitter", c.NATS.Retry.Jitter, "must be between 0 and 1")
+	}
+	for i, s := range c.NATS.Provisioning.Streams {
+		p := fmt.Sprintf("nats.provisioning.streams[%d]", i)
+		v.required(p+".name", s.Name)
+		v.oneOf(p+".retention", s.Retention, "", "limits", "interest", "workqueue")
+		v.oneOf(p+".storage", s.Storage, "", "file", "memory")
+		v.oneOf(p+".discard", s.Discard, "", "old", "new")
+		for j, cons := range s.Consumers {
+			cp := fmt.Sprintf("%s.consumers[%d]", p, j)
+			v.required(cp+".durable", cons.Durable)
+			v.oneOf(cp+".deliver_policy", cons.DeliverPolicy, "", "all", "new", "last")
+			v.oneOf(cp+".ack_policy", cons.AckPolicy, "", "explicit", "all", "none")
+		}
+	}
+
+	if c.Outbox.Enabled {
+		v.required("database.url", c.Database.URL)
+		if c.Outbox.BatchSize <= 0 {
+			v.add("outbox.batch_size", c.Outbox.BatchSize, "must be positive")
+		}
+		if c.Outbox.MaxAttempts <= 0 {
+			v.add("outbox.max_attempts", c.Outbox.MaxAttempts, "must be positive")
+		}
+	}
+
+	jwtKeys := c.JWT.PublicKeyFile != "" || c.JWT.JWKSURL != ""
+	if !jwtKeys {
+		v.required("jwt.secret", c.JWT.Secret)
+	}
+	for i, alg := range c.JWT.Algorithms {
+		p := fmt.Sprintf("jwt.algorithms[%d]", i)
+		switch {
+		case strings.HasPrefix(alg, "HS"):
+			v.oneOf(p, alg, "HS256", "HS384", "HS512")
+			if c.JWT.Secret == "" {
+				v.add(p, alg, "needs jwt.secret")
+			}
+		default:
+			v.oneOf(p, alg, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA")
+			if !jwtKeys {
+				v.add(p, alg, "needs jwt.public_key_file or jwt.jwks_url")
+			}
+		}
+	}
+	if c.JWT.JWKSURL != "" {
+		v.url("jwt.jwks_url", c.JWT.JWKSURL, "http", "https")
+		v.positive("jwt.jwks_refresh", c.JWT.JWKSRefresh)
+	}
+	if c.JWT.Leeway < 0 {
+		v.add("jwt.leeway", c.JWT.Leeway, "must not be negative")
+	}
+	// The API allows credentialed CORS requests, which "*" would open to any
+	// site.
+	for i, o := range c.Security.AllowedOrigins {
+		if o == "*" {
+			v.add(fmt.Sprintf("security.allowed_origins[%d]", i), o, "must list explicit origins; \"*\" is not allowed with credentials")
+		}
+	}
+	if !dev {
+		v.notDefault("jwt.secret", c.JWT.Secret)
+		if c.JWT.Secret != "" && len(c.JWT.Secret) < minJWTSecretLen {
+			v.add("jwt.secret", c.JWT.Secret, fmt.Sprintf("must be at least %d characters outside development", minJWTSecretLen))
+		}
+		v.notDefault("security.api_key", c.Security.APIKey)
+		if c.AI.Enabled {
+			v.required("ai.api_key", c.AI.APIKey)
+			v.notDefault("ai.api_key", c.AI.APIKey)
+		}
+	}
+
+	if c.Secrets.CacheTTL < 0 {
+		v.add("secrets.cache_ttl", c.Secrets.CacheTTL, "must not be negative")
+	}
+	if c.Secrets.Vault.Address != "" {
+		v.url("secrets.vault.address", c.Secrets.Vault.Address, "http", "https")
+		if c.Secrets.Vault.KVVersion != 1 && c.Secrets.Vault.KVVersion != 2 {
+			v.add("secrets.vault.kv_version", c.Secrets.Vault.KVVersion, "must be 1 or 2")
+		}
+	}
+
+	if c.RateLimit.Enabled && c.RateLimit.RPS <= 0 {
+		v.add("rate_limit.rps", c.RateLimit.RPS, "must be positive when rate limiting is enabled")
+	}
+	if c.RateLimit.Enabled && c.RateLimit.Burst <= 0 {
+		v.add("rate_limit.burst", c.RateLimit.Burst, "must be positive when rate limiting is enabled")
+	}
+	if c.RateLimit.Distributed {
+		v.required("redis.url", c.Redis.URL)
+	}
+	names := map[string]bool{}
+	for i, pol := range c.RateLimit.Policies {
+		p := fmt.Sprintf("rate_limit.policies[%d]", i)
+		v.required(p+".name", pol.Name)
+		if names[pol.Name] {
+			v.add(p+".name", pol.Name, "duplicate policy name")
+		}
+		names[pol.Name] = true
+		if pol.Tenant == "" && pol.Plan == "" && pol.Route == "" && pol.APIKey == "" {
+			v.add(p, pol.Name, "needs at least one of tenant, plan, route or api_key")
+		}
+		if pol.Route != "" && !strings.HasPrefix(pol.Route, "/") {
+			v.add(p+".route", pol.Route, "must start with /")
+		}
+		if pol.RPS <= 0 {
+			v.add(p+".rps", pol.RPS, "must be positive")
+		}
+		if pol.Burst <= 0 {
+			v.add(p+".burst", pol.Burst, "must be positive")
+		}
+	}
+	for i, s := range c.RateLimit.Allowlist {
+		v.ipOrCIDR(fmt.Sprintf("rate_limit.allowlist[%d]", i), s)
+	}
+	for i, s := range c.RateLimit.Denylist {
+		v.ipOrCIDR(fmt.Sprintf("rate_limit.denylist[%d]", i), s)
+	}
+	if c.RateLimit.Ban.Threshold > 0 {
+		v.positive("rate_limit.ban.window", c.RateLimit.Ban.Window)
+		v.positive("rate_limit.ban.duration", c.RateLimit.Ban.Duration)
+	}
+	v.positive("rate_limit.idle_ttl", c.RateLimit.IdleTTL)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+type validator struct {
+	errs []FieldError
+}
+
+func (v *validator) add(path string, value any, rule string) {
+	v.errs = append(v.errs, FieldError{Path: path, Value: value, Rule: rule})
+}
+
+func (v *validator) required(path, s string) {
+	if strings.TrimSpace(s) == "" {
+		v.add(path, s, "is required")
+	}
+}
+
+func (v *validator) oneOf(path, s string, allowed ...string) {
+	for _, a := range allowed {
+		if s == a {
+			return
+		}
+	}
+	var shown []string
+	for _, a := range allowed {
+		if a != "" {
+			shown = append(shown, a)
+		}
+	}
+	v.add(path, s, "must be one of "+strings.Join(shown, ", "))
+}
+
+func (v *validator) port(path, s string) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 65535 {
+		v.add(path, s, "must be a port number between 1 and 65535")
+	}
+}
+
+func (v *validator) url(path, s string, schemes ...string) {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		v.add(path, s, "must be a URL with a host")
+		return
+	}
+	v.oneOf(path, u.Scheme, schemes...)
+}
+
+func (v *validator) positive(path string, d time.Duration) {
+	if d <= 0 {
+		v.add(path, d, "must be positive")
+	}
+}
+
+func (v *validator) ipOrCIDR(path, s string) {
+	if net.ParseIP(s) != nil {
+		return
+	}
+	if _, _, err := net.ParseCIDR(s); err != nil {
+		v.add(path, s, "must be an IP address or CIDR")
+	}
+}
+
+func (v *validator) notDefault(path, s string) {
+	if defaultSecrets[s] || strings.HasPrefix(s, "${") {
+		v.add(path, s, "must be changed from the example value outside development")
+	}
+}
+
+// walkStrings calls fn for every string reachable from v, with its
+// mapstructure path. Strings outside maps can be set through f.
+func walkStrings(v reflect.Value, path string, fn func(path string, f reflect.Value)) {
+	switch v.Kind() {
+	case reflect.String:
+		fn(path, v)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := strings.Split(f.Tag.Get("mapstructure"), ",")[0]
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			walkStrings(v.Field(i), name, fn)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkStrings(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			walkStrings(iter.Value(), fmt.Sprintf("%s.%v", path, iter.Key()), fn)
+		}
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			walkStrings(v.Elem(), path, fn)
+		}
+	}
+}
+
+// formatValue quotes a value for an error message, hiding secrets that are
+// not one of the well-known example values or a secret:// reference.
+func formatValue(path string, value any) string {
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	if isURLPath(path) {
+		return strconv.Quote(redactURL(s))
+	}
+	if isSecretPath(path) && s != "" && !defaultSecrets[s] && !strings.HasPrefix(s, "${") && !strings.HasPrefix(s, SecretScheme) {
+		return `"<redacted>"`
+	}
+	return strconv.Quote(s)
+}
+
+// redactURL hides the password of a URL, which may embed credentials.
+func redactURL(s string) string {
+	if u, err := url.Parse(s); err == nil {
+		return u.Redacted()
+	}
+	return "<redacted>"
+}
+
+// isURLPath matches keys holding URLs, which may embed credentials.
+func isURLPath(path string) bool {
+	return strings.HasSuffix(path, ".url") || strings.HasPrefix(path, "database.replicas")
+}
+
+// isSecretPath matches keys whose last segment names a secret, e.g.
+// jwt.secret or secrets.vault.token but not secrets.cache_ttl.
+func isSecretPath(path string) bool {
+	p := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+	for _, k := range []string{"secret", "password", "api_key", "token"} {
+		if strings.Contains(p, k) {
+			return true
+		}
+	}
+	return false
+}
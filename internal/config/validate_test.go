@@ -0,0 +1,286 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultConfig returns the built-in defaults with the secrets production
+// requires.
+func defaultConfig(t *testing.T) *Config {
+	t.Helper()
+	v := viper.New()
+	setDefaults(v)
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		t.Fatal(err)
+	}
+	c.JWT.Secret = strings.Repeat("k", minJWTSecretLen)
+	c.AI.APIKey = "sk-live-0123456789"
+	return &c
+}
+
+// problems returns "path: rule" for every FieldError of err.
+func problems(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %T %v, want *ValidationError", err, err)
+	}
+	var out []string
+	for _, fe := range verr.Errors {
+		out = append(out, fe.Path+": "+fe.Rule)
+	}
+	return out
+}
+
+func TestValidateDefaults(t *testing.T) {
+	for _, env := range []string{"development", "local", "test", "staging", "production", "prod"} {
+		c := defaultConfig(t)
+		c.Environment = env
+		if err := c.Validate(); err != nil {
+			t.Errorf("%s: %v", env, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		set  func(c *Config)
+		want []string
+	}{
+		{
+			name: "port",
+			env:  "development",
+			set:  func(c *Config) { c.HTTPPort = "abc"; c.MetricsPort = "70000" },
+			want: []string{
+				"http_port: must be a port number between 1 and 65535",
+				"metrics_port: must be a port number between 1 and 65535",
+			},
+		},
+		{
+			name: "unreplaced placeholder",
+			env:  "development",
+			set:  func(c *Config) { c.ServiceName = "{{ .ServiceName }}" },
+			want: []string{"service_name: unreplaced template placeholder"},
+		},
+		{
+			name: "enums",
+			env:  "qa",
+			set:  func(c *Config) { c.LogLevel = "trace"; c.NATS.Consumers.Mode = "poll" },
+			want: []string{
+				"environment: must be one of development, dev, local, test, staging, production, prod",
+				"log_level: must be one of debug, info, warn, error",
+				"nats.consumers.mode: must be one of pull, push",
+			},
+		},
+		{
+			name: "urls",
+			env:  "development",
+			set: func(c *Config) {
+				c.Database.URL = "mysql://db/app"
+				c.Redis.URL = "localhost:6379"
+				c.NATS.URL = "nats://"
+			},
+			want: []string{
+				"database.url: must be one of postgres, postgresql",
+				"redis.url: must be a URL with a host",
+				"nats.url: must be a URL with a host",
+			},
+		},
+		{
+			name: "durations and sizes",
+			env:  "development",
+			set: func(c *Config) {
+				c.Database.ConnMaxLifetime = -time.Second
+				c.ClickHouse.Batch.BufferSize = 10
+				c.RateLimit.IdleTTL = 0
+			},
+			want: []string{
+				"database.conn_max_lifetime: must not be negative",
+				"clickhouse.batch.buffer_size: must be at least clickhouse.batch.size",
+				"rate_limit.idle_ttl: must be positive",
+			},
+		},
+		{
+			name: "nats retry and lease",
+			env:  "development",
+			set: func(c *Config) {
+				c.NATS.Retry.Multiplier = 0.5
+				c.NATS.Retry.Jitter = 1.5
+				c.NATS.Idempotency.Lease = 10 * time.Second
+				c.NATS.Consumers.Subjects = []SubjectConsumerConfig{{Subject: "reports.>", AckWait: time.Minute}}
+			},
+			want: []string{
+				"nats.idempotency.lease: must be at least the consumer ack_wait (1m0s)",
+				"nats.retry.multiplier: must be at least 1",
+				"nats.retry.jitter: must be between 0 and 1",
+			},
+		},
+		{
+			name: "outbox",
+			env:  "development",
+			set:  func(c *Config) { c.Outbox.Enabled = true; c.Outbox.MaxAttempts = 0 },
+			want: []string{"database.url: is required", "outbox.max_attempts: must be positive"},
+		},
+		{
+			name: "rate limit policies",
+			env:  "development",
+			set: func(c *Config) {
+				c.RateLimit.Policies = []RateLimitPolicy{
+					{Name: "free", Plan: "free", RPS: 1, Burst: 1},
+					{Name: "free", Route: "api", RPS: 1, Burst: 1},
+					{Name: "any", RPS: 0, Burst: 1},
+				}
+				c.RateLimit.Allowlist = []string{"10.0.0.0/8", "10.0.0.1", "intranet"}
+			},
+			want: []string{
+				"rate_limit.policies[1].name: duplicate policy name",
+				"rate_limit.policies[1].route: must start with /",
+				"rate_limit.policies[2]: needs at least one of tenant, plan, route or api_key",
+				"rate_limit.policies[2].rps: must be positive",
+				"rate_limit.allowlist[2]: must be an IP address or CIDR",
+			},
+		},
+		{
+			name: "asymmetric jwt without keys",
+			env:  "development",
+			set:  func(c *Config) { c.JWT.Algorithms = []string{"HS256", "RS256", "none"} },
+			want: []string{
+				"jwt.algorithms[1]: needs jwt.public_key_file or jwt.jwks_url",
+				"jwt.algorithms[2]: must be one of RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512, EdDSA",
+				"jwt.algorithms[2]: needs jwt.public_key_file or jwt.jwks_url",
+			},
+		},
+		{
+			name: "wildcard origin in development",
+			env:  "development",
+			set:  func(c *Config) { c.Security.AllowedOrigins = []string{"https://app.example.com", "*"} },
+			want: []string{`security.allowed_origins[1]: must list explicit origins; "*" is not allowed with credentials`},
+		},
+		{
+			name: "wildcard origin in production",
+			env:  "production",
+			set:  func(c *Config) { c.Security.AllowedOrigins = []string{"*"} },
+			want: []string{`security.allowed_origins[0]: must list explicit origins; "*" is not allowed with credentials`},
+		},
+
+		// Secrets are only enforced outside development.
+		{
+			name: "example secrets in development",
+			env:  "development",
+			set: func(c *Config) {
+				c.JWT.Secret = "your-jwt-secret-key"
+				c.Security.APIKey = "your-api-key"
+				c.AI.APIKey = ""
+			},
+		},
+		{
+			name: "example secrets in production",
+			env:  "production",
+			set: func(c *Config) {
+				c.JWT.Secret = "your-jwt-secret-key"
+				c.Security.APIKey = "your-api-key"
+				c.AI.APIKey = "changeme"
+			},
+			want: []string{
+				"jwt.secret: must be changed from the example value outside development",
+				"jwt.secret: must be at least 32 characters outside development",
+				"security.api_key: must be changed from the example value outside development",
+				"ai.api_key: must be changed from the example value outside development",
+			},
+		},
+		{
+			name: "missing secrets in staging",
+			env:  "staging",
+			set:  func(c *Config) { c.JWT.Secret = ""; c.AI.APIKey = "" },
+			want: []string{"jwt.secret: is required", "ai.api_key: is required"},
+		},
+		{
+			name: "ai disabled in production",
+			env:  "production",
+			set:  func(c *Config) { c.AI.Enabled = false; c.AI.APIKey = "" },
+		},
+		{
+			name: "jwt keys instead of a secret",
+			env:  "production",
+			set: func(c *Config) {
+				c.JWT.Secret = ""
+				c.JWT.JWKSURL = "https://auth.example.com/.well-known/jwks.json"
+				c.JWT.Algorithms = []string{"RS256"}
+			},
+		},
+		{
+			name: "env reference in development",
+			env:  "development",
+			set:  func(c *Config) { c.JWT.Secret = "${JWT_SECRET}" },
+		},
+		{
+			name: "env reference in production",
+			env:  "production",
+			set:  func(c *Config) { c.JWT.Secret = "${JWT_SECRET}" },
+			want: []string{
+				"jwt.secret: unexpanded environment reference outside development",
+				"jwt.secret: must be changed from the example value outside development",
+				"jwt.secret: must be at least 32 characters outside development",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaultConfig(t)
+			c.Environment = tt.env
+			tt.set(c)
+			if got := problems(t, c.Validate()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problems:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationErrorListsEveryProblem(t *testing.T) {
+	c := defaultConfig(t)
+	c.Environment = "production"
+	c.HTTPPort = "http"
+	c.JWT.Secret = "hunter2"
+	c.Database.URL = "postgres://app:pa55word@/app"
+	c.Security.AllowedOrigins = []string{"*"}
+
+	err := c.Validate()
+	want := []string{
+		"http_port: must be a port number between 1 and 65535",
+		"database.url: must be a URL with a host",
+		`security.allowed_origins[0]: must list explicit origins; "*" is not allowed with credentials`,
+		"jwt.secret: must be at least 32 characters outside development",
+	}
+	if got := problems(t, err); !reflect.DeepEqual(got, want) {
+		t.Fatalf("problems:\n got %q\nwant %q", got, want)
+	}
+
+	msg := err.Error()
+	for _, s := range []string{
+		"invalid configuration (4 problems):",
+		`http_port = "http": must be a port number`,
+		`database.url = "postgres://app:xxxxx@/app"`,
+		`jwt.secret = "<redacted>"`,
+	} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("message lacks %q:\n%s", s, msg)
+		}
+	}
+	for _, leak := range []string{"hunter2", "pa55word"} {
+		if strings.Contains(msg, leak) {
+			t.Errorf("message leaks %q:\n%s", leak, msg)
+		}
+	}
+}
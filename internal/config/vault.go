@@ -0,0 +1,105 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// VaultSecrets reads secrets from a Vault KV secrets engine over its HTTP
+// API. In secret://vault/kv/mcp#jwt the first path segment ("kv") is the
+// mount and the key is required.
+type VaultSecrets struct {
+	address   string
+	token     string
+	namespace string
+	kvVersion int
+	client    *http.Client
+}
+
+// NewVaultSecrets returns a provider for the server at cfg.Address, which
+// may be any HTTP server speaking the KV API, e.g. an httptest stand-in.
+func NewVaultSecrets(cfg VaultConfig, client *http.Client) *VaultSecrets {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	kv := cfg.KVVersion
+	if kv == 0 {
+		kv = 2
+	}
+	return &VaultSecrets{
+		address:   strings.TrimRight(cfg.Address, "/"),
+		token:     cfg.Token,
+		namespace: cfg.Namespace,
+		kvVersion: kv,
+		client:    client,
+	}
+}
+
+func (p *VaultSecrets) Secret(ctx context.Context, path, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("vault secret %s: a #key is required", path)
+	}
+	mount, rest, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	if rest == "" {
+		return "", fmt.Errorf("vault secret %s: want <mount>/<path>", path)
+	}
+	apiPath := "/v1/" + url.PathEscape(mount) + "/" + escapePath(rest)
+	if p.kvVersion == 2 {
+		apiPath = "/v1/" + url.PathEscape(mount) + "/data/" + escapePath(rest)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.address+apiPath, nil)
+	if err != nil {
+		return "", err
+	}
+	if p.token != "" {
+		req.Header.Set("X-Vault-Token", p.token)
+	}
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("vault %s: %w", path, ErrSecretNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("vault %s: %s %s", path, resp.Status, strings.Join(body.Errors, "; "))
+	}
+
+	// KV v2 nests the secret under data.data, next to its metadata.
+	var fields map[string]any
+	if p.kvVersion == 2 {
+		var v2 struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(body.Data, &v2); err != nil {
+			return "", fmt.Errorf("vault %s: %w", path, err)
+		}
+		fields = v2.Data
+	} else if err := json.Unmarshal(body.Data, &fields); err != nil {
+		return "", fmt.Errorf("vault %s: %w", path, err)
+	}
+	return secretField(fields, path, key)
+}
+
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, s := range parts {
+		parts[i] = url.PathEscape(s)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// vaultStub serves one KV secret at /v1/kv/data/mcp (v2) and /v1/kv/mcp (v1)
+// and counts the requests that reached it.
+type vaultStub struct {
+	hits      atomic.Int32
+	token     string
+	namespace string
+	value     atomic.Value // string
+}
+
+func newVaultStub(t *testing.T) (*vaultStub, *httptest.Server) {
+	s := &vaultStub{}
+	s.value.Store("s3cret")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		if r.Header.Get("X-Vault-Token") != s.token || r.Header.Get("X-Vault-Namespace") != s.namespace {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		value := s.value.Load().(string)
+		switch r.URL.Path {
+		case "/v1/kv/data/mcp":
+			_, _ = w.Write([]byte(`{"data":{"data":{"jwt":"` + value + `","port":5432},"metadata":{"version":3}}}`))
+		case "/v1/kv/mcp":
+			_, _ = w.Write([]byte(`{"data":{"jwt":"` + value + `"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func TestVaultSecrets(t *testing.T) {
+	stub, srv := newVaultStub(t)
+	stub.token, stub.namespace = "root-token", "team-a"
+
+	tests := []struct {
+		name      string
+		kv        int
+		token     string
+		path, key string
+		want      string
+		wantErr   error
+	}{
+		{name: "kv v2", kv: 2, token: "root-token", path: "kv/mcp", key: "jwt", want: "s3cret"},
+		{name: "kv v2 non-string field", kv: 2, token: "root-token", path: "kv/mcp", key: "port", want: "5432"},
+		{name: "kv v1", kv: 1, token: "root-token", path: "kv/mcp", key: "jwt", want: "s3cret"},
+		{name: "missing path", kv: 2, token: "root-token", path: "kv/other", key: "jwt", wantErr: ErrSecretNotFound},
+		{name: "missing key", kv: 2, token: "root-token", path: "kv/mcp", key: "nope", wantErr: ErrSecretNotFound},
+		{name: "wrong token", kv: 2, token: "bad", path: "kv/mcp", key: "jwt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewVaultSecrets(VaultConfig{Address: srv.URL + "/", Token: tt.token, Namespace: "team-a", KVVersion: tt.kv}, srv.Client())
+			got, err := p.Secret(context.Background(), tt.path, tt.key)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.want == "":
+				if err == nil {
+					t.Fatalf("got %q, want an error", got)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case got != tt.want:
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultSecretsRequiresKey(t *testing.T) {
+	p := NewVaultSecrets(VaultConfig{Address: "http://127.0.0.1:1"}, nil)
+	if _, err := p.Secret(context.Background(), "kv/mcp", ""); err == nil {
+		t.Fatal("want an error without #key")
+	}
+}
+
+func TestSecretsCacheTTL(t *testing.T) {
+	stub, srv := newVaultStub(t)
+	store := NewSecrets(50 * time.Millisecond)
+	store.Register("vault", NewVaultSecrets(VaultConfig{Address: srv.URL, KVVersion: 2}, srv.Client()))
+	ref := SecretRef{Provider: "vault", Path: "kv/mcp", Key: "jwt"}
+	ctx := context.Background()
+
+	resolve := func() string {
+		t.Helper()
+		v, err := store.Resolve(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	if v := resolve(); v != "s3cret" {
+		t.Fatalf("got %q", v)
+	}
+	stub.value.Store("rotated")
+	if v := resolve(); v != "s3cret" || stub.hits.Load() != 1 {
+		t.Fatalf("within ttl: got %q after %d requests, want the cached value", v, stub.hits.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if v := resolve(); v != "rotated" || stub.hits.Load() != 2 {
+		t.Fatalf("after ttl: got %q after %d requests, want a refetch", v, stub.hits.Load())
+	}
+
+	// A failed refresh keeps serving the cached value.
+	store.Expire()
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	if v := resolve(); v != "rotated" {
+		t.Fatalf("vault down: got %q, want the stale value", v)
+	}
+}
@@ -0,0 +1,288 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadable are the keys (and sections) applied live on reload. A change to
+// any other key is logged and waits for a restart.
+var reloadable = []string{"log_level", "rate_limit", "security.allowed_origins", "ai"}
+
+// IsReloadable reports whether a change to path is applied without a restart.
+func IsReloadable(path string) bool {
+	for _, p := range reloadable {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadDebounce groups the several events editors and ConfigMap updates
+// produce for one change.
+const reloadDebounce = 250 * time.Millisecond
+
+// ReloadObserver records the outcome of every reload: "applied", "rejected"
+// (only keys that need a restart changed), "unchanged" or "failed".
+type ReloadObserver interface {
+	ObserveReload(result string)
+}
+
+// Watcher reloads the configuration when a config file changes or on SIGHUP
+// and passes the result to its subscribers. Only reloadable keys change;
+// Current always returns a complete, validated configuration.
+type Watcher struct {
+	secrets  *Secrets
+	logger   *slog.Logger
+	observer ReloadObserver
+
+	mu   sync.Mutex // serializes reloads and guards subs
+	subs []func(*Config)
+	cur  atomic.Pointer[Config]
+}
+
+// NewWatcher starts from cfg, the configuration loaded at startup with
+// secrets. observer may be nil.
+func NewWatcher(cfg *Config, secrets *Secrets, logger *slog.Logger, observer ReloadObserver) *Watcher {
+	w := &Watcher{secrets: secrets, logger: logger, observer: observer}
+	w.cur.Store(cfg)
+	return w
+}
+
+// Current returns the configuration in effect. Callers must not modify it.
+func (w *Watcher) Current() *Config {
+	return w.cur.Load()
+}
+
+// Subscribe calls fn with the new configuration after every applied reload.
+func (w *Watcher) Subscribe(fn func(*Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subs = append(w.subs, fn)
+}
+
+// Reload loads the configuration again and applies the changed reloadable
+// keys. An invalid configuration is rejected as a whole.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	loaded, err := Load(w.secrets)
+	if err != nil {
+		w.logger.Error("config reload failed, keeping current config", "error", err)
+		w.observe("failed")
+		return err
+	}
+
+	old := w.Current()
+	next := *old
+	next.sources = cloneMap(old.sources)
+	next.secretRefs = cloneMap(old.secretRefs)
+
+	var applied, ignored, rotated []string
+	for _, path := range changedKeys(old, loaded) {
+		if !IsReloadable(path) {
+			if _, ok := loaded.secretRefs[path]; ok {
+				rotated = append(rotated, path)
+			} else {
+				ignored = append(ignored, path)
+			}
+			continue
+		}
+		copyKey(&next, loaded, path)
+		next.sources[path] = loaded.sources[path]
+		delete(next.secretRefs, path)
+		if ref, ok := loaded.secretRefs[path]; ok {
+			next.secretRefs[path] = ref
+		}
+		applied = append(applied, path)
+	}
+	if len(ignored) > 0 {
+		w.logger.Warn("config changes need a restart, ignored", "keys", ignored)
+	}
+	if len(rotated) > 0 {
+		w.logger.Warn("rotated secrets need a restart to take effect, still using the old values", "keys", rotated)
+	}
+	if len(applied) == 0 {
+		if len(ignored) > 0 || len(rotated) > 0 {
+			w.observe("rejected")
+		} else {
+			w.observe("unchanged")
+		}
+		return nil
+	}
+
+	w.cur.Store(&next)
+	w.logger.Info("config reloaded", "changes", describeChanges(old, &next, applied))
+	w.observe("applied")
+	for _, fn := range w.subs {
+		fn(&next)
+	}
+	return nil
+}
+
+// Run reloads on SIGHUP (fetching every secret again), whenever a config file
+// changes and when cached secrets expire, until ctx is done. Directories are
+// watched rather than files so that editors replacing the file and Kubernetes
+// ConfigMap symlink swaps are seen too.
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var (
+		events <-chan fsnotify.Event
+		errs   <-chan error
+	)
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.logger.Warn("config file watching disabled", "error", err)
+	} else {
+		defer fw.Close()
+		for _, dir := range configDirs {
+			if _, err := os.Stat(dir); err == nil {
+				if err := fw.Add(dir); err != nil {
+					w.logger.Warn("cannot watch config directory", "dir", dir, "error", err)
+				}
+			}
+		}
+		events, errs = fw.Events, fw.Errors
+	}
+
+	// Reload when cached secrets expire, so rotated ones are picked up. Only
+	// reloadable keys switch to them; the others are logged as needing a
+	// restart.
+	var refresh <-chan time.Time
+	if cur := w.Current(); len(cur.secretRefs) > 0 && cur.Secrets.CacheTTL > 0 {
+		t := time.NewTicker(cur.Secrets.CacheTTL)
+		defer t.Stop()
+		refresh = t.C
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			w.logger.Info("SIGHUP received, reloading config and secrets")
+			w.secrets.Expire()
+			_ = w.Reload()
+		case <-refresh:
+			_ = w.Reload()
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if isConfigFile(ev.Name) {
+				debounce = time.After(reloadDebounce)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			w.logger.Warn("config file watcher error", "error", err)
+		case <-debounce:
+			debounce = nil
+			_ = w.Reload()
+		}
+	}
+}
+
+func (w *Watcher) observe(result string) {
+	if w.observer != nil {
+		w.observer.ObserveReload(result)
+	}
+}
+
+// isConfigFile matches config.yaml, config.<profile>.yaml and the ..data
+// symlink Kubernetes swaps when a ConfigMap changes.
+func isConfigFile(name string) bool {
+	base := filepath.Base(name)
+	if base == "..data" {
+		return true
+	}
+	ext := filepath.Ext(base)
+	return strings.HasPrefix(base, "config") && (ext == ".yaml" || ext == ".yml")
+}
+
+// changedKeys lists the keys whose values differ, sorted.
+func changedKeys(a, b *Config) []string {
+	before := values(a)
+	var changed []string
+	for path, v := range values(b) {
+		if !reflect.DeepEqual(before[path], v) {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func values(c *Config) map[string]any {
+	m := map[string]any{}
+	walkValues(reflect.ValueOf(c).Elem(), "", func(path string, v reflect.Value) { m[path] = plain(v) })
+	return m
+}
+
+// copyKey sets the key at path of dst to its value in src.
+func copyKey(dst, src *Config, path string) {
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for _, name := range strings.Split(path, ".") {
+		i := fieldIndex(d.Type(), name)
+		if i < 0 {
+			return
+		}
+		d, s = d.Field(i), s.Field(i)
+	}
+	d.Set(s)
+}
+
+func fieldIndex(t reflect.Type, name string) int {
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("mapstructure"), ",")[0] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// describeChanges renders "key: old -> new" for the log, secrets redacted.
+func describeChanges(old, next *Config, paths []string) []string {
+	before, after := values(old), values(next)
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		_, wasRef := old.secretRefs[p]
+		_, isRef := next.secretRefs[p]
+		if wasRef || isRef {
+			out = append(out, p+": <redacted>")
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s: %v -> %v", p, redact(p, before[p]), redact(p, after[p])))
+	}
+	return out
+}
+
+func cloneMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
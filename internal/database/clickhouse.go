@@ -0,0 +1,309 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"modelo-mcp/internal/config"
+	"modelo-mcp/internal/metrics"
+)
+
+// NewClickHouseConnection opens ClickHouse with cfg and pings it, so a
+// wrong address or credentials fail at startup.
+func NewClickHouseConnection(ctx context.Context, cfg config.ClickHouseConfig) (clickhouse.Conn, error) {
+	opts, err := clickHouseOptions(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure ClickHouse: %w", err)
+	}
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ClickHouse: %w", err)
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, cfg.DialTimeout)
+	defer cancel()
+	if err := conn.Ping(pingCtx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping ClickHouse: %w", err)
+	}
+	return conn, nil
+}
+
+func clickHouseOptions(cfg config.ClickHouseConfig) (*clickhouse.Options, error) {
+	opts := &clickhouse.Options{}
+	if strings.Contains(cfg.URL, "://") {
+		parsed, err := clickhouse.ParseDSN(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		opts = parsed
+	} else {
+		for _, addr := range strings.Split(cfg.URL, ",") {
+			opts.Addr = append(opts.Addr, strings.TrimSpace(addr))
+		}
+	}
+	if cfg.Database != "" {
+		opts.Auth.Database = cfg.Database
+	}
+	if cfg.Username != "" {
+		opts.Auth.Username = cfg.Username
+	}
+	if cfg.Password != "" {
+		opts.Auth.Password = cfg.Password
+	}
+	opts.DialTimeout = cfg.DialTimeout
+	opts.ReadTimeout = cfg.ReadTimeout
+
+	switch cfg.Compression {
+	case "lz4":
+		opts.Compression = &clickhouse.Compression{Method: clickhouse.CompressionLZ4}
+	case "zstd":
+		opts.Compression = &clickhouse.Compression{Method: clickhouse.CompressionZSTD}
+	case "none":
+		opts.Compression = nil
+	}
+
+	if cfg.TLS.Enabled {
+		tlsCfg, err := clickHouseTLS(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLS = tlsCfg
+	}
+	return opts, nil
+}
+
+func clickHouseTLS(cfg config.ClickHouseTLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+var (
+	// ErrBufferFull is returned by BatchWriter.Write when the row was dropped
+	// because the buffer stayed full.
+	ErrBufferFull = errors.New("clickhouse batch buffer full")
+	// ErrWriterClosed is returned by BatchWriter.Write after Close.
+	ErrWriterClosed = errors.New("clickhouse batch writer closed")
+)
+
+// BatchWriter buffers rows for one ClickHouse table in memory and inserts
+// them in batches from a background goroutine. While ClickHouse is slow or
+// down the buffer fills up and Write blocks, up to the enqueue timeout,
+// before dropping rows: analytics never hold requests for long.
+type BatchWriter struct {
+	conn    clickhouse.Conn
+	table   string
+	cfg     config.ClickHouseBatchConfig
+	metrics *metrics.ClickHouseMetrics
+	logger  *slog.Logger
+
+	mu     sync.RWMutex // guards closed against sends on rows
+	closed bool
+	rows   chan []any
+	done   chan struct{}
+
+	// ctx bounds inserts and retry waits; it is cancelled when Close gives
+	// up, so the remaining rows are dropped instead of retried.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewBatchWriter starts a writer inserting into table, whose columns the
+// values passed to Write must match in order. Close it on shutdown to flush
+// the buffered rows.
+func NewBatchWriter(conn clickhouse.Conn, table string, cfg config.ClickHouseBatchConfig, m *metrics.ClickHouseMetrics, logger *slog.Logger) *BatchWriter {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &BatchWriter{
+		conn:    conn,
+		table:   table,
+		cfg:     cfg,
+		metrics: m,
+		logger:  logger.With("table", table),
+		rows:    make(chan []any, cfg.BufferSize),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go w.run()
+	return w
+}
+
+// Write buffers one row. It returns ErrBufferFull when the row was dropped
+// because the buffer stayed full for the enqueue timeout or until ctx was
+// done.
+func (w *BatchWriter) Write(ctx context.Context, row ...any) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	select {
+	case w.rows <- row:
+		w.metrics.Buffered.WithLabelValues(w.table).Inc()
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(w.cfg.EnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case w.rows <- row:
+		w.metrics.Buffered.WithLabelValues(w.table).Inc()
+		return nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	w.metrics.Dropped.WithLabelValues(w.table, "buffer_full").Inc()
+	return ErrBufferFull
+}
+
+// Close stops accepting rows and waits until the buffered ones are flushed
+// or ctx is done, in which case pending retries stop and the rows left are
+// dropped.
+func (w *BatchWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.rows)
+	}
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		return ctx.Err()
+	}
+}
+
+func (w *BatchWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]any, 0, w.cfg.Size)
+	for {
+		select {
+		case row, ok := <-w.rows:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, row)
+			if len(batch) < w.cfg.Size {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		w.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush inserts rows, retrying with exponential backoff. Rows that still
+// fail are dropped; a row ClickHouse cannot accept is dropped alone and the
+// others are sent without it.
+func (w *BatchWriter) flush(rows [][]any) {
+	if len(rows) == 0 {
+		return
+	}
+	defer w.metrics.Buffered.WithLabelValues(w.table).Sub(float64(len(rows)))
+
+	backoff := w.cfg.RetryBackoff
+	for attempt := 0; len(rows) > 0; {
+		start := time.Now()
+		err := w.send(rows)
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		w.metrics.FlushDuration.WithLabelValues(w.table, result).Observe(time.Since(start).Seconds())
+		if err == nil {
+			return
+		}
+
+		var invalid *invalidRowError
+		if errors.As(err, &invalid) {
+			w.logger.Error("clickhouse row dropped, invalid", "row", invalid.index, "error", invalid.err)
+			w.metrics.Dropped.WithLabelValues(w.table, "invalid").Inc()
+			rows = append(rows[:invalid.index], rows[invalid.index+1:]...)
+			continue
+		}
+		if attempt >= w.cfg.MaxRetries {
+			w.logger.Error("clickhouse batch dropped", "rows", len(rows), "attempts", attempt+1, "error", err)
+			w.metrics.Dropped.WithLabelValues(w.table, "flush_failed").Add(float64(len(rows)))
+			return
+		}
+		w.logger.Warn("clickhouse batch flush failed, retrying", "rows", len(rows), "attempt", attempt+1, "backoff", backoff, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-w.ctx.Done():
+			timer.Stop()
+			w.logger.Error("clickhouse batch dropped, writer closed", "rows", len(rows), "error", err)
+			w.metrics.Dropped.WithLabelValues(w.table, "flush_failed").Add(float64(len(rows)))
+			return
+		}
+		backoff *= 2
+		attempt++
+	}
+}
+
+// invalidRowError marks the row at index that the batch rejected; retrying
+// it cannot help.
+type invalidRowError struct {
+	index int
+	err   error
+}
+
+func (e *invalidRowError) Error() string { return fmt.Sprintf("row %d: %v", e.index, e.err) }
+func (e *invalidRowError) Unwrap() error { return e.err }
+
+// send inserts rows as one batch. A batch is not reused after a failed
+// Append, whose column state the driver does not roll back.
+func (w *BatchWriter) send(rows [][]any) error {
+	ctx, cancel := context.WithTimeout(w.ctx, w.cfg.FlushTimeout)
+	defer cancel()
+	batch, err := w.conn.PrepareBatch(ctx, "INSERT INTO "+w.table)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		if err := batch.Append(row...); err != nil {
+			_ = batch.Abort()
+			return &invalidRowError{index: i, err: err}
+		}
+	}
+	return batch.Send()
+}
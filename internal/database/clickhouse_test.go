@@ -0,0 +1,245 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"modelo-mcp/internal/config"
+	"modelo-mcp/internal/metrics"
+)
+
+// fakeConn accepts batches in memory. PrepareBatch fails while failures is
+// positive, and rows whose first value is "bad" fail to Append.
+type fakeConn struct {
+	driver.Conn
+	sends chan [][]any
+
+	mu       sync.Mutex
+	failures int
+	prepared int
+}
+
+func newFakeConn(failures int) *fakeConn {
+	return &fakeConn{sends: make(chan [][]any, 16), failures: failures}
+}
+
+func (c *fakeConn) PrepareBatch(ctx context.Context, _ string, _ ...driver.PrepareBatchOption) (driver.Batch, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prepared++
+	if c.failures != 0 {
+		c.failures--
+		return nil, errors.New("connection refused")
+	}
+	return &fakeBatch{conn: c}, nil
+}
+
+func (c *fakeConn) attempts() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.prepared
+}
+
+type fakeBatch struct {
+	driver.Batch
+	conn *fakeConn
+	rows [][]any
+}
+
+func (b *fakeBatch) Append(v ...any) error {
+	if v[0] == "bad" {
+		return errors.New("cannot convert bad to UInt64")
+	}
+	b.rows = append(b.rows, v)
+	return nil
+}
+
+func (b *fakeBatch) Abort() error { return nil }
+
+func (b *fakeBatch) Send() error {
+	b.conn.sends <- b.rows
+	return nil
+}
+
+func testBatchConfig() config.ClickHouseBatchConfig {
+	return config.ClickHouseBatchConfig{
+		Size:           3,
+		FlushInterval:  time.Hour,
+		FlushTimeout:   time.Second,
+		BufferSize:     10,
+		EnqueueTimeout: 10 * time.Millisecond,
+		MaxRetries:     2,
+		RetryBackoff:   time.Millisecond,
+	}
+}
+
+func newTestWriter(t *testing.T, conn *fakeConn, cfg config.ClickHouseBatchConfig) (*BatchWriter, *metrics.ClickHouseMetrics) {
+	t.Helper()
+	m := metrics.NewClickHouseMetrics(prometheus.NewRegistry())
+	w := NewBatchWriter(conn, "events", cfg, m, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { _ = w.Close(context.Background()) })
+	return w, m
+}
+
+func write(t *testing.T, w *BatchWriter, values ...any) {
+	t.Helper()
+	for _, v := range values {
+		if err := w.Write(context.Background(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func nextSend(t *testing.T, conn *fakeConn) [][]any {
+	t.Helper()
+	select {
+	case rows := <-conn.sends:
+		return rows
+	case <-time.After(2 * time.Second):
+		t.Fatal("no batch sent")
+		return nil
+	}
+}
+
+func firstValues(rows [][]any) []any {
+	out := make([]any, len(rows))
+	for i, r := range rows {
+		out[i] = r[0]
+	}
+	return out
+}
+
+func TestBatchWriterFlushOnSize(t *testing.T) {
+	conn := newFakeConn(0)
+	w, _ := newTestWriter(t, conn, testBatchConfig())
+
+	write(t, w, 1, 2, 3, 4)
+	if got := firstValues(nextSend(t, conn)); len(got) != 3 || got[0] != 1 || got[2] != 3 {
+		t.Fatalf("first batch = %v, want [1 2 3]", got)
+	}
+	select {
+	case rows := <-conn.sends:
+		t.Fatalf("partial batch %v sent before the interval", firstValues(rows))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBatchWriterFlushOnInterval(t *testing.T) {
+	conn := newFakeConn(0)
+	cfg := testBatchConfig()
+	cfg.FlushInterval = 20 * time.Millisecond
+	w, _ := newTestWriter(t, conn, cfg)
+
+	write(t, w, 1)
+	if got := firstValues(nextSend(t, conn)); len(got) != 1 {
+		t.Fatalf("batch = %v, want [1]", got)
+	}
+}
+
+func TestBatchWriterRetry(t *testing.T) {
+	t.Run("recovers", func(t *testing.T) {
+		conn := newFakeConn(2)
+		w, m := newTestWriter(t, conn, testBatchConfig())
+
+		write(t, w, 1, 2, 3)
+		if got := nextSend(t, conn); len(got) != 3 {
+			t.Fatalf("batch = %v, want 3 rows", firstValues(got))
+		}
+		if n := conn.attempts(); n != 3 {
+			t.Errorf("attempts = %d, want 3", n)
+		}
+		if n := testutil.ToFloat64(m.Dropped.WithLabelValues("events", "flush_failed")); n != 0 {
+			t.Errorf("dropped %v rows", n)
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		conn := newFakeConn(-1)
+		w, m := newTestWriter(t, conn, testBatchConfig())
+
+		write(t, w, 1, 2)
+		if err := w.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if n := conn.attempts(); n != 3 {
+			t.Errorf("attempts = %d, want 1 + MaxRetries", n)
+		}
+		if n := testutil.ToFloat64(m.Dropped.WithLabelValues("events", "flush_failed")); n != 2 {
+			t.Errorf("dropped %v rows, want 2", n)
+		}
+		if n := testutil.ToFloat64(m.Buffered.WithLabelValues("events")); n != 0 {
+			t.Errorf("buffered = %v after drop", n)
+		}
+	})
+}
+
+func TestBatchWriterSkipsInvalidRow(t *testing.T) {
+	conn := newFakeConn(0)
+	w, m := newTestWriter(t, conn, testBatchConfig())
+
+	write(t, w, 1, "bad", 3)
+	got := firstValues(nextSend(t, conn))
+	if len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("batch = %v, want [1 3]", got)
+	}
+	if n := testutil.ToFloat64(m.Dropped.WithLabelValues("events", "invalid")); n != 1 {
+		t.Errorf("dropped %v invalid rows, want 1", n)
+	}
+}
+
+func TestBatchWriterClose(t *testing.T) {
+	t.Run("drains buffered rows", func(t *testing.T) {
+		conn := newFakeConn(0)
+		w, m := newTestWriter(t, conn, testBatchConfig())
+
+		write(t, w, 1, 2, 3, 4, 5)
+		if err := w.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		close(conn.sends)
+		var sent []any
+		for rows := range conn.sends {
+			sent = append(sent, firstValues(rows)...)
+		}
+		if len(sent) != 5 {
+			t.Errorf("sent %v, want all 5 rows", sent)
+		}
+		if n := testutil.ToFloat64(m.Buffered.WithLabelValues("events")); n != 0 {
+			t.Errorf("buffered = %v after Close", n)
+		}
+		if err := w.Write(context.Background(), 6); !errors.Is(err, ErrWriterClosed) {
+			t.Errorf("Write after Close: err = %v, want ErrWriterClosed", err)
+		}
+	})
+
+	t.Run("deadline stops the retry backoff", func(t *testing.T) {
+		conn := newFakeConn(-1)
+		cfg := testBatchConfig()
+		cfg.RetryBackoff = time.Hour
+		w, m := newTestWriter(t, conn, cfg)
+
+		write(t, w, 1)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := w.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want DeadlineExceeded", err)
+		}
+		select {
+		case <-w.done:
+		case <-time.After(time.Second):
+			t.Fatal("writer still waiting out the backoff")
+		}
+		if n := testutil.ToFloat64(m.Dropped.WithLabelValues("events", "flush_failed")); n != 1 {
+			t.Errorf("dropped %v rows, want 1", n)
+		}
+	})
+}
@@ -3,54 +3,72 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"time"
+	"log/slog"
 
-	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/go-redis/redis/v8"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-)
+	"gorm.io/plugin/dbresolver"
 
-// NewConnection creates a new PostgreSQL database connection
-func NewConnection(databaseURL string) (*gorm.DB, error) {
-	config := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	}
+	"modelo-mcp/internal/config"
+)
 
-	db, err := gorm.Open(postgres.Open(databaseURL), config)
+// NewConnection opens PostgreSQL with the pool settings of cfg and GORM
+// logging through logger. With cfg.Replicas, reads outside transactions go
+// to a random replica and everything else to the primary; use
+// db.Clauses(dbresolver.Write) to read from the primary explicitly.
+func NewConnection(cfg config.DatabaseConfig, logger *slog.Logger) (*gorm.DB, error) {
+	primary, err := openPool(cfg, cfg.URL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-
-	sqlDB, err := db.DB()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: primary}), &gorm.Config{
+		Logger: NewGormLogger(logger, cfg.LogLevel, cfg.SlowQueryThreshold),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
+		primary.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	// Configure connection pool
-	sqlDB.SetMaxOpenConns(25)
-	sqlDB.SetMaxIdleConns(25)
-	sqlDB.SetConnMaxLifetime(5 * time.Minute)
-
+	p := &pools{named: []namedPool{{name: "primary", db: primary}}}
+	if len(cfg.Replicas) > 0 {
+		var replicas []gorm.Dialector
+		for i, url := range cfg.Replicas {
+			replica, err := openPool(cfg, url)
+			if err != nil {
+				p.close()
+				return nil, fmt.Errorf("failed to connect to replica %d: %w", i, err)
+			}
+			p.named = append(p.named, namedPool{name: fmt.Sprintf("replica-%d", i), db: replica})
+			replicas = append(replicas, postgres.New(postgres.Config{Conn: replica}))
+		}
+		err := db.Use(dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		}))
+		if err != nil {
+			p.close()
+			return nil, fmt.Errorf("failed to register read replicas: %w", err)
+		}
+	}
+	if err := db.Use(p); err != nil {
+		p.close()
+		return nil, err
+	}
 	return db, nil
 }
 
-// NewClickHouseConnection creates a new ClickHouse database connection
-func NewClickHouseConnection(url string) (clickhouse.Conn, error) {
-	conn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{url},
-		Auth: clickhouse.Auth{
-			Database: "{{CLICKHOUSE_DATABASE}}",
-			Username: "{{CLICKHOUSE_USERNAME}}",
-			Password: "{{CLICKHOUSE_PASSWORD}}",
-		},
-	})
+// openPool opens one connection pool with the limits of cfg.
+func openPool(cfg config.DatabaseConfig, url string) (*sql.DB, error) {
+	sqlDB, err := sql.Open("pgx", url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to ClickHouse: %w", err)
+		return nil, err
 	}
-
-	return conn, nil
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return sqlDB, nil
 }
 
 // NewRedisClient creates a new Redis client
@@ -64,29 +82,9 @@ func NewRedisClient(redisURL string) (*redis.Client, error) {
 	return client, nil
 }
 
-// RunMigrations runs database migrations
-func RunMigrations(db *gorm.DB) error {
-	// Auto-migrate models (to be customized per MCP)
-	err := db.AutoMigrate(
-		// Add your models here
-		// &models.{{MODEL_NAME}}{},
-	)
-	if err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
-	}
-
-	return nil
-}
-
 // Additional connection helpers for service-specific databases
 // These will be customized based on MCP requirements
 
-// NewVaultClient creates a new Vault client (for security MCPs)
-func NewVaultClient(config interface{}) (interface{}, error) {
-	// Implementation depends on specific Vault configuration
-	return nil, nil
-}
-
 // NewMinIOClient creates a new MinIO client (for storage MCPs)
 func NewMinIOClient(config interface{}) (interface{}, error) {
 	// Implementation depends on specific MinIO configuration
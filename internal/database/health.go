@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// PostgresCheck pings the database behind db.
+func PostgresCheck(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// RedisCheck pings the Redis server.
+func RedisCheck(client *redis.Client) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// ClickHouseCheck pings the ClickHouse server.
+func ClickHouseCheck(conn clickhouse.Conn) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return conn.Ping(ctx)
+	}
+}
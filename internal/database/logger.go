@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// GormLogger routes GORM logs to slog. Failed queries are logged as errors,
+// queries slower than the threshold as warnings and, at the info level,
+// every query at debug.
+type GormLogger struct {
+	logger *slog.Logger
+	level  logger.LogLevel
+	slow   time.Duration
+}
+
+// NewGormLogger returns a GORM logger at level ("silent", "error", "warn" or
+// "info"). A zero slow disables slow-query warnings.
+func NewGormLogger(l *slog.Logger, level string, slow time.Duration) *GormLogger {
+	lvl := logger.Warn
+	switch level {
+	case "silent":
+		lvl = logger.Silent
+	case "error":
+		lvl = logger.Error
+	case "info":
+		lvl = logger.Info
+	}
+	return &GormLogger{logger: l.With("component", "gorm"), level: lvl, slow: slow}
+}
+
+func (g *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	c := *g
+	c.level = level
+	return &c
+}
+
+func (g *GormLogger) Info(ctx context.Context, msg string, args ...any) {
+	if g.level >= logger.Info {
+		g.logger.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g *GormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if g.level >= logger.Warn {
+		g.logger.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g *GormLogger) Error(ctx context.Context, msg string, args ...any) {
+	if g.level >= logger.Error {
+		g.logger.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if g.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && g.level >= logger.Error:
+		query, rows := fc()
+		g.logger.ErrorContext(ctx, "query failed", "sql", query, "rows", rows, "duration_ms", elapsed.Milliseconds(), "error", err)
+	case g.slow > 0 && elapsed > g.slow && g.level >= logger.Warn:
+		query, rows := fc()
+		g.logger.WarnContext(ctx, "slow query", "sql", query, "rows", rows, "duration_ms", elapsed.Milliseconds(), "threshold_ms", g.slow.Milliseconds())
+	case g.level >= logger.Info:
+		query, rows := fc()
+		g.logger.DebugContext(ctx, "query", "sql", query, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	}
+}
@@ -0,0 +1,348 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"modelo-mcp/internal/config"
+	"modelo-mcp/migrations"
+)
+
+// migrationFile matches <version>_<name>.up.sql and <version>_<name>.down.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// migrationLock is the advisory lock key held while migrating, so replicas
+// starting together apply each migration once.
+var migrationLock = func() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("schema_migrations"))
+	return int64(h.Sum64())
+}()
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of Up
+}
+
+// MigrationStatus is the state of one migration: "applied", "pending",
+// "modified" (its file changed after it was applied) or "missing" (applied,
+// but no longer in the source).
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	State     string
+	AppliedAt *time.Time
+}
+
+// MigrationSource returns the migrations to run: the directory path, or the
+// set embedded in the binary when path is empty.
+func MigrationSource(path string) fs.FS {
+	if path == "" {
+		return migrations.FS
+	}
+	return os.DirFS(path)
+}
+
+// LoadMigrations reads the migrations of source, sorted by version. Every
+// version needs an up file; a missing down file only fails a rollback.
+func LoadMigrations(source fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		body, err := fs.ReadFile(source, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", e.Name(), err)
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+			sum := sha256.Sum256(body)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		out = append(out, *mig)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Migrator applies and rolls back migrations on the primary database,
+// recording them in schema_migrations. Each migration runs in its own
+// transaction while a session advisory lock is held.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+// NewMigrator loads the migrations of source for db, a handle from
+// NewConnection.
+func NewMigrator(db *gorm.DB, source fs.FS, logger *slog.Logger) (*Migrator, error) {
+	migs, err := LoadMigrations(source)
+	if err != nil {
+		return nil, err
+	}
+	p := poolsOf(db)
+	if len(p.named) == 0 {
+		return nil, errors.New("migrate: no database connection")
+	}
+	return &Migrator{db: p.named[0].db, migrations: migs, logger: logger}, nil
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies every pending migration in order and returns how many ran. It
+// refuses to run when an applied migration was modified.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	n := 0
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if a, ok := applied[mig.Version]; ok {
+				if a.checksum != mig.Checksum {
+					return fmt.Errorf("migration %d_%s was modified after it was applied", mig.Version, mig.Name)
+				}
+				continue
+			}
+			start := time.Now()
+			err := m.inTx(ctx, conn, mig.Up,
+				`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+				mig.Version, mig.Name, mig.Checksum)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			m.logger.Info("migration applied", "version", mig.Version, "name", mig.Name, "duration_ms", time.Since(start).Milliseconds())
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns how many were rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	byVersion := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		byVersion[mig.Version] = mig
+	}
+	n := 0
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, v := range versions {
+			if n == steps {
+				break
+			}
+			mig, ok := byVersion[v]
+			if !ok {
+				return fmt.Errorf("migration %d_%s is not in the source, cannot roll it back", v, applied[v].name)
+			}
+			if strings.TrimSpace(mig.Down) == "" {
+				return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+			}
+			start := time.Now()
+			err := m.inTx(ctx, conn, mig.Down, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+			if err != nil {
+				return fmt.Errorf("rollback %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			m.logger.Info("migration rolled back", "version", mig.Version, "name", mig.Name, "duration_ms", time.Since(start).Milliseconds())
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Status lists every migration of the source and every applied one, sorted
+// by version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var out []MigrationStatus
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			s := MigrationStatus{Version: mig.Version, Name: mig.Name, State: "pending"}
+			if a, ok := applied[mig.Version]; ok {
+				s.State, s.AppliedAt = "applied", &a.appliedAt
+				if a.checksum != mig.Checksum {
+					s.State = "modified"
+				}
+				delete(applied, mig.Version)
+			}
+			out = append(out, s)
+		}
+		for v, a := range applied {
+			at := a.appliedAt
+			out = append(out, MigrationStatus{Version: v, Name: a.name, State: "missing", AppliedAt: &at})
+		}
+		return nil
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, err
+}
+
+// locked runs fn on one connection holding the migration advisory lock,
+// waiting for another instance to finish first.
+func (m *Migrator) locked(ctx context.Context, fn func(*sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+		return fmt.Errorf("migrate: acquire lock: %w", err)
+	}
+	// Unlock even if ctx was cancelled; the lock would otherwise live as
+	// long as the pooled connection.
+	defer func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLock)
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		checksum   TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("migrate: create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	out := map[int64]appliedMigration{}
+	for rows.Next() {
+		var v int64
+		var a appliedMigration
+		if err := rows.Scan(&v, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("migrate: read schema_migrations: %w", err)
+		}
+		out[v] = a
+	}
+	return out, rows.Err()
+}
+
+// inTx runs script and then the bookkeeping statement in one transaction.
+func (m *Migrator) inTx(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RunMigrations applies the pending migrations of cfg.MigrationsPath, or the
+// embedded ones, on startup.
+func RunMigrations(ctx context.Context, db *gorm.DB, cfg config.DatabaseConfig, logger *slog.Logger) error {
+	m, err := NewMigrator(db, MigrationSource(cfg.MigrationsPath), logger)
+	if err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	return nil
+}
+
+// CreateMigration writes empty up and down files for name in dir, numbered
+// after the highest version there, and returns their paths.
+func CreateMigration(dir, name string) (up, down string, err error) {
+	name = strings.ToLower(strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}), "_"))
+	if name == "" {
+		return "", "", errors.New("migration name is empty")
+	}
+	existing, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	version := int64(1)
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%06d_%s", version, name))
+	up, down = base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- Revert "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
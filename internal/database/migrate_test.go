@@ -0,0 +1,280 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"gorm.io/gorm"
+
+	"modelo-mcp/internal/config"
+	"modelo-mcp/migrations"
+)
+
+func sha(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func file(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+func TestLoadMigrations(t *testing.T) {
+	source := fstest.MapFS{
+		"000010_add_index.up.sql":    file("CREATE INDEX i ON t (c);"),
+		"000002_create_t.up.sql":     file("CREATE TABLE t (c INT);"),
+		"000002_create_t.down.sql":   file("DROP TABLE t;"),
+		"1_first.up.sql":             file("SELECT 1;"),
+		"README.md":                  file("not a migration"),
+		"000003_Mixed_Case.up.sql":   file("SELECT 3;"),
+		"000004_no_direction.sql":    file("SELECT 4;"),
+		"000005_x.sideways.sql":      file("SELECT 5;"),
+		"nested/000006_deep.up.sql":  file("SELECT 6;"),
+		"000007_dir.up.sql/keep.txt": file(""),
+	}
+	got, err := LoadMigrations(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "first", Up: "SELECT 1;", Checksum: sha("SELECT 1;")},
+		{Version: 2, Name: "create_t", Up: "CREATE TABLE t (c INT);", Down: "DROP TABLE t;", Checksum: sha("CREATE TABLE t (c INT);")},
+		// No down file: only a rollback of it fails.
+		{Version: 10, Name: "add_index", Up: "CREATE INDEX i ON t (c);", Checksum: sha("CREATE INDEX i ON t (c);")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d migrations %+v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  fstest.MapFS
+		wantErr string
+	}{
+		{
+			name:    "down without up",
+			source:  fstest.MapFS{"000001_a.up.sql": file("SELECT 1;"), "000002_b.down.sql": file("SELECT 2;")},
+			wantErr: "migration 2_b has no up file",
+		},
+		{
+			name:    "empty up",
+			source:  fstest.MapFS{"000001_a.up.sql": file("")},
+			wantErr: "migration 1_a has no up file",
+		},
+		{
+			name:    "two names for a version",
+			source:  fstest.MapFS{"000001_a.up.sql": file("SELECT 1;"), "000001_b.down.sql": file("SELECT 1;")},
+			wantErr: "migration 1 has two names: a and b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadMigrations(tt.source); err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMigrationChecksum(t *testing.T) {
+	load := func(up, down string) string {
+		t.Helper()
+		migs, err := LoadMigrations(fstest.MapFS{"000001_a.up.sql": file(up), "000001_a.down.sql": file(down)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return migs[0].Checksum
+	}
+	sum := load("CREATE TABLE t (c INT);", "DROP TABLE t;")
+	if load("CREATE TABLE t (c INT);", "DROP TABLE IF EXISTS t;") != sum {
+		t.Error("editing the down file changed the checksum")
+	}
+	if load("CREATE TABLE t (c BIGINT);", "DROP TABLE t;") == sum {
+		t.Error("editing the up file kept the checksum")
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migs, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migs) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for i, m := range migs {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d_%s: versions must follow each other from 1", m.Version, m.Name)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+
+	up, down, err := CreateMigration(dir, "Add users-table!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up != filepath.Join(dir, "000001_add_users_table.up.sql") || down != filepath.Join(dir, "000001_add_users_table.down.sql") {
+		t.Fatalf("created %s and %s", up, down)
+	}
+	if b, _ := os.ReadFile(up); string(b) != "-- add_users_table\n" {
+		t.Errorf("up file = %q", b)
+	}
+
+	// Numbered after the highest version, not the count.
+	if err := os.WriteFile(filepath.Join(dir, "000007_seed.up.sql"), []byte("SELECT 7;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if up, _, err = CreateMigration(dir, "audit log"); err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(up) != "000008_audit_log.up.sql" {
+		t.Errorf("created %s, want 000008_audit_log.up.sql", filepath.Base(up))
+	}
+
+	// What it writes loads back; the placeholder bodies are not empty.
+	migs, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migs) != 3 || migs[2].Version != 8 || migs[2].Down == "" {
+		t.Errorf("migrations = %+v", migs)
+	}
+
+	if _, _, err := CreateMigration(dir, " -!- "); err == nil {
+		t.Error("want an error for an empty name")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "000009_orphan.down.sql"), []byte("SELECT 9;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := CreateMigration(dir, "next"); err == nil {
+		t.Error("want an error for a directory with a broken migration")
+	}
+	if _, _, err := CreateMigration(filepath.Join(dir, "missing"), "next"); err == nil {
+		t.Error("want an error for a missing directory")
+	}
+}
+
+// openMigrateDB returns a handle on a fresh migrate_test schema of the
+// PostgreSQL database at TEST_DATABASE_URL.
+func openMigrateDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", "migrate_test")
+	u.RawQuery = q.Encode()
+
+	db, err := NewConnection(config.DatabaseConfig{URL: u.String(), MaxOpenConns: 2, LogLevel: "silent"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = Close(db) })
+	for _, stmt := range []string{`DROP SCHEMA IF EXISTS migrate_test CASCADE`, `CREATE SCHEMA migrate_test`} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { db.Exec(`DROP SCHEMA IF EXISTS migrate_test CASCADE`) })
+	return db
+}
+
+func TestMigrator(t *testing.T) {
+	db := openMigrateDB(t)
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	migrator := func(source fstest.MapFS) *Migrator {
+		t.Helper()
+		m, err := NewMigrator(db, source, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	states := func(m *Migrator) string {
+		t.Helper()
+		status, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, s := range status {
+			out = append(out, s.Name+"="+s.State)
+		}
+		return strings.Join(out, " ")
+	}
+
+	source := fstest.MapFS{
+		"000001_create_t.up.sql":   file("CREATE TABLE t (c INT);"),
+		"000001_create_t.down.sql": file("DROP TABLE t;"),
+		"000002_add_d.up.sql":      file("ALTER TABLE t ADD COLUMN d INT;"),
+	}
+	m := migrator(source)
+	if got := states(m); got != "create_t=pending add_d=pending" {
+		t.Errorf("status = %s", got)
+	}
+	if n, err := m.Up(ctx); err != nil || n != 2 {
+		t.Fatalf("Up = %d, %v; want 2 applied", n, err)
+	}
+	if n, err := m.Up(ctx); err != nil || n != 0 {
+		t.Fatalf("second Up = %d, %v; want nothing to do", n, err)
+	}
+
+	// No down file: the rollback stops before touching the schema.
+	if n, err := m.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "2_add_d has no down file") || n != 0 {
+		t.Fatalf("Down = %d, %v; want the missing down file reported", n, err)
+	}
+
+	// An applied migration edited afterwards blocks Up.
+	modified := fstest.MapFS{}
+	for k, v := range source {
+		modified[k] = v
+	}
+	modified["000002_add_d.up.sql"] = file("ALTER TABLE t ADD COLUMN d BIGINT;")
+	modified["000003_add_e.up.sql"] = file("ALTER TABLE t ADD COLUMN e INT;")
+	m = migrator(modified)
+	if got := states(m); got != "create_t=applied add_d=modified add_e=pending" {
+		t.Errorf("status = %s", got)
+	}
+	if _, err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "2_add_d was modified after it was applied") {
+		t.Fatalf("Up = %v, want a checksum mismatch", err)
+	}
+	if err := db.Exec(`SELECT e FROM t`).Error; err == nil {
+		t.Error("000003 applied past the modified migration")
+	}
+
+	// A migration dropped from the source is reported and cannot be rolled
+	// back.
+	m = migrator(fstest.MapFS{"000001_create_t.up.sql": source["000001_create_t.up.sql"], "000001_create_t.down.sql": source["000001_create_t.down.sql"]})
+	if got := states(m); got != "create_t=applied add_d=missing" {
+		t.Errorf("status = %s", got)
+	}
+	if _, err := m.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "not in the source") {
+		t.Fatalf("Down = %v, want the missing migration reported", err)
+	}
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"gorm.io/gorm"
+)
+
+// OutboxEvent is an event waiting to be published to NATS. It is written in
+// the same transaction as the business change, so the event exists if and
+// only if the change was committed. The relay in internal/nats publishes
+// pending rows with the service name and ID as Nats-Msg-Id, letting JetStream
+// drop duplicates. An event that keeps failing is parked with FailedAt set
+// and no longer published; clear failed_at and attempts to retry it. The
+// table is created by migrations/000001_create_outbox_events.up.sql.
+type OutboxEvent struct {
+	ID          uint64     `gorm:"primaryKey;autoIncrement"`
+	Subject     string     `gorm:"size:255;not null"`
+	Payload     []byte     `gorm:"type:jsonb;not null"`
+	Headers     []byte     `gorm:"type:jsonb"`
+	CreatedAt   time.Time  `gorm:"not null;index:idx_outbox_events_pending,where:published_at IS NULL AND failed_at IS NULL"`
+	PublishedAt *time.Time `gorm:"index"`
+	FailedAt    *time.Time
+	Attempts    int    `gorm:"not null;default:0"`
+	LastError   string `gorm:"type:text"`
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// HeaderMap decodes the stored NATS headers.
+func (e *OutboxEvent) HeaderMap() (map[string]string, error) {
+	if len(e.Headers) == 0 {
+		return nil, nil
+	}
+	var h map[string]string
+	if err := json.Unmarshal(e.Headers, &h); err != nil {
+		return nil, fmt.Errorf("decode outbox headers: %w", err)
+	}
+	return h, nil
+}
+
+// RecordEvent stores payload (JSON-encoded) for subject in the outbox using
+// tx, which must be the transaction of the business write. The trace context
+// of the transaction (db.WithContext) is stored with the headers so consumers
+// continue the trace that produced the event:
+//
+//	err := db.Transaction(func(tx *gorm.DB) error {
+//		if err := tx.Create(&order).Error; err != nil {
+//			return err
+//		}
+//		_, err := database.RecordEvent(tx, "mcp.modelo.events.order_created", order, nil)
+//		return err
+//	})
+func RecordEvent(tx *gorm.DB, subject string, payload any, headers map[string]string) (*OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode outbox payload: %w", err)
+	}
+	event := &OutboxEvent{Subject: subject, Payload: data}
+	if ctx := tx.Statement.Context; ctx != nil {
+		carrier := propagation.MapCarrier{}
+		otel.GetTextMapPropagator().Inject(ctx, carrier)
+		if len(carrier) > 0 {
+			merged := make(map[string]string, len(headers)+len(carrier))
+			for k, v := range carrier {
+				merged[k] = v
+			}
+			for k, v := range headers {
+				merged[k] = v
+			}
+			headers = merged
+		}
+	}
+	if len(headers) > 0 {
+		if event.Headers, err = json.Marshal(headers); err != nil {
+			return nil, fmt.Errorf("encode outbox headers: %w", err)
+		}
+	}
+	if err := tx.Create(event).Error; err != nil {
+		return nil, fmt.Errorf("record outbox event: %w", err)
+	}
+	return event, nil
+}
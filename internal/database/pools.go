@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const poolsPluginName = "database:pools"
+
+// pools keeps the connection pools behind a *gorm.DB (primary first, then
+// replicas) as a GORM plugin, so they can be found again from the handle.
+type pools struct {
+	named []namedPool
+}
+
+type namedPool struct {
+	name string
+	db   *sql.DB
+}
+
+func (p *pools) Name() string                 { return poolsPluginName }
+func (p *pools) Initialize(db *gorm.DB) error { return nil }
+
+func (p *pools) close() error {
+	var errs []error
+	for _, n := range p.named {
+		errs = append(errs, n.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func poolsOf(db *gorm.DB) *pools {
+	if p, ok := db.Config.Plugins[poolsPluginName].(*pools); ok {
+		return p
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		return &pools{named: []namedPool{{name: "primary", db: sqlDB}}}
+	}
+	return &pools{}
+}
+
+// Close closes every connection pool of a db from NewConnection.
+func Close(db *gorm.DB) error {
+	return poolsOf(db).close()
+}
+
+// ReportPoolStats passes the open, in-use and idle connection counts of every
+// pool of db ("primary", "replica-0", ...) to report each interval until ctx
+// is done. Pass metrics.SetDatabaseConnections as report.
+func ReportPoolStats(ctx context.Context, db *gorm.DB, interval time.Duration, report func(database, state string, count int)) {
+	p := poolsOf(db)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, n := range p.named {
+			s := n.db.Stats()
+			report(n.name, "open", s.OpenConnections)
+			report(n.name, "in_use", s.InUse)
+			report(n.name, "idle", s.Idle)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNoTenant is returned for a query on a tenant-scoped table outside a
+	// tenant transaction.
+	ErrNoTenant = errors.New("query on a tenant table without a tenant")
+	// ErrTenantScopeEnded is returned by DB once the scope of
+	// WithTenantScope has been ended.
+	ErrTenantScopeEnded = errors.New("tenant transaction already ended")
+)
+
+type (
+	tenantKey      struct{}
+	tenantTxKey    struct{} // the *gorm.DB of a tenant transaction
+	tenantScopeKey struct{} // the *tenantScope of WithTenantScope
+)
+
+// WithTenant returns ctx carrying tenantID for InTenant and WithTenantScope.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set by WithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// InTenant runs fn in a transaction scoped to the tenant of ctx: the
+// transaction starts with the equivalent of SET LOCAL app.tenant_id, which
+// the row-level security policies of tenant tables compare with their
+// tenant_id column (see migrations/000002_tenant_rls.up.sql). The context of
+// tx, and any context derived from it, resolves to tx through DB.
+func InTenant(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrNoTenant
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := setTenant(tx, tenant); err != nil {
+			return err
+		}
+		return fn(tx.WithContext(context.WithValue(ctx, tenantTxKey{}, tx)))
+	})
+}
+
+// setTenant scopes the transaction tx to tenant. SET LOCAL takes no bind
+// parameters; set_config(..., true) does the same.
+func setTenant(tx *gorm.DB, tenant string) error {
+	if err := tx.Exec("SELECT set_config('app.tenant_id', ?, true)", tenant).Error; err != nil {
+		return fmt.Errorf("set tenant: %w", err)
+	}
+	return nil
+}
+
+// DB returns the tenant transaction ctx belongs to, or db when there is
+// none, bound to ctx. Within WithTenantScope the transaction is opened by
+// the first call. Services call it with the request context:
+//
+//	database.DB(ctx, s.db).Find(&orders)
+func DB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(tenantTxKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	if scope, ok := ctx.Value(tenantScopeKey{}).(*tenantScope); ok {
+		tx, err := scope.begin()
+		if err != nil {
+			d := db.WithContext(ctx)
+			_ = d.AddError(err)
+			return d
+		}
+		return tx.WithContext(context.WithValue(ctx, tenantTxKey{}, tx))
+	}
+	return db.WithContext(ctx)
+}
+
+// WithTenantScope returns ctx carrying a transaction scoped to the tenant of
+// ctx, like InTenant, that DB opens on first use: work that never touches
+// the database holds no connection. end commits the transaction when commit
+// is true and rolls it back otherwise; it is a no-op when DB was never
+// called.
+func WithTenantScope(ctx context.Context, db *gorm.DB) (scoped context.Context, end func(commit bool) error) {
+	scope := &tenantScope{db: db, ctx: ctx}
+	return context.WithValue(ctx, tenantScopeKey{}, scope), scope.end
+}
+
+type tenantScope struct {
+	db  *gorm.DB
+	ctx context.Context
+
+	mu    sync.Mutex
+	tx    *gorm.DB
+	ended bool
+}
+
+func (s *tenantScope) begin() (*gorm.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.ended:
+		return nil, ErrTenantScopeEnded
+	case s.tx != nil:
+		return s.tx, nil
+	}
+	tenant, ok := TenantFromContext(s.ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	tx := s.db.WithContext(s.ctx).Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if err := setTenant(tx, tenant); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	s.tx = tx
+	return tx, nil
+}
+
+func (s *tenantScope) end(commit bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+	tx := s.tx
+	s.tx = nil
+	switch {
+	case tx == nil:
+		return nil
+	case commit:
+		return tx.Commit().Error
+	default:
+		return tx.Rollback().Error
+	}
+}
+
+// inTenantTx reports whether ctx belongs to a tenant transaction.
+func inTenantTx(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	_, ok := ctx.Value(tenantTxKey{}).(*gorm.DB)
+	return ok
+}
+
+const tenantGuardName = "database:tenant_guard"
+
+// tenantGuard fails statements on tenant tables that do not run in a tenant
+// transaction from InTenant or WithTenantScope. Row-level security would
+// return no rows for them anyway; the guard turns the silent empty result
+// into ErrNoTenant.
+type tenantGuard struct {
+	tables map[string]bool
+}
+
+func (g *tenantGuard) Name() string { return tenantGuardName }
+
+func (g *tenantGuard) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("tenant:guard", g.check),
+		cb.Query().Before("gorm:query").Register("tenant:guard", g.check),
+		cb.Update().Before("gorm:update").Register("tenant:guard", g.check),
+		cb.Delete().Before("gorm:delete").Register("tenant:guard", g.check),
+		cb.Row().Before("gorm:row").Register("tenant:guard", g.check),
+	)
+}
+
+func (g *tenantGuard) check(db *gorm.DB) {
+	if g.tables[db.Statement.Table] && !inTenantTx(db.Statement.Context) {
+		_ = db.AddError(fmt.Errorf("%w: %s", ErrNoTenant, db.Statement.Table))
+	}
+}
+
+// EnableTenantGuard makes db refuse statements on tenant tables, those with
+// the tenant_isolation policy, outside a tenant transaction. Call it after
+// the migrations ran; tables made tenant-scoped later need a restart. Raw
+// SQL is not checked, only RLS applies to it.
+//
+// It fails when a table has a tenant_id column but no enforced
+// tenant_isolation policy: its migration must call enable_tenant_rls.
+func EnableTenantGuard(ctx context.Context, db *gorm.DB) error {
+	var unprotected []string
+	err := db.WithContext(ctx).Raw(`SELECT c.relname FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_attribute a ON a.attrelid = c.oid AND a.attname = 'tenant_id' AND NOT a.attisdropped
+		WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p') AND NOT c.relispartition
+		AND NOT (c.relrowsecurity AND c.relforcerowsecurity AND EXISTS (
+			SELECT 1 FROM pg_policies p
+			WHERE p.schemaname = n.nspname AND p.tablename = c.relname AND p.policyname = 'tenant_isolation'))
+		ORDER BY c.relname`).
+		Scan(&unprotected).Error
+	if err != nil {
+		return fmt.Errorf("check tenant tables: %w", err)
+	}
+	if len(unprotected) > 0 {
+		return fmt.Errorf("tables with tenant_id but no row-level security: %s (call enable_tenant_rls in their migration)",
+			strings.Join(unprotected, ", "))
+	}
+
+	var tables []string
+	err = db.WithContext(ctx).Raw(`SELECT tablename FROM pg_policies
+		WHERE policyname = 'tenant_isolation' AND schemaname = current_schema()`).
+		Scan(&tables).Error
+	if err != nil {
+		return fmt.Errorf("list tenant tables: %w", err)
+	}
+	g := &tenantGuard{tables: make(map[string]bool, len(tables))}
+	for _, t := range tables {
+		g.tables[t] = true
+	}
+	return db.Use(g)
+}
@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"modelo-mcp/internal/config"
+	"modelo-mcp/migrations"
+)
+
+// The tests below need a disposable PostgreSQL database, reached as a
+// superuser through TEST_DATABASE_URL; they run in their own schema as a
+// role without BYPASSRLS, since RLS never applies to superusers.
+const (
+	testSchema = "tenant_test"
+	testRole   = "tenant_test_app"
+)
+
+type testItem struct {
+	ID       int64
+	TenantID string
+	Name     string
+	Code     *string
+}
+
+func (testItem) TableName() string { return "items" }
+
+// openTestDBs prepares testSchema with an items table created before the
+// migrations run, so 000002 must enable RLS on it, and returns a handle as
+// the schema owner and one as testRole with the tenant guard enabled.
+func openTestDBs(t *testing.T) (admin, app *gorm.DB) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", testSchema)
+	u.RawQuery = q.Encode()
+
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	open := func(maxConns int) *gorm.DB {
+		db, err := NewConnection(config.DatabaseConfig{URL: u.String(), MaxOpenConns: maxConns, MaxIdleConns: maxConns, LogLevel: "silent"}, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = Close(db) })
+		return db
+	}
+
+	admin = open(2)
+	for _, stmt := range []string{
+		`DROP SCHEMA IF EXISTS ` + testSchema + ` CASCADE`,
+		`CREATE SCHEMA ` + testSchema,
+		`CREATE TABLE items (
+			id        BIGSERIAL PRIMARY KEY,
+			tenant_id TEXT NOT NULL,
+			name      TEXT NOT NULL,
+			code      TEXT,
+			CONSTRAINT items_code_key UNIQUE (code) DEFERRABLE INITIALLY DEFERRED)`,
+		`DO $$ BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = '` + testRole + `') THEN
+				CREATE ROLE ` + testRole + ` NOLOGIN NOSUPERUSER NOBYPASSRLS;
+			END IF;
+		END $$`,
+	} {
+		if err := admin.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA IF EXISTS ` + testSchema + ` CASCADE`) })
+
+	m, err := NewMigrator(admin, migrations.FS, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`GRANT USAGE ON SCHEMA ` + testSchema + ` TO ` + testRole,
+		`GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA ` + testSchema + ` TO ` + testRole,
+		`GRANT USAGE ON ALL SEQUENCES IN SCHEMA ` + testSchema + ` TO ` + testRole,
+	} {
+		if err := admin.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One connection, so the session-level SET ROLE covers every query.
+	app = open(1)
+	if err := app.Exec(`SET ROLE ` + testRole).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := EnableTenantGuard(ctx, app); err != nil {
+		t.Fatal(err)
+	}
+	return admin, app
+}
+
+func names(t *testing.T, ctx context.Context, db *gorm.DB) []string {
+	t.Helper()
+	var out []string
+	err := InTenant(ctx, db, func(tx *gorm.DB) error {
+		return tx.Model(&testItem{}).Order("name").Pluck("name", &out).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestTenantIsolation(t *testing.T) {
+	_, db := openTestDBs(t)
+	ctxA := WithTenant(context.Background(), "a")
+	ctxB := WithTenant(context.Background(), "b")
+
+	for ctx, rows := range map[context.Context][]testItem{
+		ctxA: {{TenantID: "a", Name: "a1"}, {TenantID: "a", Name: "a2"}},
+		ctxB: {{TenantID: "b", Name: "b1"}},
+	} {
+		rows := rows
+		if err := InTenant(ctx, db, func(tx *gorm.DB) error { return tx.Create(&rows).Error }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := names(t, ctxA, db); strings.Join(got, ",") != "a1,a2" {
+		t.Errorf("tenant a sees %v", got)
+	}
+	if got := names(t, ctxB, db); strings.Join(got, ",") != "b1" {
+		t.Errorf("tenant b sees %v", got)
+	}
+
+	t.Run("other tenant rows are invisible", func(t *testing.T) {
+		err := InTenant(ctxA, db, func(tx *gorm.DB) error {
+			var n int64
+			if err := tx.Raw(`SELECT count(*) FROM items WHERE tenant_id = 'b'`).Scan(&n).Error; err != nil {
+				return err
+			}
+			if n != 0 {
+				t.Errorf("tenant a counts %d rows of tenant b", n)
+			}
+			res := tx.Model(&testItem{}).Where("tenant_id = ?", "b").Update("name", "stolen")
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected != 0 {
+				t.Errorf("tenant a updated %d rows of tenant b", res.RowsAffected)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(t, ctxB, db); strings.Join(got, ",") != "b1" {
+			t.Errorf("tenant b sees %v", got)
+		}
+	})
+
+	t.Run("rows of another tenant cannot be written", func(t *testing.T) {
+		err := InTenant(ctxA, db, func(tx *gorm.DB) error {
+			return tx.Create(&testItem{TenantID: "b", Name: "planted"}).Error
+		})
+		if err == nil || !strings.Contains(err.Error(), "row-level security") {
+			t.Fatalf("err = %v, want a row-level security violation", err)
+		}
+	})
+
+	t.Run("outside a tenant", func(t *testing.T) {
+		var items []testItem
+		if err := db.Find(&items).Error; !errors.Is(err, ErrNoTenant) {
+			t.Errorf("guard: err = %v, want ErrNoTenant", err)
+		}
+		var n int64
+		if err := db.Raw(`SELECT count(*) FROM items`).Scan(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("raw query outside a tenant counts %d rows", n)
+		}
+		if err := InTenant(context.Background(), db, func(*gorm.DB) error { return nil }); !errors.Is(err, ErrNoTenant) {
+			t.Errorf("InTenant without tenant: err = %v, want ErrNoTenant", err)
+		}
+	})
+}
+
+func TestTenantScope(t *testing.T) {
+	_, db := openTestDBs(t)
+	ctxA := WithTenant(context.Background(), "a")
+
+	t.Run("unused scope", func(t *testing.T) {
+		_, end := WithTenantScope(ctxA, db)
+		if err := end(true); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("commit and rollback", func(t *testing.T) {
+		ctx, end := WithTenantScope(ctxA, db)
+		if err := DB(ctx, db).Create(&testItem{TenantID: "a", Name: "kept"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		// Later calls reuse the transaction.
+		var n int64
+		if err := DB(ctx, db).Model(&testItem{}).Count(&n).Error; err != nil || n != 1 {
+			t.Fatalf("count = %d, %v; want 1", n, err)
+		}
+		if err := end(true); err != nil {
+			t.Fatal(err)
+		}
+		if err := DB(ctx, db).Find(&[]testItem{}).Error; !errors.Is(err, ErrTenantScopeEnded) {
+			t.Errorf("after end: err = %v, want ErrTenantScopeEnded", err)
+		}
+
+		ctx, end = WithTenantScope(ctxA, db)
+		if err := DB(ctx, db).Create(&testItem{TenantID: "a", Name: "dropped"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := end(false); err != nil {
+			t.Fatal(err)
+		}
+		if got := names(t, ctxA, db); strings.Join(got, ",") != "kept" {
+			t.Errorf("tenant a sees %v, want [kept]", got)
+		}
+	})
+
+	t.Run("commit failure", func(t *testing.T) {
+		code := "dup"
+		ctx, end := WithTenantScope(ctxA, db)
+		rows := []testItem{{TenantID: "a", Name: "x", Code: &code}, {TenantID: "a", Name: "y", Code: &code}}
+		if err := DB(ctx, db).Create(&rows).Error; err != nil {
+			t.Fatalf("deferred constraint checked early: %v", err)
+		}
+		if err := end(true); err == nil {
+			t.Fatal("commit succeeded despite a duplicate code")
+		}
+	})
+
+	t.Run("without tenant", func(t *testing.T) {
+		ctx, end := WithTenantScope(context.Background(), db)
+		defer end(false)
+		if err := DB(ctx, db).Find(&[]testItem{}).Error; !errors.Is(err, ErrNoTenant) {
+			t.Errorf("err = %v, want ErrNoTenant", err)
+		}
+	})
+}
+
+func TestEnableTenantGuardRequiresRLS(t *testing.T) {
+	admin, _ := openTestDBs(t)
+	if err := admin.Exec(`CREATE TABLE loose (id BIGSERIAL PRIMARY KEY, tenant_id TEXT NOT NULL)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	err := EnableTenantGuard(context.Background(), admin)
+	if err == nil || !strings.Contains(err.Error(), "loose") {
+		t.Fatalf("err = %v, want loose reported", err)
+	}
+	if err := admin.Exec(`SELECT enable_tenant_rls('loose')`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := EnableTenantGuard(context.Background(), admin); err != nil {
+		t.Fatal(err)
+	}
+}
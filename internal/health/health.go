@@ -0,0 +1,190 @@
+// Package health aggregates dependency checks for the readiness probe.
+// Components register a check with a timeout and criticality; /readyz runs
+// them all and fails only when a critical one is down.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"modelo-mcp/internal/metrics"
+)
+
+// Check reports whether a dependency is usable. It must honour ctx.
+type Check func(ctx context.Context) error
+
+const defaultTimeout = 2 * time.Second
+
+// Status values of a Report.
+const (
+	StatusReady    = "ready"
+	StatusDegraded = "degraded"
+	StatusNotReady = "not_ready"
+)
+
+// Result is the outcome of one check.
+type Result struct {
+	Up       bool    `json:"up"`
+	Critical bool    `json:"critical"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_ms"`
+}
+
+// Report is the aggregated readiness returned by /readyz.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Ready reports whether every critical check is up.
+func (r Report) Ready() bool {
+	return r.Status != StatusNotReady
+}
+
+type registered struct {
+	name     string
+	check    Check
+	timeout  time.Duration
+	critical bool
+}
+
+// Option customizes a registered check.
+type Option func(*registered)
+
+// WithTimeout bounds the check; the default is two seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(r *registered) { r.timeout = d }
+}
+
+// NonCritical reports failures of the check without failing readiness, for
+// dependencies the service can work around.
+func NonCritical() Option {
+	return func(r *registered) { r.critical = false }
+}
+
+// Registry holds the registered checks.
+type Registry struct {
+	metrics *metrics.HealthMetrics
+
+	mu     sync.RWMutex
+	checks []*registered
+}
+
+func NewRegistry(m *metrics.HealthMetrics) *Registry {
+	return &Registry{metrics: m}
+}
+
+// Register adds a check. Checks are critical unless NonCritical is given.
+func (r *Registry) Register(name string, check Check, opts ...Option) {
+	c := &registered{name: name, check: check, timeout: defaultTimeout, critical: true}
+	for _, o := range opts {
+		o(c)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, c)
+	sort.Slice(r.checks, func(i, j int) bool { return r.checks[i].name < r.checks[j].name })
+}
+
+// Check runs every registered check concurrently and aggregates the results.
+// The per-check Prometheus gauges are updated as a side effect.
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]*registered(nil), r.checks...)
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *registered) {
+			defer wg.Done()
+			results[i] = run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusReady, Checks: make(map[string]Result, len(checks))}
+	for i, c := range checks {
+		res := results[i]
+		report.Checks[c.name] = res
+		if !res.Up {
+			if c.critical {
+				report.Status = StatusNotReady
+			} else if report.Status == StatusReady {
+				report.Status = StatusDegraded
+			}
+		}
+		if r.metrics != nil {
+			up := 0.0
+			if res.Up {
+				up = 1
+			}
+			r.metrics.Up.WithLabelValues(c.name).Set(up)
+			r.metrics.Duration.WithLabelValues(c.name).Set(res.Duration / 1000)
+		}
+	}
+	if r.metrics != nil {
+		ready := 0.0
+		if report.Ready() {
+			ready = 1
+		}
+		r.metrics.Ready.Set(ready)
+	}
+	return report
+}
+
+func run(ctx context.Context, c *registered) (res Result) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	defer func() { res.Duration = float64(time.Since(start).Microseconds()) / 1000 }()
+
+	// The check runs in its own goroutine so a check ignoring ctx cannot hold
+	// up the probe.
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- c.check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	res = Result{Up: err == nil, Critical: c.critical}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// ErrWarmingUp is reported by a Gate that has not been opened yet.
+var ErrWarmingUp = errors.New("warming up")
+
+// Gate is a check that fails until Open is called, e.g. until the NATS
+// handlers are subscribed.
+type Gate struct {
+	open atomic.Bool
+}
+
+// Open marks the gated component as ready.
+func (g *Gate) Open() { g.open.Store(true) }
+
+// Check implements Check.
+func (g *Gate) Check(context.Context) error {
+	if !g.open.Load() {
+		return ErrWarmingUp
+	}
+	return nil
+}
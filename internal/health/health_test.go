@@ -0,0 +1,187 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"modelo-mcp/internal/metrics"
+)
+
+func up(context.Context) error { return nil }
+
+func down(context.Context) error { return errors.New("connection refused") }
+
+func TestRegistryCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(r *Registry)
+		status   string
+		failed   []string
+	}{
+		{name: "no checks", register: func(*Registry) {}, status: StatusReady},
+		{
+			name: "all up",
+			register: func(r *Registry) {
+				r.Register("postgres", up)
+				r.Register("clickhouse", up, NonCritical())
+			},
+			status: StatusReady,
+		},
+		{
+			name: "optional down",
+			register: func(r *Registry) {
+				r.Register("postgres", up)
+				r.Register("clickhouse", down, NonCritical())
+			},
+			status: StatusDegraded,
+			failed: []string{"clickhouse"},
+		},
+		{
+			name: "required down",
+			register: func(r *Registry) {
+				r.Register("postgres", down)
+				r.Register("clickhouse", up, NonCritical())
+			},
+			status: StatusNotReady,
+			failed: []string{"postgres"},
+		},
+		{
+			name: "required and optional down",
+			register: func(r *Registry) {
+				r.Register("clickhouse", down, NonCritical())
+				r.Register("postgres", down)
+			},
+			status: StatusNotReady,
+			failed: []string{"clickhouse", "postgres"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(nil)
+			tt.register(r)
+			report := r.Check(context.Background())
+			if report.Status != tt.status || report.Ready() != (tt.status != StatusNotReady) {
+				t.Errorf("status = %s, ready %v; want %s", report.Status, report.Ready(), tt.status)
+			}
+			var failed []string
+			for _, name := range []string{"clickhouse", "postgres"} {
+				if res, ok := report.Checks[name]; ok && !res.Up {
+					failed = append(failed, name)
+					if res.Error != "connection refused" {
+						t.Errorf("%s error = %q", name, res.Error)
+					}
+				}
+			}
+			if !reflect.DeepEqual(failed, tt.failed) {
+				t.Errorf("failed = %v, want %v", failed, tt.failed)
+			}
+			if res, ok := report.Checks["clickhouse"]; ok && res.Critical {
+				t.Error("clickhouse reported critical")
+			}
+		})
+	}
+}
+
+func TestRegistryCheckTimeout(t *testing.T) {
+	r := NewRegistry(nil)
+	hang := make(chan struct{})
+	defer close(hang)
+	// Ignores its context: the probe must not wait for it.
+	r.Register("stuck", func(context.Context) error { <-hang; return nil }, WithTimeout(50*time.Millisecond))
+	r.Register("slow", func(ctx context.Context) error {
+		select {
+		case <-time.After(time.Minute):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, WithTimeout(50*time.Millisecond), NonCritical())
+	r.Register("fast", up, WithTimeout(time.Second))
+
+	start := time.Now()
+	report := r.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Check took %v, want the 50ms timeouts", elapsed)
+	}
+	for _, name := range []string{"stuck", "slow"} {
+		res := report.Checks[name]
+		if res.Up || res.Error != context.DeadlineExceeded.Error() || res.Duration < 50 {
+			t.Errorf("%s = %+v, want a timeout after 50ms", name, res)
+		}
+	}
+	if !report.Checks["fast"].Up || report.Status != StatusNotReady {
+		t.Errorf("report = %+v", report)
+	}
+
+	// The caller's deadline applies too.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	r = NewRegistry(nil)
+	r.Register("slow", func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() })
+	if res := r.Check(ctx).Checks["slow"]; res.Up || res.Duration >= float64(defaultTimeout.Milliseconds()) {
+		t.Errorf("slow = %+v, want the caller's deadline", res)
+	}
+}
+
+func TestRegistryCheckPanic(t *testing.T) {
+	r := NewRegistry(nil)
+	r.Register("broken", func(context.Context) error { panic("nil map") })
+	res := r.Check(context.Background()).Checks["broken"]
+	if res.Up || res.Error != "check panicked: nil map" {
+		t.Errorf("broken = %+v", res)
+	}
+}
+
+func TestGate(t *testing.T) {
+	var g Gate
+	r := NewRegistry(nil)
+	r.Register("handlers", g.Check)
+
+	report := r.Check(context.Background())
+	if report.Ready() || report.Checks["handlers"].Error != ErrWarmingUp.Error() {
+		t.Fatalf("before Open: %+v", report)
+	}
+	g.Open()
+	if report := r.Check(context.Background()); !report.Ready() || report.Status != StatusReady {
+		t.Fatalf("after Open: %+v", report)
+	}
+}
+
+func TestRegistryMetrics(t *testing.T) {
+	m := metrics.NewHealthMetrics(prometheus.NewRegistry())
+	r := NewRegistry(m)
+	failing := true
+	r.Register("postgres", func(context.Context) error {
+		if failing {
+			return errors.New("down")
+		}
+		return nil
+	})
+	r.Register("clickhouse", up, NonCritical())
+
+	r.Check(context.Background())
+	if v := testutil.ToFloat64(m.Up.WithLabelValues("postgres")); v != 0 {
+		t.Errorf("postgres up = %v, want 0", v)
+	}
+	if v := testutil.ToFloat64(m.Up.WithLabelValues("clickhouse")); v != 1 {
+		t.Errorf("clickhouse up = %v, want 1", v)
+	}
+	if v := testutil.ToFloat64(m.Ready); v != 0 {
+		t.Errorf("ready = %v, want 0", v)
+	}
+
+	failing = false
+	r.Check(context.Background())
+	if v := testutil.ToFloat64(m.Up.WithLabelValues("postgres")); v != 1 {
+		t.Errorf("postgres up = %v, want 1", v)
+	}
+	if v := testutil.ToFloat64(m.Ready); v != 1 {
+		t.Errorf("ready = %v, want 1", v)
+	}
+}
@@ -5,10 +5,25 @@ import (
 	"os"
 )
 
-func New(env, service string) *slog.Logger {
-	opts := &slog.HandlerOptions{AddSource: false, Level: slog.LevelInfo}
+// level is shared by every logger from New, so SetLevel applies to all.
+var level = new(slog.LevelVar)
+
+// New returns a JSON logger at the given level ("debug", "info", "warn" or
+// "error", case-insensitive; anything else means info).
+func New(env, service, lvl string) *slog.Logger {
+	SetLevel(lvl)
+	opts := &slog.HandlerOptions{AddSource: false, Level: level}
 	var h slog.Handler = slog.NewJSONHandler(os.Stdout, opts)
 	logger := slog.New(h)
 	logger = logger.With("service", service, "env", env)
 	return logger
 }
+
+// SetLevel changes the level of the loggers from New, e.g. on config reload.
+func SetLevel(lvl string) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(lvl)); err != nil {
+		l = slog.LevelInfo
+	}
+	level.Set(l)
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ClickHouseMetrics describe the ClickHouse batch writers, by table.
+type ClickHouseMetrics struct {
+	FlushDuration *prometheus.HistogramVec
+	Buffered      *prometheus.GaugeVec
+	Dropped       *prometheus.CounterVec
+}
+
+func NewClickHouseMetrics(reg prometheus.Registerer) *ClickHouseMetrics {
+	m := &ClickHouseMetrics{
+		FlushDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "clickhouse_batch_flush_duration_seconds",
+			Help:    "Time to insert one batch into ClickHouse, by result (success, error).",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"table", "result"}),
+		Buffered: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "clickhouse_batch_buffered_rows",
+			Help: "Rows accepted by a batch writer and not yet inserted or dropped.",
+		}, []string{"table"}),
+		Dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "clickhouse_batch_dropped_rows_total",
+			Help: "Rows dropped by a batch writer, by reason (buffer_full, flush_failed, invalid).",
+		}, []string{"table", "reason"}),
+	}
+	reg.MustRegister(m.FlushDuration, m.Buffered, m.Dropped)
+	return m
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ConfigMetrics count configuration reloads; it implements
+// config.ReloadObserver.
+type ConfigMetrics struct {
+	Reloads *prometheus.CounterVec
+}
+
+func NewConfigMetrics(reg prometheus.Registerer) *ConfigMetrics {
+	m := &ConfigMetrics{
+		Reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "config_reloads_total",
+			Help: "Configuration reloads by result (applied, rejected, unchanged, failed).",
+		}, []string{"result"}),
+	}
+	reg.MustRegister(m.Reloads)
+	return m
+}
+
+func (m *ConfigMetrics) ObserveReload(result string) {
+	m.Reloads.WithLabelValues(result).Inc()
+}
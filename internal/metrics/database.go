@@ -0,0 +1,25 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// DatabaseMetrics export connection pool stats, mirroring the
+// database_connections gauge of pkg/metrics for services built on bootstrap.
+type DatabaseMetrics struct {
+	Connections *prometheus.GaugeVec
+}
+
+func NewDatabaseMetrics(reg prometheus.Registerer) *DatabaseMetrics {
+	m := &DatabaseMetrics{
+		Connections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "database_connections",
+			Help: "Number of database connections by pool and state (open, in_use, idle).",
+		}, []string{"database", "state"}),
+	}
+	reg.MustRegister(m.Connections)
+	return m
+}
+
+// SetConnections has the signature of database.ReportPoolStats' report.
+func (m *DatabaseMetrics) SetConnections(database, state string, count int) {
+	m.Connections.WithLabelValues(database, state).Set(float64(count))
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// HealthMetrics export the results of the readiness checks.
+type HealthMetrics struct {
+	Up       *prometheus.GaugeVec
+	Duration *prometheus.GaugeVec
+	Ready    prometheus.Gauge
+}
+
+func NewHealthMetrics(reg prometheus.Registerer) *HealthMetrics {
+	m := &HealthMetrics{
+		Up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "health_check_up",
+			Help: "Whether the last run of a readiness check succeeded (1) or failed (0).",
+		}, []string{"check"}),
+		Duration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "health_check_duration_seconds",
+			Help: "Duration of the last run of a readiness check in seconds.",
+		}, []string{"check"}),
+		Ready: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "health_ready",
+			Help: "Whether the service reported ready on the last readiness probe.",
+		}),
+	}
+	reg.MustRegister(m.Up, m.Duration, m.Ready)
+	return m
+}
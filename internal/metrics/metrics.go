@@ -1,15 +1,20 @@
 package metrics
 
 import (
-	"modelo-mcp/internal/config"
+	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func NewRegistry(cfg *config.Config) *prometheus.Registry {
-	r := prometheus.NewRegistry()
-	r.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
-	r.MustRegister(collectors.NewGoCollector())
-	return r
+// NewRegistry returns the registry for the service's own collectors. The Go
+// and process collectors stay in the default registry, next to the promauto
+// metrics of pkg/metrics used by HTTP-first services; Handler serves both.
+func NewRegistry() *prometheus.Registry {
+	return prometheus.NewRegistry()
+}
+
+// Handler exposes reg merged with the default registry.
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(prometheus.Gatherers{reg, prometheus.DefaultGatherer}, promhttp.HandlerOpts{})
 }
@@ -0,0 +1,26 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// NATSMetrics are the per-subject handler metrics recorded by the NATS
+// middleware chain.
+type NATSMetrics struct {
+	Messages *prometheus.CounterVec
+	Duration *prometheus.HistogramVec
+}
+
+func NewNATSMetrics(reg prometheus.Registerer) *NATSMetrics {
+	m := &NATSMetrics{
+		Messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "nats_handler_messages_total",
+			Help: "Messages processed by NATS handlers, by subject and outcome.",
+		}, []string{"subject", "status"}),
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "nats_handler_duration_seconds",
+			Help:    "NATS handler processing time in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"subject"}),
+	}
+	reg.MustRegister(m.Messages, m.Duration)
+	return m
+}
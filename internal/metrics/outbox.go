@@ -0,0 +1,39 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// OutboxMetrics describe the outbox relay.
+type OutboxMetrics struct {
+	Published prometheus.Counter
+	Failed    prometheus.Counter
+	Parked    prometheus.Counter
+	Pending   prometheus.Gauge
+	Lag       prometheus.Gauge
+}
+
+func NewOutboxMetrics(reg prometheus.Registerer) *OutboxMetrics {
+	m := &OutboxMetrics{
+		Published: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "outbox_published_total",
+			Help: "Outbox events published to JetStream.",
+		}),
+		Failed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "outbox_publish_failures_total",
+			Help: "Outbox publish attempts that failed.",
+		}),
+		Parked: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "outbox_parked_total",
+			Help: "Outbox events given up after outbox.max_attempts failed publishes.",
+		}),
+		Pending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_pending_events",
+			Help: "Outbox events not yet published.",
+		}),
+		Lag: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_relay_lag_seconds",
+			Help: "Age of the oldest unpublished outbox event.",
+		}),
+	}
+	reg.MustRegister(m.Published, m.Failed, m.Parked, m.Pending, m.Lag)
+	return m
+}
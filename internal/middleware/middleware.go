@@ -1,19 +1,23 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-redis/redis/v8"
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+
+	"modelo-mcp/internal/auth"
+	"modelo-mcp/internal/database"
 )
 
-// AuthMiddleware validates JWT tokens
-func AuthMiddleware(secret string) gin.HandlerFunc {
+// AuthMiddleware validates JWT tokens with verifier
+func AuthMiddleware(verifier *auth.Verifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -29,24 +33,17 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(secret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := verifier.Verify(c.Request.Context(), tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("user_id", claims["user_id"])
-			c.Set("tenant_id", claims["tenant_id"])
-			c.Set("role", claims["role"])
-		}
+		c.Set("user_id", claims["user_id"])
+		c.Set("tenant_id", claims["tenant_id"])
+		c.Set("role", claims["role"])
+		c.Set("plan", claims["plan"])
 
 		c.Next()
 	}
@@ -56,9 +53,21 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 func TenantMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tenantID := c.GetHeader("X-Tenant-ID")
-		if tenantID == "" {
-			if claims, exists := c.Get("tenant_id"); exists {
-				tenantID = claims.(string)
+		// A token issued for a tenant cannot be used for another one.
+		if claim, _ := c.Get("tenant_id"); claim != nil {
+			claimed, ok := tenantClaim(claim)
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid tenant claim"})
+				c.Abort()
+				return
+			}
+			if claimed != "" {
+				if tenantID != "" && tenantID != claimed {
+					c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID does not match token"})
+					c.Abort()
+					return
+				}
+				tenantID = claimed
 			}
 		}
 
@@ -73,61 +82,121 @@ func TenantMiddleware() gin.HandlerFunc {
 	}
 }
 
-// AdminMiddleware checks for admin role
-func AdminMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
-			c.Abort()
-			return
+// tenantClaim returns the tenant_id claim as a string. JSON numbers, which
+// JWT claims decode to float64, must be integers; other types are invalid.
+func tenantClaim(v any) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case float64:
+		if t != math.Trunc(t) {
+			return "", false
 		}
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case json.Number:
+		return t.String(), true
+	}
+	return "", false
+}
 
-		if role != "admin" && role != "super_admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-			c.Abort()
-			return
-		}
+// TenantDB scopes each request to its tenant (see database.WithTenantScope),
+// so row-level security applies to every query; use it after
+// TenantMiddleware. Services reach the transaction through
+// database.DB(c.Request.Context(), db), whose first call opens it: requests
+// that never query hold no connection.
+//
+// The response is buffered and only sent once the transaction has ended. It
+// is rolled back when the handler records an error or responds with a
+// status of 400 or more, and committed otherwise; when the commit fails the
+// client gets a 500 instead of the handler's response.
+func TenantDB(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, end := database.WithTenantScope(database.WithTenant(c.Request.Context(), c.GetString("tenant_id")), db)
+		c.Request = c.Request.WithContext(ctx)
+		w := newBufferedWriter(c.Writer)
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			// No-op unless a handler panicked.
+			_ = end(false)
+		}()
 
 		c.Next()
+
+		commit := len(c.Errors) == 0 && w.Status() < http.StatusBadRequest
+		err := end(commit)
+		c.Writer = w.ResponseWriter
+		if err != nil {
+			_ = c.Error(err)
+			if commit {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database unavailable"})
+				return
+			}
+		}
+		w.flush()
 	}
 }
 
-// RateLimitConfig holds rate limiting configuration
-type RateLimitConfig struct {
-	Enabled bool `mapstructure:"enabled"`
-	RPS     int  `mapstructure:"rps"`
-	Burst   int  `mapstructure:"burst"`
+// bufferedWriter holds a response back until flush, so TenantDB can still
+// replace it when the commit fails. Streaming through it is not possible:
+// Flush is a no-op until then.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+	wrote  bool
 }
 
-// RateLimitMiddleware implements rate limiting per tenant
-func RateLimitMiddleware(redisClient *redis.Client, config RateLimitConfig) gin.HandlerFunc {
-	if !config.Enabled {
-		return func(c *gin.Context) {
-			c.Next()
-		}
+func newBufferedWriter(w gin.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && !w.wrote {
+		w.status = code
 	}
+}
 
-	limiters := make(map[string]*rate.Limiter)
+func (w *bufferedWriter) WriteHeaderNow() { w.wrote = true }
 
-	return func(c *gin.Context) {
-		tenantID, exists := c.Get("tenant_id")
-		if !exists {
-			tenantID = "default"
-		}
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.wrote = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int   { return w.status }
+func (w *bufferedWriter) Size() int     { return w.body.Len() }
+func (w *bufferedWriter) Written() bool { return w.wrote }
+func (w *bufferedWriter) Flush()        {}
 
-		key := tenantID.(string)
-		limiter, exists := limiters[key]
+// flush passes the buffered status and body to the underlying writer.
+func (w *bufferedWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	switch {
+	case w.body.Len() > 0:
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	case w.wrote:
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// AdminMiddleware checks for admin role
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
 		if !exists {
-			limiter = rate.NewLimiter(rate.Limit(config.RPS), config.Burst)
-			limiters[key] = limiter
+			c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
+			c.Abort()
+			return
 		}
 
-		if !limiter.Allow() {
-			c.Header("X-RateLimit-Limit", strconv.Itoa(config.RPS))
-			c.Header("X-RateLimit-Remaining", "0")
-			c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
-			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+		if role != "admin" && role != "super_admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
 			c.Abort()
 			return
 		}
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"modelo-mcp/internal/config"
+	"modelo-mcp/internal/database"
+)
+
+func init() { gin.SetMode(gin.TestMode) }
+
+func TestTenantMiddlewareClaim(t *testing.T) {
+	tests := []struct {
+		name   string
+		claim  any
+		header string
+		status int
+		tenant string
+	}{
+		{name: "string claim", claim: "t1", status: http.StatusOK, tenant: "t1"},
+		{name: "numeric claim", claim: float64(42), status: http.StatusOK, tenant: "42"},
+		{name: "large numeric claim", claim: float64(1 << 53), status: http.StatusOK, tenant: "9007199254740992"},
+		{name: "json.Number claim", claim: json.Number("42"), status: http.StatusOK, tenant: "42"},
+		{name: "numeric claim matching header", claim: float64(42), header: "42", status: http.StatusOK, tenant: "42"},
+		{name: "numeric claim against header", claim: float64(42), header: "43", status: http.StatusForbidden},
+		{name: "string claim against header", claim: "t1", header: "t2", status: http.StatusForbidden},
+		{name: "fractional claim", claim: 4.2, status: http.StatusUnauthorized},
+		{name: "bool claim", claim: true, header: "t1", status: http.StatusUnauthorized},
+		{name: "no claim", header: "t1", status: http.StatusOK, tenant: "t1"},
+		{name: "empty claim", claim: "", header: "t1", status: http.StatusOK, tenant: "t1"},
+		{name: "no tenant", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) { c.Set("tenant_id", tt.claim) }, TenantMiddleware())
+			r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, c.GetString("tenant_id")) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK && w.Body.String() != tt.tenant {
+				t.Errorf("tenant = %q, want %q", w.Body, tt.tenant)
+			}
+		})
+	}
+}
+
+// tenantDBRouter serves TenantDB routes for tenant t1 on db.
+func tenantDBRouter(db *gorm.DB, routes map[string]gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("tenant_id", "t1") }, TenantDB(db))
+	for path, h := range routes {
+		r.POST(path, h)
+	}
+	return r
+}
+
+func post(r http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+	return w
+}
+
+// TestTenantDBLazy runs against a database nobody listens on: only requests
+// that query it need a connection.
+func TestTenantDBLazy(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "postgres://127.0.0.1:1/none?connect_timeout=1"}),
+		&gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := tenantDBRouter(db, map[string]gin.HandlerFunc{
+		"/static": func(c *gin.Context) {
+			c.Header("X-Handler", "static")
+			c.JSON(http.StatusCreated, gin.H{"ok": true})
+		},
+		"/empty": func(c *gin.Context) { c.Status(http.StatusNoContent) },
+		"/query": func(c *gin.Context) {
+			if err := database.DB(c.Request.Context(), db).Exec("SELECT 1").Error; err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "down"})
+				return
+			}
+			c.Status(http.StatusOK)
+		},
+	})
+
+	w := post(r, "/static")
+	if w.Code != http.StatusCreated || w.Body.String() != `{"ok":true}` || w.Header().Get("X-Handler") != "static" {
+		t.Errorf("static: %d %q %v", w.Code, w.Body, w.Header())
+	}
+	if w := post(r, "/empty"); w.Code != http.StatusNoContent {
+		t.Errorf("empty: status %d", w.Code)
+	}
+	if w := post(r, "/query"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("query: status %d, want the handler's 503", w.Code)
+	}
+}
+
+// TestTenantDBCommit needs PostgreSQL at TEST_DATABASE_URL.
+func TestTenantDBCommit(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", "tenant_db_test")
+	u.RawQuery = q.Encode()
+
+	db, err := database.NewConnection(config.DatabaseConfig{URL: u.String(), MaxOpenConns: 2, LogLevel: "silent"},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close(db)
+	for _, stmt := range []string{
+		`DROP SCHEMA IF EXISTS tenant_db_test CASCADE`,
+		`CREATE SCHEMA tenant_db_test`,
+		`CREATE TABLE notes (
+			tenant_id TEXT NOT NULL,
+			code      TEXT NOT NULL,
+			CONSTRAINT notes_code_key UNIQUE (code) DEFERRABLE INITIALLY DEFERRED)`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer db.Exec(`DROP SCHEMA IF EXISTS tenant_db_test CASCADE`)
+
+	insert := func(c *gin.Context, codes ...string) bool {
+		for _, code := range codes {
+			err := database.DB(c.Request.Context(), db).
+				Exec(`INSERT INTO notes VALUES (current_setting('app.tenant_id'), ?)`, code).Error
+			if err != nil {
+				_ = c.Error(err)
+				c.Status(http.StatusInternalServerError)
+				return false
+			}
+		}
+		return true
+	}
+	r := tenantDBRouter(db, map[string]gin.HandlerFunc{
+		"/ok": func(c *gin.Context) {
+			if insert(c, "ok") {
+				c.JSON(http.StatusCreated, gin.H{"saved": true})
+			}
+		},
+		"/rejected": func(c *gin.Context) {
+			if insert(c, "rejected") {
+				c.JSON(http.StatusConflict, gin.H{"error": "rejected"})
+			}
+		},
+		"/dup": func(c *gin.Context) {
+			if insert(c, "dup", "dup") {
+				c.JSON(http.StatusCreated, gin.H{"saved": true})
+			}
+		},
+	})
+
+	if w := post(r, "/ok"); w.Code != http.StatusCreated {
+		t.Errorf("ok: status %d: %s", w.Code, w.Body)
+	}
+	if w := post(r, "/rejected"); w.Code != http.StatusConflict {
+		t.Errorf("rejected: status %d: %s", w.Code, w.Body)
+	}
+	// The commit fails on the deferred constraint after the handler chose
+	// 201: the client must not see it.
+	if w := post(r, "/dup"); w.Code != http.StatusInternalServerError || w.Body.String() != `{"error":"Database unavailable"}` {
+		t.Errorf("dup: %d %s, want the commit failure", w.Code, w.Body)
+	}
+
+	var rows []struct{ TenantID, Code string }
+	if err := db.WithContext(context.Background()).Raw(`SELECT tenant_id, code FROM notes`).Scan(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].TenantID != "t1" || rows[0].Code != "ok" {
+		t.Errorf("rows = %+v, want only t1/ok", rows)
+	}
+}
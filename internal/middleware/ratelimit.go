@@ -0,0 +1,471 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/time/rate"
+
+	"modelo-mcp/internal/config"
+)
+
+// RateLimitConfig holds rate limiting configuration: the default limit,
+// policies by tenant, plan, route and API key, IP allow and deny lists and
+// temporary bans. With Distributed and a Redis client the limits and bans
+// are shared by every replica; otherwise each replica enforces them alone.
+type RateLimitConfig = config.RateLimitConfig
+
+// RateLimitMiddleware implements rate limiting per tenant
+func RateLimitMiddleware(redisClient *redis.Client, config RateLimitConfig) gin.HandlerFunc {
+	return NewRedisRateLimiter(redisClient, config).Middleware()
+}
+
+const (
+	// redisLimitTimeout bounds the Redis round trip of one decision.
+	redisLimitTimeout = 100 * time.Millisecond
+	// redisRetryAfter is how long Redis is skipped after it failed.
+	redisRetryAfter = 5 * time.Second
+)
+
+// limitScript decides one request atomically. It rejects banned clients
+// (KEYS[2]), applies GCRA (a token bucket without a refill timer) to the
+// bucket KEYS[1] and counts rejections in KEYS[3], banning the client once
+// they reach the threshold. The bucket holds the theoretical arrival time
+// (TAT) of the next request in microseconds of the Redis clock, so replicas
+// need not agree on the time.
+//
+// ARGV: burst, rps, ban threshold (0 disables), ban window ms, ban ms.
+// Returns {allowed, remaining, retry_after_us, reset_us, banned}.
+var limitScript = redis.NewScript(`
+local ban_ttl = redis.call("PTTL", KEYS[2])
+if ban_ttl > 0 then
+  return {0, 0, ban_ttl * 1000, 0, 1}
+end
+
+local burst = tonumber(ARGV[1])
+local emission = 1000000 / tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+  tat = now
+end
+local new_tat = tat + emission
+local diff = now - (new_tat - emission * burst)
+if diff >= 0 then
+  redis.call("SET", KEYS[1], string.format("%.0f", new_tat), "PX", math.ceil((new_tat - now) / 1000))
+  return {1, math.floor(diff / emission), 0, math.ceil(new_tat - now), 0}
+end
+
+local threshold = tonumber(ARGV[3])
+if threshold > 0 then
+  local strikes = redis.call("INCR", KEYS[3])
+  if strikes == 1 then
+    redis.call("PEXPIRE", KEYS[3], ARGV[4])
+  end
+  if strikes >= threshold then
+    redis.call("DEL", KEYS[3])
+    redis.call("SET", KEYS[2], "1", "PX", ARGV[5])
+    return {0, 0, tonumber(ARGV[5]) * 1000, 0, 1}
+  end
+end
+return {0, 0, math.ceil(-diff), math.ceil(tat - now), 0}
+`)
+
+// limitResult is one rate limiting decision.
+type limitResult struct {
+	allowed    bool
+	banned     bool
+	remaining  int
+	retryAfter time.Duration // until a denied request would be allowed
+	reset      time.Duration // until the bucket is full again
+}
+
+// RateLimiter limits requests by policy. Update applies a new configuration
+// to the running middleware, e.g. on config reload.
+type RateLimiter struct {
+	redis *redis.Client
+
+	mu        sync.Mutex
+	config    RateLimitConfig
+	allow     []*net.IPNet
+	deny      []*net.IPNet
+	limiters  map[string]*localLimiter
+	strikes   map[string]*strikeCount
+	bans      map[string]time.Time
+	lastSweep time.Time
+	redisDown time.Time // Redis is skipped until then
+}
+
+type localLimiter struct {
+	limiter  *rate.Limiter
+	policy   string
+	lastSeen time.Time
+}
+
+type strikeCount struct {
+	count int
+	since time.Time
+}
+
+// NewRateLimiter returns an in-memory limiter using config.
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return NewRedisRateLimiter(nil, config)
+}
+
+// NewRedisRateLimiter returns a limiter that keeps its buckets and bans in
+// client while config.Distributed is set. When Redis fails, requests are
+// limited in memory, per replica, and Redis is tried again a few seconds
+// later.
+func NewRedisRateLimiter(client *redis.Client, config RateLimitConfig) *RateLimiter {
+	l := &RateLimiter{
+		redis:    client,
+		limiters: make(map[string]*localLimiter),
+		strikes:  make(map[string]*strikeCount),
+		bans:     make(map[string]time.Time),
+	}
+	l.Update(config)
+	return l
+}
+
+// Update changes the configuration. Buckets keep their state with the new
+// limits of their policy; buckets of removed policies are dropped.
+func (l *RateLimiter) Update(config RateLimitConfig) {
+	allow, deny := parseNets(config.Allowlist), parseNets(config.Denylist)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config, l.allow, l.deny = config, allow, deny
+	for key, ll := range l.limiters {
+		p, ok := policyByName(config, ll.policy)
+		if !ok {
+			delete(l.limiters, key)
+			continue
+		}
+		ll.limiter.SetLimit(rate.Limit(p.RPS))
+		ll.limiter.SetBurst(p.Burst)
+	}
+}
+
+// IPFilter rejects denylisted and banned client IPs. Register it before
+// AuthMiddleware, so they are turned away before their token is verified;
+// Middleware repeats the checks for routes without it.
+func (l *RateLimiter) IPFilter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l.mu.Lock()
+		cfg, allow, deny := l.config, l.allow, l.deny
+		useRedis := l.redis != nil && cfg.Distributed && time.Now().After(l.redisDown)
+		l.mu.Unlock()
+
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		ip := net.ParseIP(c.ClientIP())
+		if containsIP(deny, ip) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			c.Abort()
+			return
+		}
+		if containsIP(allow, ip) {
+			c.Next()
+			return
+		}
+		if ban := l.banned(c.Request.Context(), cfg, useRedis, c.ClientIP()); ban > 0 {
+			c.Header("Retry-After", retryAfter(ban))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Temporarily banned for exceeding the rate limit"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// banned returns how long ip stays banned, or 0. Bans are read from Redis
+// when useRedis is set and it answers, and from memory otherwise.
+func (l *RateLimiter) banned(ctx context.Context, cfg RateLimitConfig, useRedis bool, ip string) time.Duration {
+	if useRedis {
+		ctx, cancel := context.WithTimeout(ctx, redisLimitTimeout)
+		defer cancel()
+		ttl, err := l.redis.PTTL(ctx, cfg.RedisPrefix+"ban:"+ip).Result()
+		if err == nil {
+			return max(ttl, 0)
+		}
+		l.mu.Lock()
+		l.redisDown = time.Now().Add(redisRetryAfter)
+		l.mu.Unlock()
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until, ok := l.bans[ip]; ok && now.Before(until) {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+// Middleware returns the gin handler.
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l.mu.Lock()
+		cfg, allow, deny := l.config, l.allow, l.deny
+		useRedis := l.redis != nil && cfg.Distributed && time.Now().After(l.redisDown)
+		l.mu.Unlock()
+
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		ip := net.ParseIP(c.ClientIP())
+		if containsIP(deny, ip) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			c.Abort()
+			return
+		}
+		if containsIP(allow, ip) {
+			c.Next()
+			return
+		}
+
+		apiKey := c.GetHeader("X-API-Key")
+		policy := matchPolicy(cfg, c.GetString("tenant_id"), c.GetString("plan"), c.Request.URL.Path, apiKey)
+		bucket := policy.Name + ":" + bucketID(policy, c.GetString("tenant_id"), apiKey, c.ClientIP())
+
+		var res limitResult
+		var err error
+		if useRedis {
+			res, err = l.allowRedis(c.Request.Context(), cfg, policy, bucket, c.ClientIP())
+			if err != nil {
+				l.mu.Lock()
+				l.redisDown = time.Now().Add(redisRetryAfter)
+				l.mu.Unlock()
+			}
+		}
+		if !useRedis || err != nil {
+			res = l.allowLocal(cfg, policy, bucket, c.ClientIP())
+		}
+
+		if res.banned {
+			c.Header("Retry-After", retryAfter(res.retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Temporarily banned for exceeding the rate limit"})
+			c.Abort()
+			return
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(policy.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(res.reset).Unix(), 10))
+		if !res.allowed {
+			c.Header("Retry-After", retryAfter(res.retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (l *RateLimiter) allowRedis(ctx context.Context, cfg RateLimitConfig, policy config.RateLimitPolicy, bucket, ip string) (limitResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisLimitTimeout)
+	defer cancel()
+	keys := []string{cfg.RedisPrefix + bucket, cfg.RedisPrefix + "ban:" + ip, cfg.RedisPrefix + "strikes:" + ip}
+	vals, err := limitScript.Run(ctx, l.redis, keys,
+		policy.Burst, policy.RPS, cfg.Ban.Threshold, cfg.Ban.Window.Milliseconds(), cfg.Ban.Duration.Milliseconds()).Int64Slice()
+	if err != nil {
+		return limitResult{}, err
+	}
+	return limitResult{
+		allowed:    vals[0] == 1,
+		remaining:  int(vals[1]),
+		retryAfter: time.Duration(vals[2]) * time.Microsecond,
+		reset:      time.Duration(vals[3]) * time.Microsecond,
+		banned:     vals[4] == 1,
+	}, nil
+}
+
+func (l *RateLimiter) allowLocal(cfg RateLimitConfig, policy config.RateLimitPolicy, bucket, ip string) limitResult {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now, cfg.IdleTTL)
+
+	if until, ok := l.bans[ip]; ok && now.Before(until) {
+		return limitResult{banned: true, retryAfter: until.Sub(now)}
+	}
+
+	ll, exists := l.limiters[bucket]
+	if !exists {
+		ll = &localLimiter{limiter: rate.NewLimiter(rate.Limit(policy.RPS), policy.Burst), policy: policy.Name}
+		l.limiters[bucket] = ll
+	}
+	ll.lastSeen = now
+
+	allowed := ll.limiter.AllowN(now, 1)
+	tokens := ll.limiter.TokensAt(now)
+	perToken := time.Duration(float64(time.Second) / float64(policy.RPS))
+	res := limitResult{
+		allowed:   allowed,
+		remaining: int(math.Max(0, math.Floor(tokens))),
+		reset:     time.Duration((float64(policy.Burst) - tokens) * float64(perToken)),
+	}
+	if allowed {
+		return res
+	}
+	res.retryAfter = time.Duration((1 - tokens) * float64(perToken))
+
+	if cfg.Ban.Threshold > 0 {
+		s := l.strikes[ip]
+		if s == nil || now.Sub(s.since) > cfg.Ban.Window {
+			s = &strikeCount{since: now}
+			l.strikes[ip] = s
+		}
+		s.count++
+		if s.count >= cfg.Ban.Threshold {
+			delete(l.strikes, ip)
+			l.bans[ip] = now.Add(cfg.Ban.Duration)
+			return limitResult{banned: true, retryAfter: cfg.Ban.Duration}
+		}
+	}
+	return res
+}
+
+// sweep evicts limiters idle for ttl, expired bans and stale strike counts.
+// It runs at most every ttl/2, under l.mu.
+func (l *RateLimiter) sweep(now time.Time, ttl time.Duration) {
+	if ttl <= 0 || now.Sub(l.lastSweep) < ttl/2 {
+		return
+	}
+	l.lastSweep = now
+	for key, ll := range l.limiters {
+		if now.Sub(ll.lastSeen) > ttl {
+			delete(l.limiters, key)
+		}
+	}
+	for ip, until := range l.bans {
+		if now.After(until) {
+			delete(l.bans, ip)
+		}
+	}
+	for ip, s := range l.strikes {
+		if now.Sub(s.since) > ttl {
+			delete(l.strikes, ip)
+		}
+	}
+}
+
+// matchPolicy returns the policy with the most selectors that all match the
+// request, or the default limit as the policy "default".
+func matchPolicy(cfg RateLimitConfig, tenant, plan, path, apiKey string) config.RateLimitPolicy {
+	best := config.RateLimitPolicy{Name: "default", RPS: cfg.RPS, Burst: cfg.Burst}
+	bestScore := 0
+	for _, p := range cfg.Policies {
+		score := 0
+		if p.Tenant != "" {
+			if p.Tenant != tenant {
+				continue
+			}
+			score++
+		}
+		if p.Plan != "" {
+			if p.Plan != plan {
+				continue
+			}
+			score++
+		}
+		if p.Route != "" {
+			if !routeMatches(p.Route, path) {
+				continue
+			}
+			score++
+		}
+		if p.APIKey != "" {
+			if apiKey == "" || subtle.ConstantTimeCompare([]byte(p.APIKey), []byte(apiKey)) != 1 {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = p, score
+		}
+	}
+	return best
+}
+
+func policyByName(cfg RateLimitConfig, name string) (config.RateLimitPolicy, bool) {
+	if name == "default" {
+		return config.RateLimitPolicy{Name: name, RPS: cfg.RPS, Burst: cfg.Burst}, true
+	}
+	for _, p := range cfg.Policies {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return config.RateLimitPolicy{}, false
+}
+
+// routeMatches reports whether path is route or below it.
+func routeMatches(route, path string) bool {
+	route = strings.TrimSuffix(route, "/")
+	return path == route || strings.HasPrefix(path, route+"/")
+}
+
+// bucketID is who a policy limits: the API key (hashed, so it is not
+// stored in Redis) for key policies, otherwise the tenant, or the client IP
+// for requests without one.
+func bucketID(p config.RateLimitPolicy, tenant, apiKey, ip string) string {
+	switch {
+	case p.APIKey != "":
+		sum := sha256.Sum256([]byte(apiKey))
+		return "key:" + hex.EncodeToString(sum[:8])
+	case tenant != "":
+		return "tenant:" + tenant
+	}
+	return "ip:" + ip
+}
+
+func parseNets(list []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, s := range list {
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 128
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		if _, n, err := net.ParseCIDR(s); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestRedis returns a client of a miniredis whose clock, which the limit
+// script reads with TIME, stands still until the test moves it.
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client, time.Time) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	now := time.Unix(1700000000, 0)
+	mr.SetTime(now)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client, now
+}
+
+func distributedConfig(rps, burst int) RateLimitConfig {
+	return RateLimitConfig{Enabled: true, Distributed: true, RPS: rps, Burst: burst, RedisPrefix: "rl:"}
+}
+
+// limitedRouter serves GET / behind each limiter in turn, as tenant t1.
+func limitedRouter(limiters ...*RateLimiter) []*gin.Engine {
+	var out []*gin.Engine
+	for _, l := range limiters {
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("tenant_id", "t1") }, l.Middleware())
+		r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+		out = append(out, r)
+	}
+	return out
+}
+
+func get(r http.Handler) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w
+}
+
+func TestRedisRateLimitBurst(t *testing.T) {
+	mr, client, now := newTestRedis(t)
+	r := limitedRouter(NewRedisRateLimiter(client, distributedConfig(1, 3)))[0]
+
+	for want := 2; want >= 0; want-- {
+		w := get(r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", 3-want, w.Code)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != strconv.Itoa(want) {
+			t.Errorf("remaining = %s, want %d", got, want)
+		}
+	}
+	if !mr.Exists("rl:default:tenant:t1") {
+		t.Fatalf("bucket not in Redis, keys: %v", mr.Keys())
+	}
+
+	w := get(r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("past the burst: status %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("remaining = %s, want 0", got)
+	}
+
+	// One emission interval frees one request, not the whole burst.
+	mr.SetTime(now.Add(time.Second))
+	if w := get(r); w.Code != http.StatusOK {
+		t.Fatalf("after 1s: status %d", w.Code)
+	}
+	if w := get(r); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request after 1s: status %d", w.Code)
+	}
+}
+
+func TestRedisRateLimitRetryAfter(t *testing.T) {
+	mr, client, now := newTestRedis(t)
+	cfg := distributedConfig(2, 2)
+	l := NewRedisRateLimiter(client, cfg)
+	policy := matchPolicy(cfg, "t1", "", "/", "")
+	ctx := context.Background()
+
+	decide := func() limitResult {
+		t.Helper()
+		res, err := l.allowRedis(ctx, cfg, policy, "default:tenant:t1", "192.0.2.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	for i := 0; i < 2; i++ {
+		if res := decide(); !res.allowed {
+			t.Fatalf("request %d denied", i+1)
+		}
+	}
+	// 2 rps: the next token arrives 500ms after the burst was spent, and
+	// the bucket is full again after 1s.
+	res := decide()
+	if res.allowed || res.retryAfter != 500*time.Millisecond || res.reset != time.Second {
+		t.Fatalf("got %+v, want denied with retryAfter 500ms and reset 1s", res)
+	}
+
+	mr.SetTime(now.Add(300 * time.Millisecond))
+	if res := decide(); res.allowed || res.retryAfter != 200*time.Millisecond {
+		t.Fatalf("after 300ms: got %+v, want retryAfter 200ms", res)
+	}
+	mr.SetTime(now.Add(500 * time.Millisecond))
+	if res := decide(); !res.allowed || res.remaining != 0 {
+		t.Fatalf("after 500ms: got %+v, want allowed with nothing left", res)
+	}
+}
+
+func TestRedisRateLimitSharedAcrossReplicas(t *testing.T) {
+	_, client, _ := newTestRedis(t)
+	cfg := distributedConfig(1, 3)
+	replicas := limitedRouter(NewRedisRateLimiter(client, cfg), NewRedisRateLimiter(client, cfg))
+
+	allowed := 0
+	for i := 0; i < 8; i++ {
+		if get(replicas[i%2]).Code == http.StatusOK {
+			allowed++
+		}
+	}
+	// Each replica limiting alone would let 2 bursts through.
+	if allowed != 3 {
+		t.Errorf("allowed %d requests across replicas, want one burst of 3", allowed)
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	mr, client, _ := newTestRedis(t)
+	cfg := distributedConfig(1, 3)
+	cfg.Denylist = []string{"198.51.100.0/24"}
+	cfg.Allowlist = []string{"203.0.113.7"}
+	l := NewRedisRateLimiter(client, cfg)
+
+	// The filter must answer before authentication would reject the request.
+	r := gin.New()
+	r.Use(l.IPFilter(), func(c *gin.Context) { c.AbortWithStatus(http.StatusUnauthorized) })
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	from := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := from("198.51.100.9"); w.Code != http.StatusForbidden {
+		t.Errorf("denylisted: status %d", w.Code)
+	}
+	if w := from("192.0.2.1"); w.Code != http.StatusUnauthorized {
+		t.Errorf("unlisted: status %d, want auth to run", w.Code)
+	}
+
+	mr.Set("rl:ban:192.0.2.1", "1")
+	mr.SetTTL("rl:ban:192.0.2.1", 90*time.Second)
+	w := from("192.0.2.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "90" {
+		t.Errorf("banned: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	mr.Set("rl:ban:203.0.113.7", "1")
+	mr.SetTTL("rl:ban:203.0.113.7", 90*time.Second)
+	if w := from("203.0.113.7"); w.Code != http.StatusUnauthorized {
+		t.Errorf("allowlisted: status %d, want auth to run", w.Code)
+	}
+
+	// Without Redis the bans of this replica apply.
+	mr.Close()
+	l.mu.Lock()
+	l.bans["192.0.2.2"] = time.Now().Add(time.Minute)
+	l.mu.Unlock()
+	if w := from("192.0.2.2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("banned locally with Redis down: status %d", w.Code)
+	}
+}
@@ -0,0 +1,203 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"modelo-mcp/internal/config"
+)
+
+// ConsumerPolicy sizes the consumer behind one handler.
+type ConsumerPolicy struct {
+	Pull          bool
+	Workers       int
+	BatchSize     int
+	MaxInFlight   int
+	FetchWait     time.Duration
+	AckWait       time.Duration
+	MaxAckPending int
+}
+
+// subOpts returns the consumer settings shared by push and pull
+// subscriptions.
+func (p ConsumerPolicy) subOpts() []nats.SubOpt {
+	opts := []nats.SubOpt{nats.ManualAck()}
+	if p.AckWait > 0 {
+		opts = append(opts, nats.AckWait(p.AckWait))
+	}
+	if p.MaxAckPending > 0 {
+		opts = append(opts, nats.MaxAckPending(p.MaxAckPending))
+	}
+	return opts
+}
+
+// ConsumerPolicies resolves the consumer sizing for a subject from the
+// configured per-subject overrides, falling back to the default.
+type ConsumerPolicies struct {
+	def       ConsumerPolicy
+	overrides []subjectConsumer
+}
+
+type subjectConsumer struct {
+	subject string
+	policy  ConsumerPolicy
+}
+
+func NewConsumerPolicies(cfg config.ConsumerConfig) *ConsumerPolicies {
+	def := ConsumerPolicy{
+		Pull:          cfg.Mode != "push",
+		Workers:       cfg.Workers,
+		BatchSize:     cfg.BatchSize,
+		MaxInFlight:   cfg.MaxInFlight,
+		FetchWait:     cfg.FetchWait,
+		AckWait:       cfg.AckWait,
+		MaxAckPending: cfg.MaxAckPending,
+	}
+	cp := &ConsumerPolicies{def: def.normalize()}
+	for _, o := range cfg.Subjects {
+		p := def
+		if o.Workers != 0 {
+			p.Workers = o.Workers
+		}
+		if o.BatchSize != 0 {
+			p.BatchSize = o.BatchSize
+		}
+		if o.MaxInFlight != 0 {
+			p.MaxInFlight = o.MaxInFlight
+		}
+		if o.AckWait != 0 {
+			p.AckWait = o.AckWait
+		}
+		if o.MaxAckPending != 0 {
+			p.MaxAckPending = o.MaxAckPending
+		}
+		cp.overrides = append(cp.overrides, subjectConsumer{subject: o.Subject, policy: p.normalize()})
+	}
+	return cp
+}
+
+func (p ConsumerPolicy) normalize() ConsumerPolicy {
+	if p.Workers < 1 {
+		p.Workers = 1
+	}
+	if p.BatchSize < 1 {
+		p.BatchSize = 1
+	}
+	if p.MaxInFlight < 1 {
+		p.MaxInFlight = p.Workers * p.BatchSize
+	}
+	if p.FetchWait <= 0 {
+		p.FetchWait = 5 * time.Second
+	}
+	return p
+}
+
+// For returns the first override matching subject, or the default policy.
+func (cp *ConsumerPolicies) For(subject string) ConsumerPolicy {
+	for _, o := range cp.overrides {
+		if SubjectMatches(o.subject, subject) {
+			return o.policy
+		}
+	}
+	return cp.def
+}
+
+// puller feeds a worker pool from a pull subscription. A slot is taken for
+// every fetched message and returned once it is settled, so no more than
+// MaxInFlight messages are held at a time and the server keeps the rest.
+type puller struct {
+	sub    *nats.Subscription
+	policy ConsumerPolicy
+	handle func(*nats.Msg)
+	logger *slog.Logger
+
+	fetchCtx context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func newPuller(sub *nats.Subscription, policy ConsumerPolicy, handle func(*nats.Msg), logger *slog.Logger) *puller {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &puller{
+		sub:      sub,
+		policy:   policy,
+		handle:   handle,
+		logger:   logger,
+		fetchCtx: ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *puller) run() {
+	jobs := make(chan *nats.Msg)
+	slots := make(chan struct{}, p.policy.MaxInFlight)
+	workers := make(chan struct{})
+	for i := 0; i < p.policy.Workers; i++ {
+		go func() {
+			defer func() { workers <- struct{}{} }()
+			for msg := range jobs {
+				p.handle(msg)
+				<-slots
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		for i := 0; i < p.policy.Workers; i++ {
+			<-workers
+		}
+		close(p.done)
+	}()
+
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-p.fetchCtx.Done():
+			return
+		}
+		n := 1
+	fill:
+		for n < p.policy.BatchSize {
+			select {
+			case slots <- struct{}{}:
+				n++
+			default:
+				break fill
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(p.fetchCtx, p.policy.FetchWait)
+		msgs, err := p.sub.Fetch(n, nats.Context(ctx))
+		cancel()
+		for i := len(msgs); i < n; i++ {
+			<-slots
+		}
+		for _, msg := range msgs {
+			jobs <- msg
+		}
+
+		switch {
+		case err == nil, errors.Is(err, nats.ErrTimeout),
+			errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		default:
+			p.logger.Warn("fetch failed", "subject", p.sub.Subject, "error", err)
+			select {
+			case <-time.After(time.Second):
+			case <-p.fetchCtx.Done():
+				return
+			}
+		}
+	}
+}
+
+// stop ends fetching and waits until the messages already fetched have been
+// processed.
+func (p *puller) stop() {
+	p.cancel()
+	<-p.done
+}
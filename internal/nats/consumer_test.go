@@ -0,0 +1,234 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+
+	"modelo-mcp/internal/config"
+)
+
+func TestConsumerPoliciesFor(t *testing.T) {
+	cp := NewConsumerPolicies(config.ConsumerConfig{
+		Mode:      "pull",
+		Workers:   4,
+		BatchSize: 10,
+		AckWait:   30 * time.Second,
+		Subjects: []config.SubjectConsumerConfig{
+			{Subject: "reports.>", Workers: 1, BatchSize: 1},
+			{Subject: "orders.*", MaxInFlight: 8, MaxAckPending: 100},
+		},
+	})
+	tests := []struct {
+		subject string
+		want    ConsumerPolicy
+	}{
+		{"users.create", ConsumerPolicy{Pull: true, Workers: 4, BatchSize: 10, MaxInFlight: 40, FetchWait: 5 * time.Second, AckWait: 30 * time.Second}},
+		{"reports.daily", ConsumerPolicy{Pull: true, Workers: 1, BatchSize: 1, MaxInFlight: 1, FetchWait: 5 * time.Second, AckWait: 30 * time.Second}},
+		{"orders.create", ConsumerPolicy{Pull: true, Workers: 4, BatchSize: 10, MaxInFlight: 8, FetchWait: 5 * time.Second, AckWait: 30 * time.Second, MaxAckPending: 100}},
+	}
+	for _, tt := range tests {
+		if got := cp.For(tt.subject); got != tt.want {
+			t.Errorf("For(%q) = %+v, want %+v", tt.subject, got, tt.want)
+		}
+	}
+
+	if got := NewConsumerPolicies(config.ConsumerConfig{Mode: "push"}).For("x"); got.Pull || got.Workers != 1 || got.BatchSize != 1 || got.MaxInFlight != 1 {
+		t.Errorf("push defaults = %+v", got)
+	}
+}
+
+// runJetStream starts an in-process JetStream server with an EVENTS stream
+// over orders.>.
+func runJetStream(t *testing.T) (*nats.Conn, nats.JetStreamContext) {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir(), NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(10 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{Name: "EVENTS", Subjects: []string{"orders.>"}}); err != nil {
+		t.Fatal(err)
+	}
+	return nc, js
+}
+
+func newPullRegistry(t *testing.T, nc *nats.Conn, js nats.JetStreamContext, consumer config.ConsumerConfig) (*Registry, *DLQ) {
+	t.Helper()
+	dlq := NewDLQ(js, "EVENTS", discard)
+	if err := dlq.Ensure(); err != nil {
+		t.Fatal(err)
+	}
+	retrier := NewRetrier(NewRetryPolicies(config.RetryConfig{MaxDeliver: 3, InitialBackoff: 10 * time.Millisecond, Multiplier: 1}), dlq, discard)
+	consumer.Mode = "pull"
+	consumer.FetchWait = 100 * time.Millisecond
+	return NewRegistry(nc, js, NewConsumerPolicies(consumer), retrier, discard), dlq
+}
+
+func publishOrders(t *testing.T, js nats.JetStreamContext, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if _, err := js.Publish("orders.create", []byte(`{"id":"`+id+`"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// eventually polls cond until it holds or the deadline passes.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestPullConsumerSettles(t *testing.T) {
+	nc, js := runJetStream(t)
+	r, dlq := newPullRegistry(t, nc, js, config.ConsumerConfig{Workers: 3, BatchSize: 4})
+
+	var mu sync.Mutex
+	deliveries := map[string]int{}
+	Handle(r, "orders.create", func(_ context.Context, o order) (order, error) {
+		mu.Lock()
+		deliveries[o.ID]++
+		n := deliveries[o.ID]
+		mu.Unlock()
+		switch {
+		case o.ID == "poison":
+			return order{}, Permanent(errors.New("unknown customer"))
+		case o.ID == "broken", o.ID == "flaky" && n == 1:
+			return order{}, errors.New("database down")
+		}
+		return o, nil
+	}, WithDurable("orders"))
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	publishOrders(t, js, "o1", "o2", "flaky", "poison", "broken", "o3")
+
+	eventually(t, "the dead letters", func() bool {
+		info, err := js.StreamInfo(dlq.Stream())
+		return err == nil && info.State.Msgs == 2
+	})
+	eventually(t, "every message to be settled", func() bool {
+		info, err := js.ConsumerInfo("EVENTS", "orders")
+		return err == nil && info.NumPending == 0 && info.NumAckPending == 0
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]int{"o1": 1, "o2": 1, "o3": 1, "flaky": 2, "poison": 1, "broken": 3}
+	for id, n := range want {
+		if deliveries[id] != n {
+			t.Errorf("%s delivered %d times, want %d", id, deliveries[id], n)
+		}
+	}
+
+	letters, err := dlq.List(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	for _, l := range letters {
+		got[string(l.Data)] = l.Deliveries
+	}
+	if got[`{"id":"poison"}`] != 1 || got[`{"id":"broken"}`] != 3 {
+		t.Errorf("dead letters = %v", got)
+	}
+}
+
+func TestPullConsumerBoundsInFlight(t *testing.T) {
+	nc, js := runJetStream(t)
+	r, _ := newPullRegistry(t, nc, js, config.ConsumerConfig{Workers: 2, BatchSize: 5, MaxInFlight: 4})
+	// Provisioned up front, as Reconcile does, so that draining the
+	// subscription on Stop leaves the consumer in place.
+	if _, err := js.AddConsumer("EVENTS", &nats.ConsumerConfig{Durable: "orders", AckPolicy: nats.AckExplicitPolicy}); err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	var running, peak, handled atomic.Int32
+	Handle(r, "orders.create", func(_ context.Context, o order) (order, error) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		running.Add(-1)
+		handled.Add(1)
+		return o, nil
+	}, WithDurable("orders"))
+	// Published up front so that every fetch is filled.
+	publishOrders(t, js, "1", "2", "3", "4", "5", "6", "7", "8", "9", "10")
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "the workers to be busy", func() bool { return peak.Load() == 2 })
+	// The fetch loop holds back once MaxInFlight slots are taken, below the
+	// batch size: the rest stays on the server.
+	time.Sleep(300 * time.Millisecond)
+	info, err := js.ConsumerInfo("EVENTS", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NumAckPending != 4 || info.NumPending != 6 {
+		t.Fatalf("%d in flight and %d on the server, want 4 and 6", info.NumAckPending, info.NumPending)
+	}
+	if p := peak.Load(); p != 2 {
+		t.Errorf("%d handlers ran at once, want the 2 workers", p)
+	}
+
+	// Stop waits for the fetched messages and leaves the rest on the server.
+	stopped := make(chan struct{})
+	go func() {
+		r.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned with messages still being handled")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+	if n := handled.Load(); n != 4 {
+		t.Errorf("handled %d messages, want the 4 in flight", n)
+	}
+	eventually(t, "the acks", func() bool {
+		info, err := js.ConsumerInfo("EVENTS", "orders")
+		return err == nil && info.NumAckPending == 0
+	})
+	if info, err = js.ConsumerInfo("EVENTS", "orders"); err != nil || info.NumPending != 6 {
+		t.Errorf("after Stop: %+v, %v; want 6 left on the server", info, err)
+	}
+}
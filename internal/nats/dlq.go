@@ -0,0 +1,208 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Headers added to every dead-lettered message, next to the original ones.
+const (
+	HeaderDLQReason     = "Mcp-Dlq-Reason"
+	HeaderDLQSubject    = "Mcp-Dlq-Original-Subject"
+	HeaderDLQStream     = "Mcp-Dlq-Original-Stream"
+	HeaderDLQSequence   = "Mcp-Dlq-Original-Sequence"
+	HeaderDLQDeliveries = "Mcp-Dlq-Deliveries"
+	HeaderDLQFailedAt   = "Mcp-Dlq-Failed-At"
+	HeaderDLQReplayedOf = "Mcp-Dlq-Replayed-Of"
+)
+
+// DLQ stores messages that exhausted their retry policy. Stream names cannot
+// contain dots, so the dead-letter stream for MCP_MODELO is MCP_MODELO_DLQ and
+// captures the subjects MCP_MODELO.DLQ.<original subject>.
+type DLQ struct {
+	js     nats.JetStreamContext
+	stream string
+	logger *slog.Logger
+}
+
+// DeadLetter is a message read back from the DLQ stream.
+type DeadLetter struct {
+	Sequence       uint64      `json:"sequence"`
+	Subject        string      `json:"original_subject"`
+	Stream         string      `json:"original_stream"`
+	StreamSequence uint64      `json:"original_sequence"`
+	Deliveries     int         `json:"deliveries"`
+	Reason         string      `json:"reason"`
+	FailedAt       time.Time   `json:"failed_at"`
+	Header         nats.Header `json:"header"`
+	Data           []byte      `json:"data"`
+}
+
+func NewDLQ(js nats.JetStreamContext, stream string, logger *slog.Logger) *DLQ {
+	return &DLQ{js: js, stream: stream, logger: logger}
+}
+
+// Stream returns the dead-letter stream name.
+func (d *DLQ) Stream() string { return d.stream + "_DLQ" }
+
+func (d *DLQ) subjectPrefix() string { return d.stream + ".DLQ." }
+
+// Ensure creates the dead-letter stream if it does not exist yet.
+func (d *DLQ) Ensure() error {
+	_, err := d.js.AddStream(&nats.StreamConfig{
+		Name:     d.Stream(),
+		Subjects: []string{d.subjectPrefix() + ">"},
+		MaxAge:   30 * 24 * time.Hour,
+	})
+	if err != nil && !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return fmt.Errorf("ensure dlq stream %s: %w", d.Stream(), err)
+	}
+	return nil
+}
+
+// Publish copies msg, with its original headers plus the failure details, to
+// the dead-letter stream.
+func (d *DLQ) Publish(msg *nats.Msg, reason error, deliveries int) error {
+	dl := nats.NewMsg(d.subjectPrefix() + msg.Subject)
+	for k, v := range msg.Header {
+		dl.Header[k] = append([]string(nil), v...)
+	}
+	dl.Header.Set(HeaderDLQReason, reason.Error())
+	dl.Header.Set(HeaderDLQSubject, msg.Subject)
+	dl.Header.Set(HeaderDLQDeliveries, strconv.Itoa(deliveries))
+	dl.Header.Set(HeaderDLQFailedAt, time.Now().UTC().Format(time.RFC3339Nano))
+	if meta, err := msg.Metadata(); err == nil {
+		dl.Header.Set(HeaderDLQStream, meta.Stream)
+		dl.Header.Set(HeaderDLQSequence, strconv.FormatUint(meta.Sequence.Stream, 10))
+	}
+	dl.Data = msg.Data
+
+	if _, err := d.js.PublishMsg(dl); err != nil {
+		return fmt.Errorf("publish to dlq: %w", err)
+	}
+	return nil
+}
+
+// List returns up to limit dead letters starting at sequence from (0 means
+// the oldest one still stored).
+func (d *DLQ) List(from uint64, limit int) ([]DeadLetter, error) {
+	info, err := d.js.StreamInfo(d.Stream())
+	if err != nil {
+		return nil, fmt.Errorf("dlq stream info: %w", err)
+	}
+	if from < info.State.FirstSeq {
+		from = info.State.FirstSeq
+	}
+	var out []DeadLetter
+	for seq := from; seq <= info.State.LastSeq && len(out) < limit; seq++ {
+		dl, err := d.Get(seq)
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, *dl)
+	}
+	return out, nil
+}
+
+// Get reads a single dead letter by its DLQ stream sequence.
+func (d *DLQ) Get(seq uint64) (*DeadLetter, error) {
+	raw, err := d.js.GetMsg(d.Stream(), seq)
+	if err != nil {
+		return nil, err
+	}
+	dl := &DeadLetter{
+		Sequence: raw.Sequence,
+		Subject:  raw.Header.Get(HeaderDLQSubject),
+		Stream:   raw.Header.Get(HeaderDLQStream),
+		Reason:   raw.Header.Get(HeaderDLQReason),
+		Header:   raw.Header,
+		Data:     raw.Data,
+	}
+	if dl.Subject == "" {
+		dl.Subject = strings.TrimPrefix(raw.Subject, d.subjectPrefix())
+	}
+	dl.StreamSequence, _ = strconv.ParseUint(raw.Header.Get(HeaderDLQSequence), 10, 64)
+	dl.Deliveries, _ = strconv.Atoi(raw.Header.Get(HeaderDLQDeliveries))
+	dl.FailedAt, _ = time.Parse(time.RFC3339Nano, raw.Header.Get(HeaderDLQFailedAt))
+	return dl, nil
+}
+
+// Republish puts a dead letter back onto its original subject and removes it
+// from the DLQ. DLQ headers are stripped, and so is Nats-Msg-Id so that
+// JetStream does not discard the replay as a duplicate.
+func (d *DLQ) Republish(seq uint64) error {
+	dl, err := d.Get(seq)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(dl.Subject)
+	for k, v := range dl.Header {
+		if strings.HasPrefix(k, "Mcp-Dlq-") || k == nats.MsgIdHdr {
+			continue
+		}
+		msg.Header[k] = v
+	}
+	msg.Header.Set(HeaderDLQReplayedOf, strconv.FormatUint(seq, 10))
+	msg.Data = dl.Data
+
+	if _, err := d.js.PublishMsg(msg); err != nil {
+		return fmt.Errorf("republish dlq message %d: %w", seq, err)
+	}
+	if err := d.js.DeleteMsg(d.Stream(), seq); err != nil {
+		return fmt.Errorf("delete dlq message %d: %w", seq, err)
+	}
+	d.logger.Info("dlq message republished", "seq", seq, "subject", dl.Subject)
+	return nil
+}
+
+// Retrier settles a handled message: ack on success, nak with backoff on
+// failure, and dead-letter once the subject's retry policy is exhausted or
+// the error is permanent.
+type Retrier struct {
+	policies *RetryPolicies
+	dlq      *DLQ
+	logger   *slog.Logger
+}
+
+func NewRetrier(policies *RetryPolicies, dlq *DLQ, logger *slog.Logger) *Retrier {
+	return &Retrier{policies: policies, dlq: dlq, logger: logger}
+}
+
+// Settle acknowledges msg according to the handler result herr.
+func (r *Retrier) Settle(msg *nats.Msg, herr error) {
+	if herr == nil {
+		_ = msg.Ack()
+		return
+	}
+
+	deliveries := 1
+	if meta, err := msg.Metadata(); err == nil {
+		deliveries = int(meta.NumDelivered)
+	}
+	policy := r.policies.For(msg.Subject)
+
+	if !IsPermanent(herr) && !policy.Exhausted(deliveries) {
+		delay := policy.Backoff(deliveries)
+		r.logger.Warn("handler failed, retrying", "subject", msg.Subject, "deliveries", deliveries, "delay", delay, "error", herr)
+		_ = msg.NakWithDelay(delay)
+		return
+	}
+
+	if err := r.dlq.Publish(msg, herr, deliveries); err != nil {
+		// Keep the message in the stream; it is retried until the DLQ accepts it.
+		r.logger.Error("dead-letter failed", "subject", msg.Subject, "error", err)
+		_ = msg.NakWithDelay(policy.Backoff(deliveries))
+		return
+	}
+	r.logger.Error("message dead-lettered", "subject", msg.Subject, "deliveries", deliveries, "error", herr)
+	_ = msg.Term()
+}
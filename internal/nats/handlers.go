@@ -2,43 +2,17 @@ package nats
 
 import (
 	"context"
-	"encoding/json"
 	"time"
 
-	"github.com/nats-io/nats.go"
 	"modelo-mcp/internal/config"
-	"modelo-mcp/internal/log"
+	examplev1 "modelo-mcp/pkg/contracts/example/v1"
 )
 
-type ExampleRequest struct {
-	Message string `json:"message"`
-}
-
-type ExampleReply struct {
-	Echo     string    `json:"echo"`
-	Service  string    `json:"service"`
-	Ts       time.Time `json:"ts"`
-}
-
-func RegisterExampleHandlers(ctx context.Context, js nats.JetStreamContext, cfg *config.Config, logger *slog.Logger) {
-	subject := cfg.SubjectRequest
-	durable := cfg.NATSDurable
-
-	_, err := js.Subscribe(subject, func(msg *nats.Msg) {
-		var req ExampleRequest
-		_ = json.Unmarshal(msg.Data, &req)
-
-		reply := ExampleReply{
-			Echo:    req.Message,
-			Service: cfg.ServiceName,
-			Ts:      time.Now().UTC(),
-		}
-		b, _ := json.Marshal(reply)
-		_ = js.Publish(cfg.SubjectReply, b)
-		_ = msg.Ack()
-		logger.Info("handled message", "subject", subject)
-	}, nats.Durable(durable), nats.ManualAck())
-	if err != nil {
-		logger.Error("subscribe failed", "error", err)
-	}
+// RegisterExampleHandlers registers the example request handler. Redelivered
+// requests are answered from idem instead of being processed twice.
+func RegisterExampleHandlers(reg *Registry, cfg *config.Config, idem IdempotencyStore) {
+	Handle(reg, cfg.NATS.Subjects.Request, func(ctx context.Context, req examplev1.ExampleRequest) (examplev1.ExampleReply, error) {
+		return examplev1.NewExampleReply(req.Message, cfg.ServiceName, time.Now().UTC()), nil
+	}, WithDurable(cfg.NATS.Durable), WithReplySubject(cfg.NATS.Subjects.Reply),
+		WithIdempotency(idem, MsgIDKey, cfg.NATS.Idempotency.TTL, cfg.NATS.Idempotency.Lease))
 }
@@ -0,0 +1,28 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ConnCheck fails while the connection is not established, including while
+// it is reconnecting.
+func ConnCheck(nc *nats.Conn) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if status := nc.Status(); status != nats.CONNECTED {
+			return fmt.Errorf("connection %s", status)
+		}
+		return nil
+	}
+}
+
+// StreamCheck fails when the stream cannot be looked up, which covers both
+// JetStream being unavailable and the stream missing.
+func StreamCheck(js nats.JetStreamContext, stream string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := js.StreamInfo(stream, nats.Context(ctx))
+		return err
+	}
+}
@@ -0,0 +1,315 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/nats-io/nats.go"
+)
+
+// ErrInFlight is returned when another delivery of the same message was
+// still being processed after waiting for it for a whole lease, or until the
+// context ended; the message is nak'd and retried later.
+var ErrInFlight = errors.New("duplicate message is still being processed")
+
+// claimAttempts bounds how often Claim retries after the key it lost the
+// race for expired before it could be read.
+const claimAttempts = 3
+
+// ClaimState is the outcome of IdempotencyStore.Claim.
+type ClaimState int
+
+const (
+	// Claimed means this delivery owns the key and must process the message.
+	Claimed ClaimState = iota
+	// Pending means another delivery is processing the key right now.
+	Pending
+	// Completed means the key was processed; the cached reply is returned.
+	Completed
+)
+
+// IdempotencyStore remembers processed message keys and their replies.
+type IdempotencyStore interface {
+	// Claim reserves key for processing for at most lease.
+	Claim(ctx context.Context, key string, lease time.Duration) (ClaimState, []byte, error)
+	// Complete stores the reply for key, kept for ttl.
+	Complete(ctx context.Context, key string, reply []byte, ttl time.Duration) error
+	// Release drops a claim after a failed attempt so a retry can reclaim it.
+	Release(ctx context.Context, key string) error
+}
+
+// KeyFunc extracts the idempotency key of a message. An empty key disables
+// deduplication for that message.
+type KeyFunc func(msg *nats.Msg) (string, error)
+
+// MsgIDKey uses the Nats-Msg-Id header set by the publisher.
+func MsgIDKey(msg *nats.Msg) (string, error) {
+	return msg.Header.Get(nats.MsgIdHdr), nil
+}
+
+// PayloadFieldKey uses a field of the JSON payload; nested fields are
+// addressed with dots, e.g. "order.id".
+func PayloadFieldKey(field string) KeyFunc {
+	path := strings.Split(field, ".")
+	return func(msg *nats.Msg) (string, error) {
+		var doc any
+		dec := json.NewDecoder(bytes.NewReader(msg.Data))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return "", Permanent(fmt.Errorf("idempotency key: %w", err))
+		}
+		for _, p := range path {
+			obj, ok := doc.(map[string]any)
+			if !ok {
+				return "", nil
+			}
+			doc = obj[p]
+		}
+		switch v := doc.(type) {
+		case nil:
+			return "", nil
+		case string:
+			return v, nil
+		default:
+			return fmt.Sprint(v), nil
+		}
+	}
+}
+
+// idempotency is the per-route configuration set by WithIdempotency.
+type idempotency struct {
+	store IdempotencyStore
+	key   KeyFunc
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// WithIdempotency deduplicates deliveries of the subject by key: a message
+// whose key was already processed is not handed to the handler again and
+// its cached reply is re-published instead. Replies are kept for ttl. A
+// duplicate that arrives while the key is being processed waits for that
+// outcome rather than being retried.
+//
+// A key stays claimed for lease while the handler runs. The lease is never
+// shorter than the consumer's AckWait, which is also what zero means:
+// otherwise a redelivery after AckWait could reclaim the key and process the
+// message a second time.
+func WithIdempotency(store IdempotencyStore, key KeyFunc, ttl, lease time.Duration) RouteOption {
+	return func(r *route) {
+		r.idempotency = &idempotency{store: store, key: key, ttl: ttl, lease: lease}
+	}
+}
+
+// defaultAckWait is the JetStream AckWait of consumers that do not set one.
+const defaultAckWait = 30 * time.Second
+
+// leaseFor returns the lease to use with a consumer whose AckWait is ackWait.
+func (i *idempotency) leaseFor(ackWait time.Duration) time.Duration {
+	if ackWait <= 0 {
+		ackWait = defaultAckWait
+	}
+	return max(i.lease, ackWait)
+}
+
+// run wraps process with the claim/complete protocol and returns the reply
+// to publish.
+func (i *idempotency) run(ctx context.Context, msg *nats.Msg, process func() ([]byte, error)) ([]byte, error) {
+	key, err := i.key(msg)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return process()
+	}
+	key = msg.Subject + ":" + key
+
+	state, cached, err := i.store.Claim(ctx, key, i.lease)
+	if err != nil {
+		return nil, fmt.Errorf("idempotency claim: %w", err)
+	}
+	if state == Pending {
+		state, cached, err = i.wait(ctx, msg, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if state == Completed {
+		return cached, nil
+	}
+
+	reply, err := process()
+	if err != nil {
+		if rerr := i.store.Release(ctx, key); rerr != nil {
+			return nil, errors.Join(err, fmt.Errorf("idempotency release: %w", rerr))
+		}
+		return nil, err
+	}
+	if err := i.store.Complete(ctx, key, reply, i.ttl); err != nil {
+		return nil, fmt.Errorf("idempotency complete: %w", err)
+	}
+	return reply, nil
+}
+
+// wait polls a key claimed by another delivery until it is completed or can
+// be claimed, for at most one lease. The message is reported in progress
+// meanwhile, so the wait neither triggers a redelivery nor spends one.
+func (i *idempotency) wait(ctx context.Context, msg *nats.Msg, key string) (ClaimState, []byte, error) {
+	interval := min(i.lease/4, time.Second)
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(i.lease)
+	for {
+		_ = msg.InProgress()
+		select {
+		case <-ctx.Done():
+			return 0, nil, errors.Join(ErrInFlight, ctx.Err())
+		case <-ticker.C:
+		}
+		state, cached, err := i.store.Claim(ctx, key, i.lease)
+		if err != nil {
+			return 0, nil, fmt.Errorf("idempotency claim: %w", err)
+		}
+		if state != Pending {
+			return state, cached, nil
+		}
+		if time.Now().After(deadline) {
+			return 0, nil, ErrInFlight
+		}
+	}
+}
+
+// Stored values are a one-byte state marker followed by the reply (done) or
+// the claim time in unix nanoseconds (pending).
+const (
+	markPending = 'p'
+	markDone    = 'd'
+)
+
+// RedisIdempotencyStore keeps keys in Redis, e.g. the client returned by
+// database.NewRedisClient.
+type RedisIdempotencyStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisIdempotencyStore(client *redis.Client, prefix string) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{client: client, prefix: prefix}
+}
+
+func (s *RedisIdempotencyStore) Claim(ctx context.Context, key string, lease time.Duration) (ClaimState, []byte, error) {
+	k := s.prefix + key
+	for attempt := 0; attempt < claimAttempts; attempt++ {
+		ok, err := s.client.SetNX(ctx, k, []byte{markPending}, lease).Result()
+		if err != nil {
+			return 0, nil, err
+		}
+		if ok {
+			return Claimed, nil, nil
+		}
+		val, err := s.client.Get(ctx, k).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// Expired between SETNX and GET; try again.
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(val) > 0 && val[0] == markDone {
+			return Completed, val[1:], nil
+		}
+		return Pending, nil, nil
+	}
+	return 0, nil, fmt.Errorf("claim %s: key expired while being read %d times", key, claimAttempts)
+}
+
+func (s *RedisIdempotencyStore) Complete(ctx context.Context, key string, reply []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, append([]byte{markDone}, reply...), ttl).Err()
+}
+
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+// KVIdempotencyStore keeps keys in a JetStream KV bucket. KV entries expire
+// with the bucket TTL, so the ttl passed to Complete is not used per key.
+type KVIdempotencyStore struct {
+	kv nats.KeyValue
+}
+
+// NewKVIdempotencyStore binds to bucket, creating it with ttl if needed.
+func NewKVIdempotencyStore(js nats.JetStreamContext, bucket string, ttl time.Duration) (*KVIdempotencyStore, error) {
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "idempotency keys of NATS handlers",
+			TTL:         ttl,
+			History:     1,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("idempotency bucket %s: %w", bucket, err)
+	}
+	return &KVIdempotencyStore{kv: kv}, nil
+}
+
+// kvKey hashes key because KV keys only allow a restricted character set.
+func kvKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *KVIdempotencyStore) Claim(ctx context.Context, key string, lease time.Duration) (ClaimState, []byte, error) {
+	k := kvKey(key)
+	for attempt := 0; attempt < claimAttempts; attempt++ {
+		pending := append([]byte{markPending}, strconv.FormatInt(time.Now().UnixNano(), 10)...)
+		if _, err := s.kv.Create(k, pending); err == nil {
+			return Claimed, nil, nil
+		} else if !errors.Is(err, nats.ErrKeyExists) {
+			return 0, nil, err
+		}
+
+		entry, err := s.kv.Get(k)
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			// Purged between Create and Get; try again.
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		val := entry.Value()
+		if len(val) > 0 && val[0] == markDone {
+			return Completed, val[1:], nil
+		}
+		// Take over a claim whose owner died without completing or releasing it.
+		since, _ := strconv.ParseInt(string(val[min(1, len(val)):]), 10, 64)
+		if time.Since(time.Unix(0, since)) > lease {
+			if _, err := s.kv.Update(k, pending, entry.Revision()); err == nil {
+				return Claimed, nil, nil
+			}
+		}
+		return Pending, nil, nil
+	}
+	return 0, nil, fmt.Errorf("claim %s: key purged while being read %d times", key, claimAttempts)
+}
+
+func (s *KVIdempotencyStore) Complete(ctx context.Context, key string, reply []byte, ttl time.Duration) error {
+	_, err := s.kv.Put(kvKey(key), append([]byte{markDone}, reply...))
+	return err
+}
+
+func (s *KVIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.kv.Purge(kvKey(key))
+}
@@ -0,0 +1,214 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/nats-io/nats.go"
+)
+
+func newTestIdempotencyStore(t *testing.T) (*miniredis.Miniredis, *redis.Client, *RedisIdempotencyStore) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client, NewRedisIdempotencyStore(client, "idem:")
+}
+
+func TestRedisIdempotencyStore(t *testing.T) {
+	mr, _, s := newTestIdempotencyStore(t)
+	ctx := context.Background()
+	claim := func(want ClaimState) []byte {
+		t.Helper()
+		state, reply, err := s.Claim(ctx, "k", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != want {
+			t.Fatalf("state = %d, want %d", state, want)
+		}
+		return reply
+	}
+
+	claim(Claimed)
+	claim(Pending)
+	if ttl := mr.TTL("idem:k"); ttl != time.Minute {
+		t.Errorf("claim TTL = %v, want the lease", ttl)
+	}
+
+	if err := s.Release(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	claim(Claimed)
+	if err := s.Complete(ctx, "k", []byte(`{"ok":true}`), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if reply := claim(Completed); string(reply) != `{"ok":true}` {
+		t.Errorf("reply = %s", reply)
+	}
+
+	// A claim whose owner died expires with the lease.
+	if _, _, err := s.Claim(ctx, "dead", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(2 * time.Second)
+	if state, _, err := s.Claim(ctx, "dead", time.Second); err != nil || state != Claimed {
+		t.Fatalf("after the lease: state %d, err %v; want Claimed", state, err)
+	}
+}
+
+// expiringGets makes every GET look like the key expired right after SETNX
+// found it, and counts the SETNX calls.
+type expiringGets struct{ setnx int }
+
+func (h *expiringGets) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *expiringGets) AfterProcess(_ context.Context, cmd redis.Cmder) error {
+	switch cmd.Name() {
+	case "set", "setnx":
+		h.setnx++
+	case "get":
+		cmd.SetErr(redis.Nil)
+	}
+	return nil
+}
+
+func (h *expiringGets) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *expiringGets) AfterProcessPipeline(context.Context, []redis.Cmder) error { return nil }
+
+func TestRedisIdempotencyStoreClaimIsBounded(t *testing.T) {
+	mr, client, s := newTestIdempotencyStore(t)
+	mr.Set("idem:k", "p")
+	hook := &expiringGets{}
+	client.AddHook(hook)
+
+	_, _, err := s.Claim(context.Background(), "k", time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("err = %v, want the claim to give up", err)
+	}
+	if hook.setnx != claimAttempts {
+		t.Errorf("SETNX sent %d times, want %d", hook.setnx, claimAttempts)
+	}
+}
+
+// purgingKV is a KV bucket whose key is always taken on Create and gone on
+// Get, as if purged in between.
+type purgingKV struct {
+	nats.KeyValue
+	creates int
+}
+
+func (kv *purgingKV) Create(string, []byte) (uint64, error) {
+	kv.creates++
+	return 0, nats.ErrKeyExists
+}
+
+func (kv *purgingKV) Get(string) (nats.KeyValueEntry, error) { return nil, nats.ErrKeyNotFound }
+
+func TestKVIdempotencyStoreClaimIsBounded(t *testing.T) {
+	kv := &purgingKV{}
+	s := &KVIdempotencyStore{kv: kv}
+	if _, _, err := s.Claim(context.Background(), "k", time.Minute); err == nil {
+		t.Fatal("want the claim to give up")
+	}
+	if kv.creates != claimAttempts {
+		t.Errorf("Create called %d times, want %d", kv.creates, claimAttempts)
+	}
+}
+
+func TestIdempotencyWaitsForInFlightDuplicate(t *testing.T) {
+	ctx := context.Background()
+	msg := nats.NewMsg("orders.create")
+	msg.Header.Set(nats.MsgIdHdr, "m1")
+	key := "orders.create:m1"
+
+	tests := []struct {
+		name string
+		// settle ends the first delivery's claim.
+		settle    func(s *RedisIdempotencyStore) error
+		want      string
+		processed bool
+	}{
+		{
+			name:   "first delivery completes",
+			settle: func(s *RedisIdempotencyStore) error { return s.Complete(ctx, key, []byte("cached"), time.Hour) },
+			want:   "cached",
+		},
+		{
+			name:      "first delivery fails",
+			settle:    func(s *RedisIdempotencyStore) error { return s.Release(ctx, key) },
+			want:      "fresh",
+			processed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, s := newTestIdempotencyStore(t)
+			i := &idempotency{store: s, key: MsgIDKey, ttl: time.Hour, lease: 200 * time.Millisecond}
+			if state, _, err := s.Claim(ctx, key, time.Minute); err != nil || state != Claimed {
+				t.Fatalf("first claim: %d, %v", state, err)
+			}
+
+			processed := false
+			done := make(chan struct{})
+			var reply []byte
+			var err error
+			go func() {
+				defer close(done)
+				reply, err = i.run(ctx, msg, func() ([]byte, error) {
+					processed = true
+					return []byte("fresh"), nil
+				})
+			}()
+			time.Sleep(60 * time.Millisecond)
+			if serr := tt.settle(s); serr != nil {
+				t.Fatal(serr)
+			}
+			<-done
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(reply) != tt.want || processed != tt.processed {
+				t.Errorf("reply %q, processed %v; want %q, %v", reply, processed, tt.want, tt.processed)
+			}
+		})
+	}
+
+	t.Run("still in flight after the lease", func(t *testing.T) {
+		_, _, s := newTestIdempotencyStore(t)
+		i := &idempotency{store: s, key: MsgIDKey, ttl: time.Hour, lease: 40 * time.Millisecond}
+		if _, _, err := s.Claim(ctx, key, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		_, err := i.run(ctx, msg, func() ([]byte, error) {
+			t.Error("duplicate processed")
+			return nil, nil
+		})
+		if !errors.Is(err, ErrInFlight) {
+			t.Fatalf("err = %v, want ErrInFlight", err)
+		}
+	})
+
+	t.Run("context ends the wait", func(t *testing.T) {
+		_, _, s := newTestIdempotencyStore(t)
+		i := &idempotency{store: s, key: MsgIDKey, ttl: time.Hour, lease: time.Minute}
+		if _, _, err := s.Claim(ctx, key, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		cctx, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+		defer cancel()
+		if _, err := i.run(cctx, msg, nil); !errors.Is(err, ErrInFlight) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want ErrInFlight and DeadlineExceeded", err)
+		}
+	})
+}
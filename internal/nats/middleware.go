@@ -0,0 +1,97 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"modelo-mcp/internal/metrics"
+)
+
+const tracerName = "modelo-mcp/internal/nats"
+
+// Recover turns a handler panic into an error so the message is retried
+// instead of crashing the subscription goroutine.
+func Recover(logger *slog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *nats.Msg) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					logger.Error("handler panic", "subject", msg.Subject, "panic", p, "stack", string(debug.Stack()))
+					err = fmt.Errorf("handler panic: %v", p)
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Logging logs the outcome and duration of every message.
+func Logging(logger *slog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			correlationID := CorrelationIDFromContext(ctx)
+			if err != nil {
+				logger.Error("handler failed", "subject", msg.Subject, "correlation_id", correlationID, "duration", time.Since(start), "error", err)
+				return err
+			}
+			logger.Info("handled message", "subject", msg.Subject, "correlation_id", correlationID, "duration", time.Since(start))
+			return nil
+		}
+	}
+}
+
+// Metrics records message counts and processing time per registered subject.
+func Metrics(m *metrics.NATSMetrics) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			subject := SubjectFromContext(ctx)
+			start := time.Now()
+			err := next(ctx, msg)
+			status := "ok"
+			if err != nil {
+				status = "error"
+			}
+			m.Messages.WithLabelValues(subject, status).Inc()
+			m.Duration.WithLabelValues(subject).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// Tracing starts a consumer span around the handler, continuing the trace
+// propagated in the message headers.
+func Tracing() Middleware {
+	tracer := otel.Tracer(tracerName)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			ctx = ExtractTrace(ctx, msg)
+			ctx, span := tracer.Start(ctx, SubjectFromContext(ctx)+" process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("messaging.system", "nats"),
+					attribute.String("messaging.destination.name", msg.Subject),
+				),
+				trace.WithAttributes(deliveryAttributes(msg)...),
+			)
+			defer span.End()
+
+			err := next(ctx, msg)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"modelo-mcp/internal/metrics"
+)
+
+func TestMiddlewares(t *testing.T) {
+	ctx := context.WithValue(context.Background(), subjectKey{}, "orders.*")
+	msg := nats.NewMsg("orders.create")
+	failure := errors.New("boom")
+
+	tests := []struct {
+		name    string
+		mw      func(m *metrics.NATSMetrics) Middleware
+		next    HandlerFunc
+		wantErr string
+		status  string
+	}{
+		{
+			name:    "recover turns a panic into an error",
+			mw:      func(*metrics.NATSMetrics) Middleware { return Recover(discard) },
+			next:    func(context.Context, *nats.Msg) error { panic("nil map") },
+			wantErr: "handler panic: nil map",
+		},
+		{
+			name: "recover passes results through",
+			mw:   func(*metrics.NATSMetrics) Middleware { return Recover(discard) },
+			next: func(context.Context, *nats.Msg) error { return nil },
+		},
+		{
+			name:    "logging keeps the error",
+			mw:      func(*metrics.NATSMetrics) Middleware { return Logging(discard) },
+			next:    func(context.Context, *nats.Msg) error { return failure },
+			wantErr: "boom",
+		},
+		{
+			name:   "metrics count success by registered subject",
+			mw:     Metrics,
+			next:   func(context.Context, *nats.Msg) error { return nil },
+			status: "ok",
+		},
+		{
+			name:    "metrics count failure",
+			mw:      Metrics,
+			next:    func(context.Context, *nats.Msg) error { return failure },
+			wantErr: "boom",
+			status:  "error",
+		},
+		{
+			name:    "tracing keeps the error",
+			mw:      func(*metrics.NATSMetrics) Middleware { return Tracing() },
+			next:    func(context.Context, *nats.Msg) error { return failure },
+			wantErr: "boom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := metrics.NewNATSMetrics(prometheus.NewRegistry())
+			err := tt.mw(m)(tt.next)(ctx, msg)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+			if tt.status != "" {
+				if n := testutil.ToFloat64(m.Messages.WithLabelValues("orders.*", tt.status)); n != 1 {
+					t.Errorf("messages{orders.*, %s} = %v, want 1", tt.status, n)
+				}
+			}
+		})
+	}
+}
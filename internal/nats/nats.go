@@ -2,11 +2,11 @@ package nats
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"log/slog"
 
 	"github.com/nats-io/nats.go"
 	"modelo-mcp/internal/config"
-	"modelo-mcp/internal/log"
 )
 
 type JS struct {
@@ -15,19 +15,20 @@ type JS struct {
 }
 
 func Connect(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*nats.Conn, nats.JetStreamContext, error) {
-	nc, err := nats.Connect(cfg.NATSURL, nats.Name(cfg.ServiceName))
+	nc, err := nats.Connect(cfg.NATS.URL, nats.Name(cfg.ServiceName))
 	if err != nil { return nil, nil, err }
 	js, err := nc.JetStream()
 	if err != nil { return nil, nil, err }
 
-	// Ensure stream (idempotent)
-	_, err = js.AddStream(&nats.StreamConfig{
-		Name:     cfg.NATSJSStream,
-		Subjects: []string{"mcp.modelo.>"},
-		MaxAge:  7 * 24 * time.Hour,
-	})
-	if err != nil && err != nats.ErrStreamNameAlreadyInUse {
-		logger.Error("add stream", "error", err)
+	// Reconcile declared streams and consumers
+	streams := cfg.NATS.Provisioning.Streams
+	if len(streams) == 0 {
+		streams = defaultStreams(cfg.NATS.Stream)
+	}
+	prov := NewProvisioner(js, logger, cfg.NATS.Provisioning.DryRun)
+	if _, err := prov.Reconcile(streams); err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("provision jetstream: %w", err)
 	}
 	return nc, js, nil
 }
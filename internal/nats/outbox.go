@@ -0,0 +1,187 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"modelo-mcp/internal/config"
+	"modelo-mcp/internal/database"
+	"modelo-mcp/internal/metrics"
+)
+
+// OutboxRelay publishes pending outbox_events rows to JetStream in insertion
+// order. Rows are claimed with FOR UPDATE SKIP LOCKED, so several replicas can
+// run the relay at once without publishing the same row twice; a crash between
+// publish and commit is covered by Nats-Msg-Id deduplication.
+//
+// A failed event holds back the ones behind it until it has been tried
+// MaxAttempts times; it is then parked (failed_at set) and skipped, so a row
+// that can never be published does not stop the outbox.
+//
+// The row locks are what keep replicas apart, so the batch transaction stays
+// open while its events are published: up to BatchSize PubAck round trips,
+// each bounded by the JetStream publish timeout. Keep BatchSize small enough
+// for that to fit well within the database's idle transaction timeout.
+type OutboxRelay struct {
+	db      *gorm.DB
+	js      nats.JetStreamContext
+	service string
+	cfg     config.OutboxConfig
+	metrics *metrics.OutboxMetrics
+	logger  *slog.Logger
+}
+
+// NewOutboxRelay returns a relay for the outbox of service, whose name scopes
+// the Nats-Msg-Id of the events: row IDs are only unique within one database.
+func NewOutboxRelay(db *gorm.DB, js nats.JetStreamContext, service string, cfg config.OutboxConfig, m *metrics.OutboxMetrics, logger *slog.Logger) *OutboxRelay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	return &OutboxRelay{db: db, js: js, service: service, cfg: cfg, metrics: m, logger: logger}
+}
+
+// Run relays events until ctx is cancelled. A full batch is followed
+// immediately by the next one; otherwise the relay waits PollInterval.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Time{}
+
+	for {
+		n, err := r.relayBatch(ctx)
+		if err != nil {
+			r.logger.Error("outbox relay failed", "error", err)
+		}
+		r.observe(ctx)
+		if r.cfg.Retention > 0 && time.Since(lastCleanup) > time.Hour {
+			r.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+		if err == nil && n == r.cfg.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch publishes the next batch of pending events and returns how many
+// of them were published or parked.
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	handled := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var events []database.OutboxEvent
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND failed_at IS NULL").
+			Order("id").
+			Limit(r.cfg.BatchSize).
+			Find(&events).Error
+		if err != nil {
+			return fmt.Errorf("load pending events: %w", err)
+		}
+
+		for i := range events {
+			e := &events[i]
+			if err := r.publish(ctx, e); err != nil {
+				r.metrics.Failed.Inc()
+				updates := map[string]any{
+					"attempts":   gorm.Expr("attempts + 1"),
+					"last_error": err.Error(),
+				}
+				if e.Attempts+1 < r.cfg.MaxAttempts {
+					r.logger.Warn("outbox publish failed", "id", e.ID, "subject", e.Subject, "attempts", e.Attempts+1, "error", err)
+					// Stop here so later events are not published ahead of this one.
+					return tx.Model(e).Updates(updates).Error
+				}
+				r.logger.Error("outbox event parked", "id", e.ID, "subject", e.Subject, "attempts", e.Attempts+1, "error", err)
+				updates["failed_at"] = time.Now().UTC()
+				if err := tx.Model(e).Updates(updates).Error; err != nil {
+					return fmt.Errorf("park event %d: %w", e.ID, err)
+				}
+				r.metrics.Parked.Inc()
+				handled++
+				continue
+			}
+			now := time.Now().UTC()
+			if err := tx.Model(e).Update("published_at", now).Error; err != nil {
+				return fmt.Errorf("mark event %d published: %w", e.ID, err)
+			}
+			r.metrics.Published.Inc()
+			handled++
+		}
+		return nil
+	})
+	return handled, err
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, e *database.OutboxEvent) error {
+	headers, err := e.HeaderMap()
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(e.Subject)
+	for k, v := range headers {
+		msg.Header.Set(k, v)
+	}
+	msg.Header.Set(nats.MsgIdHdr, r.msgID(e))
+	msg.Data = e.Payload
+	_, err = r.js.PublishMsg(msg, nats.Context(ctx))
+	return err
+}
+
+// msgID is the Nats-Msg-Id of e, e.g. "billing-mcp:outbox_events:42".
+func (r *OutboxRelay) msgID(e *database.OutboxEvent) string {
+	return r.service + ":" + e.TableName() + ":" + strconv.FormatUint(e.ID, 10)
+}
+
+// observe updates the pending count and the age of the oldest pending event;
+// parked events are not pending.
+func (r *OutboxRelay) observe(ctx context.Context) {
+	var stats struct {
+		Pending int64
+		Oldest  *time.Time
+	}
+	err := r.db.WithContext(ctx).Model(&database.OutboxEvent{}).
+		Select("COUNT(*) AS pending, MIN(created_at) AS oldest").
+		Where("published_at IS NULL AND failed_at IS NULL").
+		Scan(&stats).Error
+	if err != nil {
+		r.logger.Warn("outbox stats failed", "error", err)
+		return
+	}
+	r.metrics.Pending.Set(float64(stats.Pending))
+	if stats.Oldest == nil {
+		r.metrics.Lag.Set(0)
+		return
+	}
+	r.metrics.Lag.Set(time.Since(*stats.Oldest).Seconds())
+}
+
+// cleanup deletes events published longer ago than the retention period.
+func (r *OutboxRelay) cleanup(ctx context.Context) {
+	cutoff := time.Now().Add(-r.cfg.Retention)
+	res := r.db.WithContext(ctx).Where("published_at < ?", cutoff).Delete(&database.OutboxEvent{})
+	if res.Error != nil {
+		r.logger.Warn("outbox cleanup failed", "error", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		r.logger.Info("outbox cleanup", "deleted", res.RowsAffected)
+	}
+}
@@ -0,0 +1,211 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm"
+
+	"modelo-mcp/internal/config"
+	"modelo-mcp/internal/database"
+	"modelo-mcp/internal/metrics"
+	"modelo-mcp/migrations"
+)
+
+// fakeJetStream records published messages and rejects those for subjects
+// in reject.
+type fakeJetStream struct {
+	nats.JetStreamContext
+	reject    map[string]bool
+	published []*nats.Msg
+}
+
+func (js *fakeJetStream) PublishMsg(m *nats.Msg, _ ...nats.PubOpt) (*nats.PubAck, error) {
+	if js.reject[m.Subject] {
+		return nil, errors.New("nats: no response from stream")
+	}
+	js.published = append(js.published, m)
+	return &nats.PubAck{Stream: "EVENTS", Sequence: uint64(len(js.published))}, nil
+}
+
+func (js *fakeJetStream) subjects() []string {
+	out := make([]string, len(js.published))
+	for i, m := range js.published {
+		out[i] = m.Subject
+	}
+	return out
+}
+
+// openOutboxDB migrates a fresh outbox_test schema of the PostgreSQL database
+// at TEST_DATABASE_URL.
+func openOutboxDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", "outbox_test")
+	u.RawQuery = q.Encode()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := database.NewConnection(config.DatabaseConfig{URL: u.String(), MaxOpenConns: 2, LogLevel: "silent"}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = database.Close(db) })
+	for _, stmt := range []string{`DROP SCHEMA IF EXISTS outbox_test CASCADE`, `CREATE SCHEMA outbox_test`} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { db.Exec(`DROP SCHEMA IF EXISTS outbox_test CASCADE`) })
+
+	m, err := database.NewMigrator(db, migrations.FS, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestRelay(db *gorm.DB, js *fakeJetStream, maxAttempts int) (*OutboxRelay, *metrics.OutboxMetrics) {
+	m := metrics.NewOutboxMetrics(prometheus.NewRegistry())
+	cfg := config.OutboxConfig{BatchSize: 10, MaxAttempts: maxAttempts}
+	return NewOutboxRelay(db, js, "billing-mcp", cfg, m, slog.New(slog.NewTextHandler(io.Discard, nil))), m
+}
+
+func record(t *testing.T, db *gorm.DB, subjects ...string) []*database.OutboxEvent {
+	t.Helper()
+	var out []*database.OutboxEvent
+	for _, s := range subjects {
+		e, err := database.RecordEvent(db, s, map[string]string{"subject": s}, map[string]string{"X-Source": "test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func load(t *testing.T, db *gorm.DB, id uint64) database.OutboxEvent {
+	t.Helper()
+	var e database.OutboxEvent
+	if err := db.First(&e, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func relay(t *testing.T, r *OutboxRelay) int {
+	t.Helper()
+	n, err := r.relayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestOutboxRelayPublishesInOrder(t *testing.T) {
+	db := openOutboxDB(t)
+	js := &fakeJetStream{}
+	r, m := newTestRelay(db, js, 3)
+	events := record(t, db, "ev.a", "ev.b", "ev.c")
+
+	if n := relay(t, r); n != 3 {
+		t.Fatalf("relayed %d events, want 3", n)
+	}
+	if got := js.subjects(); len(got) != 3 || got[0] != "ev.a" || got[1] != "ev.b" || got[2] != "ev.c" {
+		t.Fatalf("published %v, want ev.a, ev.b, ev.c", got)
+	}
+	msg := js.published[0]
+	if id := msg.Header.Get(nats.MsgIdHdr); id != r.msgID(events[0]) {
+		t.Errorf("Nats-Msg-Id = %q, want %q", id, r.msgID(events[0]))
+	}
+	if msg.Header.Get("X-Source") != "test" || string(msg.Data) != `{"subject":"ev.a"}` {
+		t.Errorf("message = %v %s", msg.Header, msg.Data)
+	}
+	for _, e := range events {
+		if got := load(t, db, e.ID); got.PublishedAt == nil {
+			t.Errorf("event %d not marked published", e.ID)
+		}
+	}
+	if n := testutil.ToFloat64(m.Published); n != 3 {
+		t.Errorf("published metric = %v", n)
+	}
+
+	if n := relay(t, r); n != 0 || len(js.published) != 3 {
+		t.Errorf("second pass relayed %d, published %d in total; want nothing again", n, len(js.published))
+	}
+}
+
+func TestOutboxRelayFailureHoldsBackLaterEvents(t *testing.T) {
+	db := openOutboxDB(t)
+	js := &fakeJetStream{reject: map[string]bool{"ev.stuck": true}}
+	r, m := newTestRelay(db, js, 3)
+	events := record(t, db, "ev.a", "ev.stuck", "ev.c")
+
+	for attempt := 1; attempt < 3; attempt++ {
+		relay(t, r)
+		if got := js.subjects(); len(got) != 1 || got[0] != "ev.a" {
+			t.Fatalf("attempt %d: published %v, want only ev.a", attempt, got)
+		}
+		stuck := load(t, db, events[1].ID)
+		if stuck.Attempts != attempt || stuck.LastError == "" || stuck.FailedAt != nil {
+			t.Fatalf("attempt %d: stuck event = %+v", attempt, stuck)
+		}
+	}
+
+	// The last attempt parks it and lets ev.c through.
+	relay(t, r)
+	if got := js.subjects(); len(got) != 2 || got[1] != "ev.c" {
+		t.Fatalf("published %v, want ev.c after ev.a", got)
+	}
+	stuck := load(t, db, events[1].ID)
+	if stuck.Attempts != 3 || stuck.FailedAt == nil || stuck.PublishedAt != nil {
+		t.Errorf("parked event = %+v", stuck)
+	}
+	if n := testutil.ToFloat64(m.Parked); n != 1 {
+		t.Errorf("parked metric = %v, want 1", n)
+	}
+
+	js.reject = nil
+	if n := relay(t, r); n != 0 || len(js.published) != 2 {
+		t.Errorf("parked event relayed again: %v", js.subjects())
+	}
+}
+
+func TestOutboxRelayParksUndecodableHeaders(t *testing.T) {
+	db := openOutboxDB(t)
+	js := &fakeJetStream{}
+	r, _ := newTestRelay(db, js, 1)
+	poison := &database.OutboxEvent{Subject: "ev.poison", Payload: []byte(`{}`), Headers: []byte(`["not", "a", "map"]`)}
+	if err := db.Create(poison).Error; err != nil {
+		t.Fatal(err)
+	}
+	record(t, db, "ev.after")
+
+	if n := relay(t, r); n != 2 {
+		t.Fatalf("relayed %d events, want the poison row parked and 1 published", n)
+	}
+	if got := js.subjects(); len(got) != 1 || got[0] != "ev.after" {
+		t.Fatalf("published %v, want ev.after", got)
+	}
+	if got := load(t, db, poison.ID); got.FailedAt == nil {
+		t.Errorf("poison row not parked: %+v", got)
+	}
+}
@@ -0,0 +1,335 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"modelo-mcp/internal/config"
+)
+
+// Drift is a difference between a declared stream or consumer and the
+// server.
+type Drift struct {
+	Stream   string
+	Consumer string
+	Action   string // create or update
+	Changes  []string
+}
+
+// Provisioner reconciles the streams and consumers declared in
+// nats.provisioning with the server.
+type Provisioner struct {
+	js     nats.JetStreamContext
+	logger *slog.Logger
+	dryRun bool
+}
+
+func NewProvisioner(js nats.JetStreamContext, logger *slog.Logger, dryRun bool) *Provisioner {
+	return &Provisioner{js: js, logger: logger, dryRun: dryRun}
+}
+
+// Reconcile creates missing streams and consumers and updates drifted ones.
+// In dry-run mode nothing is changed and the drift is only logged and
+// returned. Changes the server cannot apply in place (storage, retention,
+// deliver policy) are reported as errors.
+func (p *Provisioner) Reconcile(streams []config.StreamConfig) ([]Drift, error) {
+	var drift []Drift
+	var errs []error
+	for _, spec := range streams {
+		d, err := p.reconcileStream(spec)
+		drift = append(drift, d...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stream %s: %w", spec.Name, err))
+		}
+	}
+	return drift, errors.Join(errs...)
+}
+
+func (p *Provisioner) reconcileStream(spec config.StreamConfig) ([]Drift, error) {
+	var drift []Drift
+	info, err := p.js.StreamInfo(spec.Name)
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		want, err := applyStream(&nats.StreamConfig{Name: spec.Name}, spec)
+		if err != nil {
+			return nil, err
+		}
+		drift = append(drift, p.report(Drift{Stream: spec.Name, Action: "create", Changes: []string{"stream missing"}}))
+		if !p.dryRun {
+			if _, err := p.js.AddStream(want); err != nil {
+				return drift, fmt.Errorf("create: %w", err)
+			}
+		}
+	case err != nil:
+		return nil, err
+	default:
+		cur := info.Config
+		want, err := applyStream(&cur, spec)
+		if err != nil {
+			return nil, err
+		}
+		changes, immutable := diffStream(&info.Config, want)
+		if len(changes) > 0 {
+			drift = append(drift, p.report(Drift{Stream: spec.Name, Action: "update", Changes: changes}))
+			if len(immutable) > 0 {
+				return drift, fmt.Errorf("cannot change %v in place; recreate the stream", immutable)
+			}
+			if !p.dryRun {
+				if _, err := p.js.UpdateStream(want); err != nil {
+					return drift, fmt.Errorf("update: %w", err)
+				}
+			}
+		}
+	}
+
+	var errs []error
+	for _, c := range spec.Consumers {
+		d, err := p.reconcileConsumer(spec.Name, c)
+		drift = append(drift, d...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("consumer %s: %w", c.Durable, err))
+		}
+	}
+	return drift, errors.Join(errs...)
+}
+
+func (p *Provisioner) reconcileConsumer(stream string, spec config.StreamConsumer) ([]Drift, error) {
+	info, err := p.js.ConsumerInfo(stream, spec.Durable)
+	switch {
+	case errors.Is(err, nats.ErrConsumerNotFound), errors.Is(err, nats.ErrStreamNotFound):
+		want, err := applyConsumer(&nats.ConsumerConfig{Durable: spec.Durable, AckPolicy: nats.AckExplicitPolicy}, spec)
+		if err != nil {
+			return nil, err
+		}
+		drift := []Drift{p.report(Drift{Stream: stream, Consumer: spec.Durable, Action: "create", Changes: []string{"consumer missing"}})}
+		if !p.dryRun {
+			if _, err := p.js.AddConsumer(stream, want); err != nil {
+				return drift, fmt.Errorf("create: %w", err)
+			}
+		}
+		return drift, nil
+	case err != nil:
+		return nil, err
+	}
+
+	cur := info.Config
+	want, err := applyConsumer(&cur, spec)
+	if err != nil {
+		return nil, err
+	}
+	changes, immutable := diffConsumer(&info.Config, want)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	drift := []Drift{p.report(Drift{Stream: stream, Consumer: spec.Durable, Action: "update", Changes: changes})}
+	if len(immutable) > 0 {
+		return drift, fmt.Errorf("cannot change %v in place; recreate the consumer", immutable)
+	}
+	if !p.dryRun {
+		if _, err := p.js.UpdateConsumer(stream, want); err != nil {
+			return drift, fmt.Errorf("update: %w", err)
+		}
+	}
+	return drift, nil
+}
+
+func (p *Provisioner) report(d Drift) Drift {
+	msg := "jetstream drift reconciled"
+	if p.dryRun {
+		msg = "jetstream drift detected (dry run)"
+	}
+	p.logger.Info(msg, "stream", d.Stream, "consumer", d.Consumer, "action", d.Action, "changes", d.Changes)
+	return d
+}
+
+// applyStream returns a copy of base with the fields declared in spec set.
+func applyStream(base *nats.StreamConfig, spec config.StreamConfig) (*nats.StreamConfig, error) {
+	c := *base
+	c.Subjects = slices.Clone(base.Subjects)
+	if spec.Description != "" {
+		c.Description = spec.Description
+	}
+	if len(spec.Subjects) > 0 {
+		c.Subjects = slices.Clone(spec.Subjects)
+	}
+	switch spec.Retention {
+	case "":
+	case "limits":
+		c.Retention = nats.LimitsPolicy
+	case "interest":
+		c.Retention = nats.InterestPolicy
+	case "workqueue":
+		c.Retention = nats.WorkQueuePolicy
+	default:
+		return nil, fmt.Errorf("unknown retention %q", spec.Retention)
+	}
+	switch spec.Storage {
+	case "":
+	case "file":
+		c.Storage = nats.FileStorage
+	case "memory":
+		c.Storage = nats.MemoryStorage
+	default:
+		return nil, fmt.Errorf("unknown storage %q", spec.Storage)
+	}
+	switch spec.Discard {
+	case "":
+	case "old":
+		c.Discard = nats.DiscardOld
+	case "new":
+		c.Discard = nats.DiscardNew
+	default:
+		return nil, fmt.Errorf("unknown discard policy %q", spec.Discard)
+	}
+	if spec.Replicas != 0 {
+		c.Replicas = spec.Replicas
+	}
+	if spec.MaxAge != 0 {
+		c.MaxAge = spec.MaxAge
+	}
+	if spec.MaxBytes != 0 {
+		c.MaxBytes = spec.MaxBytes
+	}
+	if spec.MaxMsgs != 0 {
+		c.MaxMsgs = spec.MaxMsgs
+	}
+	if spec.MaxMsgSize != 0 {
+		c.MaxMsgSize = spec.MaxMsgSize
+	}
+	if spec.Duplicates != 0 {
+		c.Duplicates = spec.Duplicates
+	}
+	return &c, nil
+}
+
+// applyConsumer returns a copy of base with the fields declared in spec set.
+func applyConsumer(base *nats.ConsumerConfig, spec config.StreamConsumer) (*nats.ConsumerConfig, error) {
+	c := *base
+	if spec.Description != "" {
+		c.Description = spec.Description
+	}
+	if spec.FilterSubject != "" {
+		c.FilterSubject = spec.FilterSubject
+	}
+	switch spec.DeliverPolicy {
+	case "":
+	case "all":
+		c.DeliverPolicy = nats.DeliverAllPolicy
+	case "new":
+		c.DeliverPolicy = nats.DeliverNewPolicy
+	case "last":
+		c.DeliverPolicy = nats.DeliverLastPolicy
+	default:
+		return nil, fmt.Errorf("unknown deliver policy %q", spec.DeliverPolicy)
+	}
+	switch spec.AckPolicy {
+	case "":
+	case "explicit":
+		c.AckPolicy = nats.AckExplicitPolicy
+	case "all":
+		c.AckPolicy = nats.AckAllPolicy
+	case "none":
+		c.AckPolicy = nats.AckNonePolicy
+	default:
+		return nil, fmt.Errorf("unknown ack policy %q", spec.AckPolicy)
+	}
+	if spec.AckWait != 0 {
+		c.AckWait = spec.AckWait
+	}
+	if spec.MaxDeliver != 0 {
+		c.MaxDeliver = spec.MaxDeliver
+	}
+	if spec.MaxAckPending != 0 {
+		c.MaxAckPending = spec.MaxAckPending
+	}
+	return &c, nil
+}
+
+// diffStream lists the changed fields; immutable holds those the server
+// rejects on update.
+func diffStream(cur, want *nats.StreamConfig) (changes, immutable []string) {
+	add := func(field string, from, to any) {
+		changes = append(changes, fmt.Sprintf("%s: %v -> %v", field, from, to))
+	}
+	if cur.Description != want.Description {
+		add("description", cur.Description, want.Description)
+	}
+	if !slices.Equal(cur.Subjects, want.Subjects) {
+		add("subjects", cur.Subjects, want.Subjects)
+	}
+	if cur.Retention != want.Retention {
+		add("retention", cur.Retention, want.Retention)
+		immutable = append(immutable, "retention")
+	}
+	if cur.Storage != want.Storage {
+		add("storage", cur.Storage, want.Storage)
+		immutable = append(immutable, "storage")
+	}
+	if cur.Discard != want.Discard {
+		add("discard", cur.Discard, want.Discard)
+	}
+	if cur.Replicas != want.Replicas {
+		add("replicas", cur.Replicas, want.Replicas)
+	}
+	if cur.MaxAge != want.MaxAge {
+		add("max_age", cur.MaxAge, want.MaxAge)
+	}
+	if cur.MaxBytes != want.MaxBytes {
+		add("max_bytes", cur.MaxBytes, want.MaxBytes)
+	}
+	if cur.MaxMsgs != want.MaxMsgs {
+		add("max_msgs", cur.MaxMsgs, want.MaxMsgs)
+	}
+	if cur.MaxMsgSize != want.MaxMsgSize {
+		add("max_msg_size", cur.MaxMsgSize, want.MaxMsgSize)
+	}
+	if cur.Duplicates != want.Duplicates {
+		add("duplicates", cur.Duplicates, want.Duplicates)
+	}
+	return changes, immutable
+}
+
+func diffConsumer(cur, want *nats.ConsumerConfig) (changes, immutable []string) {
+	add := func(field string, from, to any) {
+		changes = append(changes, fmt.Sprintf("%s: %v -> %v", field, from, to))
+	}
+	if cur.Description != want.Description {
+		add("description", cur.Description, want.Description)
+	}
+	if cur.FilterSubject != want.FilterSubject {
+		add("filter_subject", cur.FilterSubject, want.FilterSubject)
+	}
+	if cur.DeliverPolicy != want.DeliverPolicy {
+		add("deliver_policy", cur.DeliverPolicy, want.DeliverPolicy)
+		immutable = append(immutable, "deliver_policy")
+	}
+	if cur.AckPolicy != want.AckPolicy {
+		add("ack_policy", cur.AckPolicy, want.AckPolicy)
+		immutable = append(immutable, "ack_policy")
+	}
+	if cur.AckWait != want.AckWait {
+		add("ack_wait", cur.AckWait, want.AckWait)
+	}
+	if cur.MaxDeliver != want.MaxDeliver {
+		add("max_deliver", cur.MaxDeliver, want.MaxDeliver)
+	}
+	if cur.MaxAckPending != want.MaxAckPending {
+		add("max_ack_pending", cur.MaxAckPending, want.MaxAckPending)
+	}
+	return changes, immutable
+}
+
+// defaultStreams is used when nats.provisioning declares no stream, matching
+// what Connect created before streams were configurable.
+func defaultStreams(name string) []config.StreamConfig {
+	return []config.StreamConfig{{
+		Name:     name,
+		Subjects: []string{"mcp.modelo.>"},
+		MaxAge:   7 * 24 * time.Hour,
+	}}
+}
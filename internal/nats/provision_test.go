@@ -0,0 +1,289 @@
+package nats
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"modelo-mcp/internal/config"
+)
+
+func TestDiffStream(t *testing.T) {
+	base := nats.StreamConfig{
+		Name:       "EVENTS",
+		Subjects:   []string{"mcp.modelo.>"},
+		Retention:  nats.LimitsPolicy,
+		Storage:    nats.FileStorage,
+		Discard:    nats.DiscardOld,
+		Replicas:   1,
+		MaxAge:     24 * time.Hour,
+		Duplicates: 2 * time.Minute,
+	}
+	tests := []struct {
+		name      string
+		change    func(c *nats.StreamConfig)
+		changes   []string
+		immutable []string
+	}{
+		{name: "identical", change: func(*nats.StreamConfig) {}},
+		{
+			name:    "subjects",
+			change:  func(c *nats.StreamConfig) { c.Subjects = []string{"mcp.modelo.>", "mcp.audit.>"} },
+			changes: []string{"subjects: [mcp.modelo.>] -> [mcp.modelo.> mcp.audit.>]"},
+		},
+		{
+			name:    "subjects removed",
+			change:  func(c *nats.StreamConfig) { c.Subjects = nil },
+			changes: []string{"subjects: [mcp.modelo.>] -> []"},
+		},
+		{
+			name: "limits",
+			change: func(c *nats.StreamConfig) {
+				c.MaxAge = 48 * time.Hour
+				c.MaxBytes = 1 << 30
+				c.MaxMsgs = 1000
+				c.MaxMsgSize = 1 << 20
+				c.Duplicates = time.Minute
+			},
+			changes: []string{
+				"max_age: 24h0m0s -> 48h0m0s",
+				"max_bytes: 0 -> 1073741824",
+				"max_msgs: 0 -> 1000",
+				"max_msg_size: 0 -> 1048576",
+				"duplicates: 2m0s -> 1m0s",
+			},
+		},
+		{
+			name:    "description, discard and replicas",
+			change:  func(c *nats.StreamConfig) { c.Description = "events"; c.Discard = nats.DiscardNew; c.Replicas = 3 },
+			changes: []string{"description:  -> events", "discard: DiscardOld -> DiscardNew", "replicas: 1 -> 3"},
+		},
+		{
+			name:      "retention",
+			change:    func(c *nats.StreamConfig) { c.Retention = nats.WorkQueuePolicy },
+			changes:   []string{"retention: Limits -> WorkQueue"},
+			immutable: []string{"retention"},
+		},
+		{
+			name:      "storage",
+			change:    func(c *nats.StreamConfig) { c.Storage = nats.MemoryStorage; c.MaxAge = time.Hour },
+			changes:   []string{"storage: File -> Memory", "max_age: 24h0m0s -> 1h0m0s"},
+			immutable: []string{"storage"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := base
+			want.Subjects = append([]string(nil), base.Subjects...)
+			tt.change(&want)
+			changes, immutable := diffStream(&base, &want)
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("changes = %q, want %q", changes, tt.changes)
+			}
+			if !reflect.DeepEqual(immutable, tt.immutable) {
+				t.Errorf("immutable = %q, want %q", immutable, tt.immutable)
+			}
+		})
+	}
+}
+
+func TestDiffConsumer(t *testing.T) {
+	base := nats.ConsumerConfig{
+		Durable:       "orders",
+		DeliverPolicy: nats.DeliverAllPolicy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       30 * time.Second,
+		MaxDeliver:    5,
+	}
+	tests := []struct {
+		name      string
+		change    func(c *nats.ConsumerConfig)
+		changes   []string
+		immutable []string
+	}{
+		{name: "identical", change: func(*nats.ConsumerConfig) {}},
+		{
+			name: "mutable",
+			change: func(c *nats.ConsumerConfig) {
+				c.AckWait = time.Minute
+				c.MaxAckPending = 100
+				c.FilterSubject = "orders.>"
+			},
+			changes: []string{"filter_subject:  -> orders.>", "ack_wait: 30s -> 1m0s", "max_ack_pending: 0 -> 100"},
+		},
+		{
+			name:      "policies",
+			change:    func(c *nats.ConsumerConfig) { c.DeliverPolicy = nats.DeliverNewPolicy; c.AckPolicy = nats.AckAllPolicy },
+			changes:   []string{"deliver_policy: 0 -> 2", "ack_policy: AckExplicit -> AckAll"},
+			immutable: []string{"deliver_policy", "ack_policy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := base
+			tt.change(&want)
+			changes, immutable := diffConsumer(&base, &want)
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("changes = %q, want %q", changes, tt.changes)
+			}
+			if !reflect.DeepEqual(immutable, tt.immutable) {
+				t.Errorf("immutable = %q, want %q", immutable, tt.immutable)
+			}
+		})
+	}
+}
+
+func TestApplyStream(t *testing.T) {
+	base := &nats.StreamConfig{Name: "EVENTS", Subjects: []string{"a.>"}, MaxAge: time.Hour, Replicas: 1}
+	got, err := applyStream(base, config.StreamConfig{
+		Subjects:  []string{"b.>"},
+		Retention: "workqueue",
+		Storage:   "memory",
+		Discard:   "new",
+		MaxMsgs:   10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := nats.StreamConfig{Name: "EVENTS", Subjects: []string{"b.>"}, Retention: nats.WorkQueuePolicy,
+		Storage: nats.MemoryStorage, Discard: nats.DiscardNew, MaxAge: time.Hour, Replicas: 1, MaxMsgs: 10}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("got %+v\nwant %+v", *got, want)
+	}
+	if base.Subjects[0] != "a.>" {
+		t.Error("base modified")
+	}
+
+	for _, spec := range []config.StreamConfig{{Retention: "forever"}, {Storage: "tape"}, {Discard: "random"}} {
+		if _, err := applyStream(base, spec); err == nil {
+			t.Errorf("%+v: want an error", spec)
+		}
+	}
+}
+
+// fakeStreams is a JetStream context holding stream and consumer configs.
+type fakeStreams struct {
+	nats.JetStreamContext
+	streams   map[string]*nats.StreamConfig
+	consumers map[string]*nats.ConsumerConfig
+	calls     []string
+}
+
+func (f *fakeStreams) StreamInfo(name string, _ ...nats.JSOpt) (*nats.StreamInfo, error) {
+	c, ok := f.streams[name]
+	if !ok {
+		return nil, nats.ErrStreamNotFound
+	}
+	return &nats.StreamInfo{Config: *c}, nil
+}
+
+func (f *fakeStreams) AddStream(c *nats.StreamConfig, _ ...nats.JSOpt) (*nats.StreamInfo, error) {
+	f.calls = append(f.calls, "add stream "+c.Name)
+	f.streams[c.Name] = c
+	return &nats.StreamInfo{Config: *c}, nil
+}
+
+func (f *fakeStreams) UpdateStream(c *nats.StreamConfig, _ ...nats.JSOpt) (*nats.StreamInfo, error) {
+	f.calls = append(f.calls, "update stream "+c.Name)
+	f.streams[c.Name] = c
+	return &nats.StreamInfo{Config: *c}, nil
+}
+
+func (f *fakeStreams) ConsumerInfo(stream, name string, _ ...nats.JSOpt) (*nats.ConsumerInfo, error) {
+	c, ok := f.consumers[stream+"/"+name]
+	if !ok {
+		return nil, nats.ErrConsumerNotFound
+	}
+	return &nats.ConsumerInfo{Config: *c}, nil
+}
+
+func (f *fakeStreams) AddConsumer(stream string, c *nats.ConsumerConfig, _ ...nats.JSOpt) (*nats.ConsumerInfo, error) {
+	f.calls = append(f.calls, "add consumer "+c.Durable)
+	f.consumers[stream+"/"+c.Durable] = c
+	return &nats.ConsumerInfo{Config: *c}, nil
+}
+
+func (f *fakeStreams) UpdateConsumer(stream string, c *nats.ConsumerConfig, _ ...nats.JSOpt) (*nats.ConsumerInfo, error) {
+	f.calls = append(f.calls, "update consumer "+c.Durable)
+	f.consumers[stream+"/"+c.Durable] = c
+	return &nats.ConsumerInfo{Config: *c}, nil
+}
+
+func TestReconcile(t *testing.T) {
+	specs := []config.StreamConfig{
+		{Name: "EVENTS", Subjects: []string{"mcp.modelo.>"}, MaxAge: 48 * time.Hour,
+			Consumers: []config.StreamConsumer{{Durable: "orders", AckWait: time.Minute}}},
+		{Name: "AUDIT", Subjects: []string{"mcp.audit.>"}},
+	}
+	newFake := func() *fakeStreams {
+		return &fakeStreams{
+			streams:   map[string]*nats.StreamConfig{"EVENTS": {Name: "EVENTS", Subjects: []string{"mcp.modelo.>"}, MaxAge: 24 * time.Hour}},
+			consumers: map[string]*nats.ConsumerConfig{},
+		}
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		js := newFake()
+		drift, err := NewProvisioner(js, discard, true).Reconcile(specs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(drift) != 3 || drift[0].Action != "update" || drift[1].Consumer != "orders" || drift[2].Stream != "AUDIT" {
+			t.Errorf("drift = %+v", drift)
+		}
+		if len(js.calls) != 0 {
+			t.Errorf("dry run changed the server: %v", js.calls)
+		}
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		js := newFake()
+		if _, err := NewProvisioner(js, discard, false).Reconcile(specs); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"update stream EVENTS", "add consumer orders", "add stream AUDIT"}
+		if !reflect.DeepEqual(js.calls, want) {
+			t.Errorf("calls = %v, want %v", js.calls, want)
+		}
+		if c := js.consumers["EVENTS/orders"]; c.AckWait != time.Minute || c.AckPolicy != nats.AckExplicitPolicy {
+			t.Errorf("consumer = %+v", c)
+		}
+
+		// Reconciled: nothing left to do.
+		js.calls = nil
+		drift, err := NewProvisioner(js, discard, false).Reconcile(specs)
+		if err != nil || len(drift) != 0 || len(js.calls) != 0 {
+			t.Errorf("second pass: drift %+v, calls %v, err %v", drift, js.calls, err)
+		}
+	})
+
+	t.Run("immutable change", func(t *testing.T) {
+		js := newFake()
+		_, err := NewProvisioner(js, discard, false).Reconcile([]config.StreamConfig{{Name: "EVENTS", Storage: "memory"}})
+		if err == nil || !strings.Contains(err.Error(), "recreate the stream") {
+			t.Fatalf("err = %v, want a recreate error", err)
+		}
+		if len(js.calls) != 0 {
+			t.Errorf("calls = %v", js.calls)
+		}
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		js := &failingStreams{fakeStreams: newFake()}
+		if _, err := NewProvisioner(js, discard, false).Reconcile(specs[1:]); !errors.Is(err, errUnavailable) {
+			t.Fatalf("err = %v, want %v", err, errUnavailable)
+		}
+	})
+}
+
+var errUnavailable = errors.New("jetstream not enabled")
+
+type failingStreams struct{ *fakeStreams }
+
+func (f *failingStreams) StreamInfo(string, ...nats.JSOpt) (*nats.StreamInfo, error) {
+	return nil, errUnavailable
+}
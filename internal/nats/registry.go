@@ -0,0 +1,285 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// HandlerFunc processes one JetStream message. The registry settles the
+// message (ack, nak or dead-letter) from the returned error.
+type HandlerFunc func(ctx context.Context, msg *nats.Msg) error
+
+// Middleware wraps a HandlerFunc. Middlewares registered first run outermost.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// TypedHandler is the application-facing handler signature: the registry
+// decodes Req from the message and publishes the returned Reply.
+type TypedHandler[Req, Reply any] func(ctx context.Context, req Req) (Reply, error)
+
+// Registry wires typed handlers to JetStream subscriptions so services only
+// write the business function for each subject.
+type Registry struct {
+	nc        *nats.Conn
+	js        nats.JetStreamContext
+	consumers *ConsumerPolicies
+	retrier   *Retrier
+	logger    *slog.Logger
+	replies   *SchemaValidator
+
+	middlewares []Middleware
+	routes      []*route
+
+	mu      sync.Mutex
+	subs    []*nats.Subscription
+	pullers []*puller
+}
+
+type route struct {
+	subject      string
+	durable      string
+	replySubject string
+	middlewares  []Middleware
+	idempotency  *idempotency
+	handler      HandlerFunc
+}
+
+// validator is implemented by the contract types generated into
+// pkg/contracts.
+type validator interface {
+	Validate() error
+}
+
+// RouteOption customizes a single registered handler.
+type RouteOption func(*route)
+
+// WithDurable sets the durable consumer name for the subject.
+func WithDurable(name string) RouteOption {
+	return func(r *route) { r.durable = name }
+}
+
+// WithReplySubject additionally broadcasts every reply to subject as an
+// event, whether or not the request came with an inbox.
+func WithReplySubject(subject string) RouteOption {
+	return func(r *route) { r.replySubject = subject }
+}
+
+// WithMiddleware adds middlewares that only apply to this subject. They run
+// inside the registry-wide chain.
+func WithMiddleware(mw ...Middleware) RouteOption {
+	return func(r *route) { r.middlewares = append(r.middlewares, mw...) }
+}
+
+func NewRegistry(nc *nats.Conn, js nats.JetStreamContext, consumers *ConsumerPolicies, retrier *Retrier, logger *slog.Logger) *Registry {
+	return &Registry{nc: nc, js: js, consumers: consumers, retrier: retrier, logger: logger}
+}
+
+// Use appends middlewares applied to every handler.
+func (r *Registry) Use(mw ...Middleware) {
+	r.middlewares = append(r.middlewares, mw...)
+}
+
+// ValidateReplies checks every reply against its contract before publishing.
+// It is meant for development, where a mismatch points at a handler bug.
+func (r *Registry) ValidateReplies(v *SchemaValidator) {
+	r.replies = v
+}
+
+// Handle registers a typed handler for subject. It must be called before
+// Start.
+func Handle[Req, Reply any](r *Registry, subject string, h TypedHandler[Req, Reply], opts ...RouteOption) {
+	rt := &route{subject: subject}
+	for _, o := range opts {
+		o(rt)
+	}
+	process := func(ctx context.Context, msg *nats.Msg) ([]byte, error) {
+		var req Req
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return nil, Permanent(fmt.Errorf("decode request: %w", err))
+		}
+		if v, ok := any(req).(validator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, Permanent(fmt.Errorf("invalid request: %w", err))
+			}
+		}
+		reply, err := h(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return r.encodeReply(rt, reply)
+	}
+	rt.handler = func(ctx context.Context, msg *nats.Msg) error {
+		var b []byte
+		var err error
+		if rt.idempotency != nil {
+			b, err = rt.idempotency.run(ctx, msg, func() ([]byte, error) { return process(ctx, msg) })
+		} else {
+			b, err = process(ctx, msg)
+		}
+		if err != nil {
+			return err
+		}
+		return r.publishReply(ctx, rt, msg, b)
+	}
+	r.routes = append(r.routes, rt)
+}
+
+func (r *Registry) encodeReply(rt *route, reply any) ([]byte, error) {
+	b, err := json.Marshal(reply)
+	if err != nil {
+		return nil, Permanent(fmt.Errorf("encode reply: %w", err))
+	}
+	if r.replies != nil {
+		if err := r.replies.ValidateReply(rt.subject, b); err != nil {
+			return nil, Permanent(fmt.Errorf("invalid reply: %w", err))
+		}
+	}
+	return b, nil
+}
+
+// publishReply answers the caller's inbox, if any, and broadcasts the reply
+// when the route has a reply subject. Both carry the correlation ID.
+func (r *Registry) publishReply(ctx context.Context, rt *route, msg *nats.Msg, b []byte) error {
+	if inbox := replyInbox(msg); inbox != "" {
+		out := nats.NewMsg(inbox)
+		out.Header.Set(CorrelationIDHeader, CorrelationIDFromContext(ctx))
+		InjectTrace(ctx, out)
+		out.Data = b
+		if err := r.nc.PublishMsg(out); err != nil {
+			return fmt.Errorf("publish reply: %w", err)
+		}
+	}
+	if rt.replySubject == "" {
+		return nil
+	}
+	out := nats.NewMsg(rt.replySubject)
+	out.Header.Set(CorrelationIDHeader, CorrelationIDFromContext(ctx))
+	out.Data = b
+	_, span := startPublish(ctx, out)
+	defer span.End()
+	if _, err := r.js.PublishMsg(out); err != nil {
+		return fmt.Errorf("broadcast reply: %w", err)
+	}
+	return nil
+}
+
+// replyError tells a waiting caller that its request was rejected, so it
+// does not wait for the timeout. Transient failures are retried and get no
+// answer until they succeed.
+func (r *Registry) replyError(ctx context.Context, msg *nats.Msg, err error) {
+	inbox := replyInbox(msg)
+	if inbox == "" {
+		return
+	}
+	out := nats.NewMsg(inbox)
+	out.Header.Set(CorrelationIDHeader, CorrelationIDFromContext(ctx))
+	out.Header.Set(ErrorHeader, err.Error())
+	InjectTrace(ctx, out)
+	if perr := r.nc.PublishMsg(out); perr != nil {
+		r.logger.Warn("publish error reply", "subject", msg.Subject, "error", perr)
+	}
+}
+
+// Start subscribes every registered handler. Messages are processed with a
+// context derived from ctx.
+func (r *Registry) Start(ctx context.Context) error {
+	for _, rt := range r.routes {
+		h := r.wrap(rt)
+		rt := rt
+
+		handle := func(msg *nats.Msg) {
+			mctx := context.WithValue(ctx, subjectKey{}, rt.subject)
+			mctx = WithCorrelationID(mctx, correlationID(msg))
+			err := h(mctx, msg)
+			if err != nil && IsPermanent(err) {
+				r.replyError(mctx, msg, err)
+			}
+			r.retrier.Settle(msg, err)
+		}
+
+		policy := r.consumers.For(rt.subject)
+		if rt.idempotency != nil {
+			rt.idempotency.lease = rt.idempotency.leaseFor(policy.AckWait)
+		}
+		var (
+			sub *nats.Subscription
+			err error
+		)
+		if policy.Pull {
+			sub, err = r.js.PullSubscribe(rt.subject, rt.durable, policy.subOpts()...)
+		} else {
+			// Without WithDurable the push consumer is ephemeral, as in pull mode.
+			opts := policy.subOpts()
+			if rt.durable != "" {
+				opts = append(opts, nats.Durable(rt.durable))
+			}
+			sub, err = r.js.Subscribe(rt.subject, handle, opts...)
+		}
+		if err != nil {
+			return fmt.Errorf("subscribe %s: %w", rt.subject, err)
+		}
+		r.mu.Lock()
+		r.subs = append(r.subs, sub)
+		if policy.Pull {
+			p := newPuller(sub, policy, handle, r.logger)
+			r.pullers = append(r.pullers, p)
+			go p.run()
+		}
+		r.mu.Unlock()
+		r.logger.Info("handler registered", "subject", rt.subject, "durable", rt.durable,
+			"pull", policy.Pull, "workers", policy.Workers, "batch_size", policy.BatchSize)
+	}
+	return nil
+}
+
+// Stop stops fetching, waits for the messages already handed to workers and
+// then drains every subscription.
+func (r *Registry) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.pullers {
+		p.stop()
+	}
+	r.pullers = nil
+	for _, sub := range r.subs {
+		if err := sub.Drain(); err != nil {
+			r.logger.Error("drain subscription", "subject", sub.Subject, "error", err)
+		}
+	}
+	r.subs = nil
+}
+
+// wrap returns the handler of rt inside its own middlewares, themselves
+// inside the registry-wide ones.
+func (r *Registry) wrap(rt *route) HandlerFunc {
+	return chain(chain(rt.handler, rt.middlewares), r.middlewares)
+}
+
+func chain(h HandlerFunc, mws []Middleware) HandlerFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+type subjectKey struct{}
+
+// correlationID returns the correlation ID sent by the caller, falling back
+// to the message ID so replies to plain publishes can still be matched.
+func correlationID(msg *nats.Msg) string {
+	if id := msg.Header.Get(CorrelationIDHeader); id != "" {
+		return id
+	}
+	return msg.Header.Get(nats.MsgIdHdr)
+}
+
+// SubjectFromContext returns the subject the handler was registered with,
+// which may contain wildcards unlike msg.Subject.
+func SubjectFromContext(ctx context.Context) string {
+	s, _ := ctx.Value(subjectKey{}).(string)
+	return s
+}
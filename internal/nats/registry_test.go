@@ -0,0 +1,202 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+
+	examplev1 "modelo-mcp/pkg/contracts/example/v1"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newTestRegistry returns a registry without a connection: handlers invoked
+// directly only publish when the message has a reply inbox.
+func newTestRegistry() *Registry {
+	return NewRegistry(nil, nil, nil, nil, discard)
+}
+
+type order struct {
+	ID string `json:"id"`
+}
+
+func (o order) Validate() error {
+	if o.ID == "bad" {
+		return errors.New("id: must not be bad")
+	}
+	return nil
+}
+
+func TestHandleRegistersRoutes(t *testing.T) {
+	r := newTestRegistry()
+	mw := func(next HandlerFunc) HandlerFunc { return next }
+	Handle(r, "orders.create", func(context.Context, order) (order, error) { return order{}, nil },
+		WithDurable("orders"), WithReplySubject("orders.created"), WithMiddleware(mw, mw))
+	Handle(r, "orders.*.cancel", func(context.Context, order) (order, error) { return order{}, nil })
+
+	if len(r.routes) != 2 {
+		t.Fatalf("%d routes, want 2", len(r.routes))
+	}
+	first, second := r.routes[0], r.routes[1]
+	if first.subject != "orders.create" || first.durable != "orders" || first.replySubject != "orders.created" || len(first.middlewares) != 2 {
+		t.Errorf("first route = %+v", first)
+	}
+	if second.subject != "orders.*.cancel" || second.durable != "" || second.replySubject != "" || second.middlewares != nil {
+		t.Errorf("second route = %+v", second)
+	}
+	if first.handler == nil || second.handler == nil {
+		t.Error("route without handler")
+	}
+}
+
+func TestHandleDecodeAndValidate(t *testing.T) {
+	handlerErr := errors.New("database down")
+	tests := []struct {
+		name      string
+		data      string
+		handle    func(*Registry, *[]string)
+		wantErr   string
+		permanent bool
+		called    bool
+	}{
+		{
+			name:      "malformed json",
+			data:      `{"id":`,
+			wantErr:   "decode request",
+			permanent: true,
+		},
+		{
+			name:      "wrong type",
+			data:      `{"id":42}`,
+			wantErr:   "decode request",
+			permanent: true,
+		},
+		{
+			name:      "validation failure",
+			data:      `{"id":"bad"}`,
+			wantErr:   "invalid request: id: must not be bad",
+			permanent: true,
+		},
+		{
+			name: "generated contract without required field",
+			data: `{}`,
+			handle: func(r *Registry, calls *[]string) {
+				Handle(r, "example.request", func(_ context.Context, req examplev1.ExampleRequest) (examplev1.ExampleReply, error) {
+					*calls = append(*calls, req.Message)
+					return examplev1.ExampleReply{}, nil
+				})
+			},
+			wantErr:   "message: is required",
+			permanent: true,
+		},
+		{
+			name: "generated contract with empty field",
+			data: `{"message":""}`,
+			handle: func(r *Registry, calls *[]string) {
+				Handle(r, "example.request", func(_ context.Context, req examplev1.ExampleRequest) (examplev1.ExampleReply, error) {
+					*calls = append(*calls, req.Message)
+					return examplev1.ExampleReply{}, nil
+				})
+			},
+			called: true,
+		},
+		{
+			name: "handler failure is retried",
+			data: `{"id":"o1"}`,
+			handle: func(r *Registry, calls *[]string) {
+				Handle(r, "orders.create", func(_ context.Context, req order) (order, error) {
+					*calls = append(*calls, req.ID)
+					return order{}, handlerErr
+				})
+			},
+			wantErr: "database down",
+			called:  true,
+		},
+		{
+			name: "unencodable reply",
+			data: `{"id":"o1"}`,
+			handle: func(r *Registry, calls *[]string) {
+				Handle(r, "orders.create", func(_ context.Context, req order) (chan int, error) {
+					*calls = append(*calls, req.ID)
+					return make(chan int), nil
+				})
+			},
+			wantErr:   "encode reply",
+			permanent: true,
+			called:    true,
+		},
+		{
+			name:   "success",
+			data:   `{"id":"o1"}`,
+			called: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry()
+			var calls []string
+			if tt.handle != nil {
+				tt.handle(r, &calls)
+			} else {
+				Handle(r, "orders.create", func(_ context.Context, req order) (order, error) {
+					calls = append(calls, req.ID)
+					return req, nil
+				})
+			}
+			msg := nats.NewMsg("orders.create")
+			msg.Data = []byte(tt.data)
+
+			err := r.routes[0].handler(context.Background(), msg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+			if IsPermanent(err) != tt.permanent {
+				t.Errorf("permanent = %v, want %v", IsPermanent(err), tt.permanent)
+			}
+			if (len(calls) > 0) != tt.called {
+				t.Errorf("handler calls = %v, want called %v", calls, tt.called)
+			}
+		})
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var trace []string
+	mw := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, msg *nats.Msg) error {
+				trace = append(trace, name+">")
+				err := next(ctx, msg)
+				trace = append(trace, "<"+name)
+				return err
+			}
+		}
+	}
+
+	r := newTestRegistry()
+	r.Use(mw("global1"), mw("global2"))
+	Handle(r, "orders.create", func(context.Context, order) (order, error) {
+		trace = append(trace, "handler")
+		return order{}, nil
+	}, WithMiddleware(mw("route1"), mw("route2")))
+	r.Use(mw("global3"))
+
+	msg := nats.NewMsg("orders.create")
+	msg.Data = []byte(`{"id":"o1"}`)
+	if err := r.wrap(r.routes[0])(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	want := "global1> global2> global3> route1> route2> handler <route2 <route1 <global3 <global2 <global1"
+	if got := strings.Join(trace, " "); got != want {
+		t.Errorf("order:\n got %s\nwant %s", got, want)
+	}
+}
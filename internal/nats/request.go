@@ -0,0 +1,135 @@
+package nats
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Request/reply headers. Requests travel through JetStream, where msg.Reply
+// is the ack subject of the consumer, so the caller's inbox is carried in a
+// header instead.
+const (
+	ReplyToHeader       = "Mcp-Reply-To"
+	CorrelationIDHeader = "Mcp-Correlation-Id"
+	ErrorHeader         = "Mcp-Error"
+)
+
+// RemoteError is returned by Request when the handler rejected the request.
+type RemoteError struct {
+	Subject string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Subject, e.Message)
+}
+
+// replyInbox returns where the reply to msg must go, or "" for a
+// fire-and-forget message. A core NATS msg.Reply is honoured as well; the
+// JetStream ack subject is not an inbox.
+func replyInbox(msg *nats.Msg) string {
+	if to := msg.Header.Get(ReplyToHeader); to != "" {
+		return to
+	}
+	if msg.Reply != "" && !strings.HasPrefix(msg.Reply, "$JS.ACK.") {
+		return msg.Reply
+	}
+	return ""
+}
+
+type correlationKey struct{}
+
+// WithCorrelationID returns a context whose outgoing requests carry id.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID of the message being
+// handled, or of the request chain set with WithCorrelationID.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Client sends requests to handlers registered on a Registry, in this or
+// another MCP.
+type Client struct {
+	nc      *nats.Conn
+	js      nats.JetStreamContext
+	timeout time.Duration
+}
+
+// NewClient returns a Client that gives up after timeout unless the context
+// passed to Request has an earlier deadline.
+func NewClient(nc *nats.Conn, js nats.JetStreamContext, timeout time.Duration) *Client {
+	return &Client{nc: nc, js: js, timeout: timeout}
+}
+
+// Request publishes req on subject and waits for the handler's reply. The
+// request is stored in the stream, so it is processed even if the handler is
+// briefly unavailable; the caller only waits until the deadline.
+func Request[Req, Reply any](ctx context.Context, c *Client, subject string, req Req) (Reply, error) {
+	var reply Reply
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return reply, fmt.Errorf("encode request: %w", err)
+	}
+
+	inbox := c.nc.NewRespInbox()
+	sub, err := c.nc.SubscribeSync(inbox)
+	if err != nil {
+		return reply, fmt.Errorf("subscribe inbox: %w", err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	id := newID()
+	correlationID := CorrelationIDFromContext(ctx)
+	if correlationID == "" {
+		correlationID = id
+	}
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	msg.Header.Set(nats.MsgIdHdr, id)
+	msg.Header.Set(ReplyToHeader, inbox)
+	msg.Header.Set(CorrelationIDHeader, correlationID)
+	ctx, span := startPublish(ctx, msg)
+	defer span.End()
+	if _, err := c.js.PublishMsg(msg, nats.Context(ctx)); err != nil {
+		return reply, fmt.Errorf("publish request %s: %w", subject, err)
+	}
+
+	resp, err := sub.NextMsgWithContext(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return reply, fmt.Errorf("request %s: %w", subject, nats.ErrTimeout)
+		}
+		return reply, fmt.Errorf("request %s: %w", subject, err)
+	}
+	if msg := resp.Header.Get(ErrorHeader); msg != "" {
+		return reply, &RemoteError{Subject: subject, Message: msg}
+	}
+	if err := json.Unmarshal(resp.Data, &reply); err != nil {
+		return reply, fmt.Errorf("decode reply: %w", err)
+	}
+	return reply, nil
+}
@@ -0,0 +1,136 @@
+package nats
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"modelo-mcp/internal/config"
+)
+
+// RetryPolicy decides how often a failed message is redelivered and how long
+// JetStream waits between attempts.
+type RetryPolicy struct {
+	MaxDeliver     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter is the fraction by which a delay may randomly grow or shrink.
+	Jitter float64
+}
+
+// Backoff returns the delay before redelivering a message that has already
+// been delivered n times. Jitter is applied before the MaxBackoff cap.
+func (p RetryPolicy) Backoff(n int) time.Duration {
+	if n < 1 {
+		n = 1
+	}
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(n-1))
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
+// Exhausted reports whether a message delivered n times must not be retried.
+func (p RetryPolicy) Exhausted(n int) bool {
+	return p.MaxDeliver > 0 && n >= p.MaxDeliver
+}
+
+// RetryPolicies resolves the policy for a subject from the configured
+// per-subject overrides, falling back to the default.
+type RetryPolicies struct {
+	def       RetryPolicy
+	overrides []subjectPolicy
+}
+
+type subjectPolicy struct {
+	subject string
+	policy  RetryPolicy
+}
+
+func NewRetryPolicies(cfg config.RetryConfig) *RetryPolicies {
+	def := RetryPolicy{
+		MaxDeliver:     cfg.MaxDeliver,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+		Multiplier:     cfg.Multiplier,
+		Jitter:         cfg.Jitter,
+	}
+	if def.Multiplier < 1 {
+		def.Multiplier = 1
+	}
+	rp := &RetryPolicies{def: def}
+	for _, o := range cfg.Subjects {
+		p := def
+		if o.MaxDeliver != 0 {
+			p.MaxDeliver = o.MaxDeliver
+		}
+		if o.InitialBackoff != 0 {
+			p.InitialBackoff = o.InitialBackoff
+		}
+		if o.MaxBackoff != 0 {
+			p.MaxBackoff = o.MaxBackoff
+		}
+		if o.Multiplier >= 1 {
+			p.Multiplier = o.Multiplier
+		}
+		rp.overrides = append(rp.overrides, subjectPolicy{subject: o.Subject, policy: p})
+	}
+	return rp
+}
+
+// For returns the first override matching subject, or the default policy.
+func (rp *RetryPolicies) For(subject string) RetryPolicy {
+	for _, o := range rp.overrides {
+		if SubjectMatches(o.subject, subject) {
+			return o.policy
+		}
+	}
+	return rp.def
+}
+
+// SubjectMatches reports whether subject matches pattern using NATS wildcard
+// rules ("*" matches one token, ">" matches one or more trailing tokens).
+func SubjectMatches(pattern, subject string) bool {
+	pt := strings.Split(pattern, ".")
+	st := strings.Split(subject, ".")
+	for i, p := range pt {
+		if p == ">" {
+			return len(st) > i
+		}
+		if i >= len(st) {
+			return false
+		}
+		if p != "*" && p != st[i] {
+			return false
+		}
+	}
+	return len(pt) == len(st)
+}
+
+// permanentError marks a failure that retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the message is sent to the DLQ without further
+// redelivery (e.g. a payload that cannot be decoded).
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent.
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
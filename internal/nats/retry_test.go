@@ -0,0 +1,134 @@
+package nats
+
+import (
+	"testing"
+	"time"
+
+	"modelo-mcp/internal/config"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Multiplier: 2}
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		n      int
+		want   time.Duration
+	}{
+		{name: "first delivery", policy: p, n: 1, want: time.Second},
+		{name: "no delivery yet", policy: p, n: 0, want: time.Second},
+		{name: "doubles", policy: p, n: 3, want: 4 * time.Second},
+		{name: "capped", policy: p, n: 5, want: 10 * time.Second},
+		{name: "capped far out", policy: p, n: 200, want: 10 * time.Second},
+		{name: "no cap", policy: RetryPolicy{InitialBackoff: time.Second, Multiplier: 2}, n: 6, want: 32 * time.Second},
+		{name: "constant", policy: RetryPolicy{InitialBackoff: time.Second, Multiplier: 1}, n: 4, want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.n); got != tt.want {
+				t.Errorf("Backoff(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Multiplier: 2, Jitter: 0.25}
+	tests := []struct {
+		n        int
+		min, max time.Duration
+	}{
+		{n: 1, min: 750 * time.Millisecond, max: 1250 * time.Millisecond},
+		{n: 3, min: 3 * time.Second, max: 5 * time.Second},
+		// 8s ± 2s: the upper half is capped.
+		{n: 4, min: 6 * time.Second, max: 10 * time.Second},
+		{n: 10, min: 10 * time.Second, max: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 500; i++ {
+			d := p.Backoff(tt.n)
+			if d < tt.min || d > tt.max {
+				t.Fatalf("Backoff(%d) = %v, want within [%v, %v]", tt.n, d, tt.min, tt.max)
+			}
+			seen[d] = true
+		}
+		if tt.min != tt.max && len(seen) < 2 {
+			t.Errorf("Backoff(%d) is not jittered", tt.n)
+		}
+	}
+}
+
+func TestRetryPolicyExhausted(t *testing.T) {
+	p := RetryPolicy{MaxDeliver: 3}
+	for n, want := range map[int]bool{1: false, 2: false, 3: true, 4: true} {
+		if got := p.Exhausted(n); got != want {
+			t.Errorf("Exhausted(%d) = %v, want %v", n, got, want)
+		}
+	}
+	if (RetryPolicy{}).Exhausted(100) {
+		t.Error("MaxDeliver 0 must retry forever")
+	}
+}
+
+func TestSubjectMatches(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"orders.create", "orders.create", true},
+		{"orders.create", "orders.cancel", false},
+		{"orders.create", "orders.create.v2", false},
+		{"orders.create.v2", "orders.create", false},
+		{"orders.*", "orders.create", true},
+		{"orders.*", "orders", false},
+		{"orders.*", "orders.create.v2", false},
+		{"*.create", "orders.create", true},
+		{"orders.*.v2", "orders.create.v2", true},
+		{"orders.*.v2", "orders.create.v3", false},
+		{"orders.>", "orders.create", true},
+		{"orders.>", "orders.create.v2", true},
+		{"orders.>", "orders", false},
+		{">", "orders", true},
+		{"*.>", "orders.create", true},
+		{"*.>", "orders", false},
+		{"*", "orders", true},
+		{"*", "orders.create", false},
+	}
+	for _, tt := range tests {
+		if got := SubjectMatches(tt.pattern, tt.subject); got != tt.want {
+			t.Errorf("SubjectMatches(%q, %q) = %v, want %v", tt.pattern, tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPoliciesFor(t *testing.T) {
+	rp := NewRetryPolicies(config.RetryConfig{
+		MaxDeliver:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Multiplier:     0.5,
+		Jitter:         0.1,
+		Subjects: []config.SubjectRetryConfig{
+			{Subject: "payments.>", MaxDeliver: 20, InitialBackoff: 5 * time.Second},
+			{Subject: "payments.refund", MaxDeliver: 2},
+			{Subject: "*.audit", Multiplier: 3},
+		},
+	})
+
+	tests := []struct {
+		subject string
+		want    RetryPolicy
+	}{
+		{"orders.create", RetryPolicy{MaxDeliver: 5, InitialBackoff: time.Second, MaxBackoff: time.Minute, Multiplier: 1, Jitter: 0.1}},
+		{"payments.charge", RetryPolicy{MaxDeliver: 20, InitialBackoff: 5 * time.Second, MaxBackoff: time.Minute, Multiplier: 1, Jitter: 0.1}},
+		// The first matching override wins.
+		{"payments.refund", RetryPolicy{MaxDeliver: 20, InitialBackoff: 5 * time.Second, MaxBackoff: time.Minute, Multiplier: 1, Jitter: 0.1}},
+		{"orders.audit", RetryPolicy{MaxDeliver: 5, InitialBackoff: time.Second, MaxBackoff: time.Minute, Multiplier: 3, Jitter: 0.1}},
+	}
+	for _, tt := range tests {
+		if got := rp.For(tt.subject); got != tt.want {
+			t.Errorf("For(%q) = %+v, want %+v", tt.subject, got, tt.want)
+		}
+	}
+}
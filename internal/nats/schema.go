@@ -0,0 +1,169 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"modelo-mcp/pkg/contracts"
+)
+
+// SchemaValidator checks payloads against the contracts embedded from
+// pkg/contracts. A subject is matched to its schemas through an explicit Bind
+// or, by convention, through its last two tokens: mcp.modelo.example.request
+// uses example.request for requests and example.reply for replies.
+type SchemaValidator struct {
+	schemas  map[string]*jsonschema.Schema
+	bindings []schemaBinding
+}
+
+type schemaBinding struct {
+	subject string
+	request string
+	reply   string
+}
+
+// ContractError lists every problem found when a payload does not match its
+// schema.
+type ContractError struct {
+	Schema   string
+	Problems []string
+}
+
+func (e *ContractError) Error() string {
+	return fmt.Sprintf("payload does not match schema %s: %s", e.Schema, strings.Join(e.Problems, "; "))
+}
+
+// NewSchemaValidator compiles the latest version of every embedded contract
+// schema.
+func NewSchemaValidator() (*SchemaValidator, error) {
+	latest, err := contracts.Latest()
+	if err != nil {
+		return nil, err
+	}
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	c.AssertFormat = true
+
+	v := &SchemaValidator{schemas: make(map[string]*jsonschema.Schema, len(latest))}
+	for _, ct := range latest {
+		raw, err := contracts.FS.ReadFile(ct.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.AddResource(ct.Path, bytes.NewReader(raw)); err != nil {
+			return nil, fmt.Errorf("load schema %s: %w", ct.Path, err)
+		}
+		s, err := c.Compile(ct.Path)
+		if err != nil {
+			return nil, fmt.Errorf("compile schema %s: %w", ct.Path, err)
+		}
+		v.schemas[ct.Name] = s
+	}
+	return v, nil
+}
+
+// Bind maps subject (wildcards allowed) to explicit request and reply schema
+// names. Either may be empty to skip validation in that direction.
+func (v *SchemaValidator) Bind(subject, request, reply string) error {
+	for _, name := range []string{request, reply} {
+		if _, ok := v.schemas[name]; name != "" && !ok {
+			return fmt.Errorf("unknown schema %q", name)
+		}
+	}
+	v.bindings = append(v.bindings, schemaBinding{subject: subject, request: request, reply: reply})
+	return nil
+}
+
+func (v *SchemaValidator) lookup(subject string) (request, reply string) {
+	for _, b := range v.bindings {
+		if SubjectMatches(b.subject, subject) {
+			return b.request, b.reply
+		}
+	}
+	tokens := strings.Split(subject, ".")
+	if len(tokens) < 2 {
+		return "", ""
+	}
+	base := tokens[len(tokens)-2]
+	if _, ok := v.schemas[base+"."+tokens[len(tokens)-1]]; ok {
+		request = base + "." + tokens[len(tokens)-1]
+	}
+	if _, ok := v.schemas[base+".reply"]; ok {
+		reply = base + ".reply"
+	}
+	return request, reply
+}
+
+// ValidateRequest validates an inbound payload for subject. Subjects without
+// a schema pass through.
+func (v *SchemaValidator) ValidateRequest(subject string, data []byte) error {
+	name, _ := v.lookup(subject)
+	return v.validate(name, data)
+}
+
+// ValidateReply validates a reply produced by the handler of subject.
+func (v *SchemaValidator) ValidateReply(subject string, data []byte) error {
+	_, name := v.lookup(subject)
+	return v.validate(name, data)
+}
+
+func (v *SchemaValidator) validate(name string, data []byte) error {
+	s, ok := v.schemas[name]
+	if !ok {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return &ContractError{Schema: name, Problems: []string{"invalid JSON: " + err.Error()}}
+	}
+	err := s.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+	ce := &ContractError{Schema: name}
+	collectProblems(ve, &ce.Problems)
+	return ce
+}
+
+func collectProblems(ve *jsonschema.ValidationError, out *[]string) {
+	if len(ve.Causes) == 0 {
+		loc := ve.InstanceLocation
+		if loc == "" {
+			loc = "/"
+		}
+		*out = append(*out, loc+": "+ve.Message)
+		return
+	}
+	for _, c := range ve.Causes {
+		collectProblems(c, out)
+	}
+}
+
+// ValidateRequests rejects messages that do not match their request schema.
+// They are dead-lettered immediately with the validation problems as reason.
+func ValidateRequests(v *SchemaValidator) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			subject := SubjectFromContext(ctx)
+			if subject == "" {
+				subject = msg.Subject
+			}
+			if err := v.ValidateRequest(subject, msg.Data); err != nil {
+				return Permanent(err)
+			}
+			return next(ctx, msg)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package nats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestValidateRequests(t *testing.T) {
+	v, err := NewSchemaValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		data      string
+		permanent bool
+	}{
+		{data: `{"message":"hi"}`},
+		{data: `{"message":""}`},
+		{data: `{}`, permanent: true},
+		{data: `{"message":"hi","extra":1}`, permanent: true},
+		{data: `not json`, permanent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			called := false
+			h := ValidateRequests(v)(func(context.Context, *nats.Msg) error {
+				called = true
+				return nil
+			})
+			msg := nats.NewMsg("mcp.modelo.example.request")
+			msg.Data = []byte(tt.data)
+			err := h(context.Background(), msg)
+			if IsPermanent(err) != tt.permanent || (err != nil && !tt.permanent) {
+				t.Fatalf("err = %v, want permanent %v", err, tt.permanent)
+			}
+			if called == tt.permanent {
+				t.Errorf("handler called = %v", called)
+			}
+		})
+	}
+}
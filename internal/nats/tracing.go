@@ -0,0 +1,73 @@
+package nats
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier adapts nats.Header to the OpenTelemetry propagators, so the
+// W3C traceparent and baggage travel with the message.
+type headerCarrier nats.Header
+
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+func (c headerCarrier) Get(key string) string { return nats.Header(c).Get(key) }
+func (c headerCarrier) Set(key, value string) { nats.Header(c).Set(key, value) }
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectTrace writes the trace context of ctx into the headers of msg.
+func InjectTrace(ctx context.Context, msg *nats.Msg) {
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(msg.Header))
+}
+
+// ExtractTrace returns ctx carrying the trace context found in msg, if any.
+func ExtractTrace(ctx context.Context, msg *nats.Msg) context.Context {
+	if msg.Header == nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier(msg.Header))
+}
+
+// startPublish starts a producer span for a message about to be published
+// and injects it into the message headers.
+func startPublish(ctx context.Context, msg *nats.Msg) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, msg.Subject+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", msg.Subject),
+		),
+	)
+	InjectTrace(ctx, msg)
+	return ctx, span
+}
+
+// deliveryAttributes describes a JetStream delivery; plain NATS messages have
+// no metadata and get none.
+func deliveryAttributes(msg *nats.Msg) []attribute.KeyValue {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return nil
+	}
+	return []attribute.KeyValue{
+		attribute.String("messaging.nats.stream", meta.Stream),
+		attribute.String("messaging.nats.consumer", meta.Consumer),
+		attribute.Int64("messaging.nats.stream_sequence", int64(meta.Sequence.Stream)),
+		attribute.Int64("messaging.nats.delivery_count", int64(meta.NumDelivered)),
+	}
+}
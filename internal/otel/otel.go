@@ -7,6 +7,7 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
@@ -15,11 +16,17 @@ import (
 )
 
 func SetupOTEL(ctx context.Context, cfg *config.Config) (*sdktrace.TracerProvider, *sdktrace.TracerProvider, error) {
-	if cfg.OTELExporterEndpoint == "" {
+	// Propagate W3C trace context and baggage over HTTP and NATS headers even
+	// when this service exports nothing, so traces stay connected.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if cfg.OTEL.ExporterEndpoint == "" {
 		return nil, nil, nil
 	}
 	exp, err := otlptrace.New(ctx, otlptracehttp.NewClient(
-		otlptracehttp.WithEndpoint(cfg.OTELExporterEndpoint),
+		otlptracehttp.WithEndpoint(cfg.OTEL.ExporterEndpoint),
 		otlptracehttp.WithInsecure(),
 	))
 	if err != nil {
@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"modelo-mcp/internal/auth"
+)
+
+// AdminOnly requires a bearer JWT accepted by verifier whose role claim is
+// admin or super_admin, like the gin AuthMiddleware + AdminMiddleware pair.
+func AdminOnly(verifier *auth.Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || tokenString == "" {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Bearer token required"})
+				return
+			}
+			claims, err := verifier.Verify(r.Context(), tokenString)
+			if err != nil {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+				return
+			}
+			if role := claims["role"]; role != "admin" && role != "super_admin" {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "Admin access required"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
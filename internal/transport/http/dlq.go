@@ -0,0 +1,83 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nats-io/nats.go"
+
+	natsx "modelo-mcp/internal/nats"
+)
+
+// DLQRoutes exposes the dead-letter stream for inspection and replay:
+//
+//	GET  /?from=<seq>&limit=<n>   list dead letters
+//	GET  /{seq}                   show one dead letter
+//	POST /{seq}/republish         move it back onto its original subject
+func DLQRoutes(dlq *natsx.DLQ, logger *slog.Logger) http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		from, _ := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 || limit > 500 {
+			limit = 50
+		}
+		items, err := dlq.List(from, limit)
+		if err != nil {
+			logger.Error("dlq list failed", "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"stream": dlq.Stream(), "messages": items})
+	})
+
+	r.Get("/{seq}", func(w http.ResponseWriter, r *http.Request) {
+		seq, err := strconv.ParseUint(chi.URLParam(r, "seq"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid sequence"})
+			return
+		}
+		dl, err := dlq.Get(seq)
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "message not found"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, dl)
+	})
+
+	r.Post("/{seq}/republish", func(w http.ResponseWriter, r *http.Request) {
+		seq, err := strconv.ParseUint(chi.URLParam(r, "seq"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid sequence"})
+			return
+		}
+		err = dlq.Republish(seq)
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "message not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("dlq republish failed", "seq", seq, "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]any{"republished": seq})
+	})
+
+	return r
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
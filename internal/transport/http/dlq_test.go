@@ -0,0 +1,69 @@
+package http
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+
+	"modelo-mcp/internal/auth"
+	"modelo-mcp/internal/config"
+	natsx "modelo-mcp/internal/nats"
+)
+
+const testSecret = "test-secret-of-at-least-32-bytes!"
+
+func TestDLQRoutesRequireAdmin(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	verifier, err := auth.NewVerifier(config.JWTConfig{Secret: testSecret}, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mounted as in cmd/modelo-mcp. The DLQ has no stream: requests that
+	// get past the auth fail on the sequence before touching it.
+	r := chi.NewRouter()
+	r.With(AdminOnly(verifier)).Mount("/admin/dlq", DLQRoutes(natsx.NewDLQ(nil, "EVENTS", logger), logger))
+
+	token := func(role string) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "u1", "role": role, "exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(testSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + signed
+	}
+
+	tests := []struct {
+		name          string
+		method, path  string
+		authorization string
+		want          int
+	}{
+		{name: "list without token", method: http.MethodGet, path: "/admin/dlq/", want: http.StatusUnauthorized},
+		{name: "get without token", method: http.MethodGet, path: "/admin/dlq/1", want: http.StatusUnauthorized},
+		{name: "republish without token", method: http.MethodPost, path: "/admin/dlq/1/republish", want: http.StatusUnauthorized},
+		{name: "invalid token", method: http.MethodGet, path: "/admin/dlq/1", authorization: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "user token", method: http.MethodPost, path: "/admin/dlq/1/republish", authorization: token("user"), want: http.StatusForbidden},
+		{name: "admin token", method: http.MethodGet, path: "/admin/dlq/x", authorization: token("admin"), want: http.StatusBadRequest},
+		{name: "super_admin token", method: http.MethodPost, path: "/admin/dlq/x/republish", authorization: token("super_admin"), want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
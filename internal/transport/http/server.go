@@ -1,37 +1,46 @@
 package http
 
 import (
+	"log/slog"
 	"net/http"
-	"runtime"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"modelo-mcp/internal/config"
-	"modelo-mcp/internal/log"
+	"modelo-mcp/internal/health"
+	"modelo-mcp/internal/metrics"
 	"modelo-mcp/internal/version"
 )
 
-func Router(cfg *config.Config, logger *slog.Logger, promRegistry *prometheus.Registry) http.Handler {
+// Router builds the infra routes. It returns the chi router so the service
+// can mount additional subtrees (e.g. /admin/dlq) before serving.
+func Router(cfg *config.Config, logger *slog.Logger, promRegistry *prometheus.Registry, checks *health.Registry) chi.Router {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 
+	// Liveness only: the process is up and serving HTTP.
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	// Readiness: 503 until every critical dependency check passes.
 	r.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ready"))
+		report := checks.Check(r.Context())
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
 	})
 	r.Get("/info", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		_, _ = w.Write([]byte("{\"service\": \"" + cfg.ServiceName + "\", \"version\": \"" + version.Version + "\", \"commit\": \"" + version.Commit + "\", \"buildTime\": \"" + version.BuildTime + "\"}"))
 	})
-	r.Handle("/metrics", promhttp.HandlerFor(promRegistry, promhttp.HandlerOpts{}))
+	r.Handle("/metrics", metrics.Handler(promRegistry))
 
 	// Optional pprof (behind flag)
 	if cfg.EnablePprof {
@@ -40,3 +49,15 @@ func Router(cfg *config.Config, logger *slog.Logger, promRegistry *prometheus.Re
 
 	return r
 }
+
+// ConfigHandler serves the effective configuration returned by current, with
+// the source of every key and secrets redacted. Mount it behind AdminOnly.
+func ConfigHandler(current func() *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := current()
+		writeJSON(w, http.StatusOK, map[string]any{
+			"environment": cfg.Environment,
+			"settings":    cfg.Effective(),
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"modelo-mcp/internal/config"
+	"modelo-mcp/internal/health"
+)
+
+func TestProbes(t *testing.T) {
+	var (
+		gate       health.Gate
+		postgresUp atomic.Bool
+		hang       = make(chan struct{})
+		redisHangs atomic.Bool
+	)
+	defer close(hang)
+	postgresUp.Store(true)
+
+	checks := health.NewRegistry(nil)
+	checks.Register("handlers", gate.Check)
+	checks.Register("postgres", func(context.Context) error {
+		if !postgresUp.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	checks.Register("redis", func(context.Context) error {
+		if redisHangs.Load() {
+			<-hang
+		}
+		return nil
+	}, health.WithTimeout(50*time.Millisecond))
+	checks.Register("clickhouse", func(context.Context) error { return errors.New("no route to host") }, health.NonCritical())
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := httptest.NewServer(Router(&config.Config{ServiceName: "modelo-mcp"}, logger, prometheus.NewRegistry(), checks))
+	defer srv.Close()
+
+	get := func(path string) (int, []byte) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, body
+	}
+	ready := func() (int, health.Report) {
+		t.Helper()
+		code, body := get("/readyz")
+		var report health.Report
+		if err := json.Unmarshal(body, &report); err != nil {
+			t.Fatalf("readyz body %s: %v", body, err)
+		}
+		return code, report
+	}
+	live := func() {
+		t.Helper()
+		if code, body := get("/healthz"); code != http.StatusOK || string(body) != "ok" {
+			t.Errorf("healthz = %d %s, want 200 ok whatever the dependencies", code, body)
+		}
+	}
+
+	// Warming up: not ready, but alive.
+	code, report := ready()
+	if code != http.StatusServiceUnavailable || report.Status != health.StatusNotReady || report.Checks["handlers"].Error != "warming up" {
+		t.Fatalf("before warm-up: %d %+v", code, report)
+	}
+	live()
+
+	// Warm: an optional check failing only degrades readiness.
+	gate.Open()
+	code, report = ready()
+	if code != http.StatusOK || report.Status != health.StatusDegraded {
+		t.Fatalf("after warm-up: %d %+v", code, report)
+	}
+	if ch := report.Checks["clickhouse"]; ch.Up || ch.Critical || ch.Error != "no route to host" {
+		t.Errorf("clickhouse = %+v", ch)
+	}
+	if pg := report.Checks["postgres"]; !pg.Up || !pg.Critical {
+		t.Errorf("postgres = %+v", pg)
+	}
+
+	// A required check failing makes the service unready.
+	postgresUp.Store(false)
+	code, report = ready()
+	if code != http.StatusServiceUnavailable || report.Checks["postgres"].Error != "connection refused" {
+		t.Fatalf("postgres down: %d %+v", code, report)
+	}
+	live()
+	postgresUp.Store(true)
+
+	// A hanging required check times out instead of holding the probe.
+	redisHangs.Store(true)
+	start := time.Now()
+	code, report = ready()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("readyz took %v, want the 50ms timeout", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || report.Checks["redis"].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("redis hanging: %d %+v", code, report)
+	}
+	live()
+}
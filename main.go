@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,12 +11,14 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/robfig/cron/v3"
 
+	"{{MCP_MODULE_NAME}}/internal/ai"
+	"{{MCP_MODULE_NAME}}/internal/bootstrap"
 	"{{MCP_MODULE_NAME}}/internal/config"
 	"{{MCP_MODULE_NAME}}/internal/database"
 	"{{MCP_MODULE_NAME}}/internal/handlers"
+	metricsx "{{MCP_MODULE_NAME}}/internal/metrics"
 	"{{MCP_MODULE_NAME}}/internal/middleware"
 	"{{MCP_MODULE_NAME}}/internal/services"
 	"{{MCP_MODULE_NAME}}/pkg/logger"
@@ -24,65 +26,89 @@ import (
 )
 
 func main() {
-	logger.Init()
-	log := logger.GetLogger()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	cfg, err := config.Load()
+	app, err := bootstrap.New(ctx)
 	if err != nil {
-		log.Fatal("Failed to load configuration", "error", err)
+		fmt.Fprintf(os.Stderr, "{{MCP_NAME}}: %v\n", err)
+		os.Exit(1)
 	}
+	cfg := app.Config
+
+	// Request logging for the gin API
+	logger.Init()
+	logger.SetLevel(cfg.LogLevel)
+	log := logger.GetLogger()
 
 	metrics.Init()
 
 	// Initialize PostgreSQL
-	db, err := database.NewConnection(cfg.Database.URL)
+	db, err := database.NewConnection(cfg.Database, app.Logger)
 	if err != nil {
 		log.Fatal("Failed to connect to database", "error", err)
 	}
-	defer db.Close()
+	app.OnShutdown(func(context.Context) error { return database.Close(db) })
+	app.Health.Register("postgres", database.PostgresCheck(db))
+	go database.ReportPoolStats(ctx, db, cfg.Database.StatsInterval, metrics.SetDatabaseConnections)
 
-	if err := database.RunMigrations(db); err != nil {
-		log.Fatal("Failed to run database migrations", "error", err)
+	if cfg.AutoMigrate {
+		if err := database.RunMigrations(ctx, db, cfg.Database, app.Logger); err != nil {
+			log.Fatal("Failed to run database migrations", "error", err)
+		}
+	}
+	if err := database.EnableTenantGuard(ctx, db); err != nil {
+		log.Fatal("Failed to enable tenant guard", "error", err)
 	}
 
 	// Initialize ClickHouse for analytics
-	clickhouseDB, err := database.NewClickHouseConnection(cfg.ClickHouse.URL)
+	clickhouseDB, err := database.NewClickHouseConnection(ctx, cfg.ClickHouse)
 	if err != nil {
 		log.Fatal("Failed to connect to ClickHouse", "error", err)
 	}
-	defer clickhouseDB.Close()
+	app.OnShutdown(func(context.Context) error { return clickhouseDB.Close() })
+	app.Health.Register("clickhouse", database.ClickHouseCheck(clickhouseDB))
+
+	// Analytics rows are buffered and inserted in batches; Close flushes them
+	// before the connection closes.
+	eventsWriter := database.NewBatchWriter(clickhouseDB, "{{CLICKHOUSE_EVENTS_TABLE}}", cfg.ClickHouse.Batch,
+		metricsx.NewClickHouseMetrics(app.Metrics), app.Logger)
+	app.OnShutdown(eventsWriter.Close)
 
 	// Initialize Redis for caching
 	redisClient, err := database.NewRedisClient(cfg.Redis.URL)
 	if err != nil {
 		log.Fatal("Failed to connect to Redis", "error", err)
 	}
-	defer redisClient.Close()
+	app.OnShutdown(func(context.Context) error { return redisClient.Close() })
+	app.Health.Register("redis", database.RedisCheck(redisClient))
 
 	// Initialize AI services for {{MCP_DESCRIPTION}}
-	aiService1, err := services.NewAI{{AI_SERVICE_1}}Service(cfg.AI)
+	// They share aiClient, whose settings follow ai.* live (see below).
+	aiClient := ai.NewClient(cfg.AI, nil)
+	aiService1, err := services.NewAI{{AI_SERVICE_1}}Service(aiClient)
 	if err != nil {
 		log.Fatal("Failed to initialize AI {{AI_SERVICE_1}}", "error", err)
 	}
 
-	aiService2, err := services.NewAI{{AI_SERVICE_2}}Service(cfg.AI)
+	aiService2, err := services.NewAI{{AI_SERVICE_2}}Service(aiClient)
 	if err != nil {
 		log.Fatal("Failed to initialize AI {{AI_SERVICE_2}}", "error", err)
 	}
 
-	aiService3, err := services.NewAI{{AI_SERVICE_3}}Service(cfg.AI)
+	aiService3, err := services.NewAI{{AI_SERVICE_3}}Service(aiClient)
 	if err != nil {
 		log.Fatal("Failed to initialize AI {{AI_SERVICE_3}}", "error", err)
 	}
 
-	aiService4, err := services.NewAI{{AI_SERVICE_4}}Service(cfg.AI)
+	aiService4, err := services.NewAI{{AI_SERVICE_4}}Service(aiClient)
 	if err != nil {
 		log.Fatal("Failed to initialize AI {{AI_SERVICE_4}}", "error", err)
 	}
 
 	// Initialize core services
 	coreService := services.New{{CORE_SERVICE}}Service(db, clickhouseDB, aiService1, cfg)
-	analyticsService := services.New{{ANALYTICS_SERVICE}}Service(clickhouseDB, aiService2, cfg)
+	analyticsService := services.New{{ANALYTICS_SERVICE}}Service(clickhouseDB, eventsWriter, aiService2, cfg)
 	optimizationService := services.New{{OPTIMIZATION_SERVICE}}Service(db, aiService3, cfg)
 	reportingService := services.New{{REPORTING_SERVICE}}Service(clickhouseDB, cfg)
 
@@ -92,8 +118,16 @@ func main() {
 	optimizationHandler := handlers.New{{OPTIMIZATION_SERVICE}}Handler(optimizationService)
 	reportingHandler := handlers.New{{REPORTING_SERVICE}}Handler(reportingService)
 
+	// Settings applied live on config reload
+	rateLimiter := middleware.NewRedisRateLimiter(redisClient, cfg.RateLimit)
+	app.Watcher.Subscribe(func(c *config.Config) {
+		logger.SetLevel(c.LogLevel)
+		rateLimiter.Update(c.RateLimit)
+		aiClient.Reconfigure(c.AI)
+	})
+
 	// Setup Gin router
-	if cfg.Environment != "development" {
+	if !cfg.Debug {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
@@ -102,9 +136,18 @@ func main() {
 	router.Use(logger.GinMiddleware())
 	router.Use(metrics.GinMiddleware())
 
-	// CORS configuration
+	// CORS configuration; origins are read per request so reloads apply.
+	// Credentials are allowed, so "*" is never honoured: it would reflect any
+	// Origin with cookies and Authorization (config validation rejects it).
 	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = cfg.Security.AllowedOrigins
+	corsConfig.AllowOriginFunc = func(origin string) bool {
+		for _, allowed := range app.Watcher.Current().Security.AllowedOrigins {
+			if allowed != "*" && allowed == origin {
+				return true
+			}
+		}
+		return false
+	}
 	corsConfig.AllowCredentials = true
 	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-Tenant-ID"}
 	router.Use(cors.New(corsConfig))
@@ -120,10 +163,13 @@ func main() {
 	})
 
 	// API routes
+	// Denied and banned IPs are rejected before their tokens are verified.
 	api := router.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
+	api.Use(rateLimiter.IPFilter())
+	api.Use(middleware.AuthMiddleware(app.Auth))
 	api.Use(middleware.TenantMiddleware())
-	api.Use(middleware.RateLimitMiddleware(redisClient, cfg.RateLimit))
+	api.Use(rateLimiter.Middleware())
+	api.Use(middleware.TenantDB(db))
 	{
 		// {{CORE_FEATURE}} Management
 		core := api.Group("/{{CORE_ENDPOINT}}")
@@ -172,7 +218,8 @@ func main() {
 
 	// Admin routes
 	admin := router.Group("/admin")
-	admin.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
+	admin.Use(rateLimiter.IPFilter())
+	admin.Use(middleware.AuthMiddleware(app.Auth))
 	admin.Use(middleware.AdminMiddleware())
 	{
 		admin.GET("/dashboard", func(c *gin.Context) {
@@ -191,34 +238,9 @@ func main() {
 		})
 	}
 
-	// Metrics server
-	metricsRouter := gin.New()
-	metricsRouter.GET("/metrics", gin.WrapH(promhttp.Handler()))
-
-	metricsServer := &http.Server{
-		Addr:    ":" + cfg.MetricsPort,
-		Handler: metricsRouter,
-	}
-
-	go func() {
-		log.Info("Starting metrics server", "port", cfg.MetricsPort)
-		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Error("Metrics server failed", "error", err)
-		}
-	}()
-
-	// Main HTTP server
-	httpServer := &http.Server{
-		Addr:    ":" + cfg.HTTPPort,
-		Handler: router,
-	}
-
-	go func() {
-		log.Info("Starting HTTP server", "port", cfg.HTTPPort)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Error("HTTP server failed", "error", err)
-		}
-	}()
+	// The gin API is served behind the bootstrap infra routes (/healthz,
+	// /readyz, /info, /metrics); metrics_port serves /metrics alone.
+	app.Router.Mount("/", router)
 
 	// Background tasks
 	cronScheduler := cron.New(cron.WithSeconds())
@@ -245,25 +267,13 @@ func main() {
 	})
 
 	cronScheduler.Start()
-	defer cronScheduler.Stop()
-
-	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	log.Info("Shutting down servers...")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Error("HTTP server forced to shutdown", "error", err)
-	}
+	app.OnShutdown(func(context.Context) error {
+		<-cronScheduler.Stop().Done()
+		return nil
+	})
 
-	if err := metricsServer.Shutdown(ctx); err != nil {
-		log.Error("Metrics server forced to shutdown", "error", err)
+	// Serve until SIGINT/SIGTERM, then shut down servers and hooks
+	if err := app.Run(ctx); err != nil {
+		os.Exit(1)
 	}
-
-	log.Info("Server shutdown complete")
 }
\ No newline at end of file
@@ -0,0 +1,23 @@
+-- Transactional outbox relayed to NATS (internal/database.OutboxEvent).
+-- IF NOT EXISTS adopts the table created by AutoMigrate in earlier releases.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id           BIGSERIAL PRIMARY KEY,
+    subject      VARCHAR(255) NOT NULL,
+    payload      JSONB NOT NULL,
+    headers      JSONB,
+    created_at   TIMESTAMPTZ NOT NULL,
+    published_at TIMESTAMPTZ,
+    failed_at    TIMESTAMPTZ,
+    attempts     BIGINT NOT NULL DEFAULT 0,
+    last_error   TEXT
+);
+
+-- Set when the relay gives up on an event; missing from adopted tables.
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+CREATE INDEX idx_outbox_events_pending
+    ON outbox_events (created_at) WHERE published_at IS NULL AND failed_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at
+    ON outbox_events (published_at);
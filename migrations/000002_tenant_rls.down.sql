@@ -0,0 +1,18 @@
+-- Disables the tenant_isolation policy on every table of the schema, then
+-- drops the functions the policies use.
+DO $$
+DECLARE
+    tbl regclass;
+BEGIN
+    FOR tbl IN
+        SELECT format('%I.%I', schemaname, tablename)::regclass FROM pg_policies
+        WHERE policyname = 'tenant_isolation' AND schemaname = current_schema()
+    LOOP
+        PERFORM disable_tenant_rls(tbl);
+    END LOOP;
+END
+$$;
+
+DROP FUNCTION IF EXISTS disable_tenant_rls(regclass);
+DROP FUNCTION IF EXISTS enable_tenant_rls(regclass);
+DROP FUNCTION IF EXISTS current_tenant_id();
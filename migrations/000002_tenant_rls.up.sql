@@ -0,0 +1,55 @@
+-- Row-level security for tenant-scoped tables. Every table of the schema that
+-- already has a tenant_id column is made tenant-scoped below; a table created
+-- later is made tenant-scoped in its own migration with:
+--
+--   SELECT enable_tenant_rls('orders');
+--
+-- Its rows are then visible and writable only in a transaction that set
+-- app.tenant_id to their tenant (database.InTenant). RLS does not apply to
+-- superusers and roles with BYPASSRLS: run the service as a plain role.
+
+-- current_tenant_id is NULL outside a tenant transaction, which matches no row.
+CREATE OR REPLACE FUNCTION current_tenant_id() RETURNS text
+    LANGUAGE sql STABLE
+    AS $$ SELECT NULLIF(current_setting('app.tenant_id', true), '') $$;
+
+CREATE OR REPLACE FUNCTION enable_tenant_rls(tbl regclass) RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    EXECUTE format('ALTER TABLE %s ENABLE ROW LEVEL SECURITY', tbl);
+    -- FORCE applies the policy to the table owner, usually the service role.
+    EXECUTE format('ALTER TABLE %s FORCE ROW LEVEL SECURITY', tbl);
+    EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %s', tbl);
+    EXECUTE format('CREATE POLICY tenant_isolation ON %s
+        USING (tenant_id::text = current_tenant_id())
+        WITH CHECK (tenant_id::text = current_tenant_id())', tbl);
+END
+$$;
+
+CREATE OR REPLACE FUNCTION disable_tenant_rls(tbl regclass) RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %s', tbl);
+    EXECUTE format('ALTER TABLE %s NO FORCE ROW LEVEL SECURITY', tbl);
+    EXECUTE format('ALTER TABLE %s DISABLE ROW LEVEL SECURITY', tbl);
+END
+$$;
+
+-- Tables that already have a tenant_id column. EnableTenantGuard refuses to
+-- start while a later one lacks the policy.
+DO $$
+DECLARE
+    tbl regclass;
+BEGIN
+    FOR tbl IN
+        SELECT c.oid::regclass FROM pg_class c
+        JOIN pg_namespace n ON n.oid = c.relnamespace
+        JOIN pg_attribute a ON a.attrelid = c.oid AND a.attname = 'tenant_id' AND NOT a.attisdropped
+        WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p') AND NOT c.relispartition
+    LOOP
+        PERFORM enable_tenant_rls(tbl);
+    END LOOP;
+END
+$$;
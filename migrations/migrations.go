@@ -0,0 +1,12 @@
+// Package migrations embeds the versioned SQL migrations of the service.
+//
+// Every migration is a pair of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql; versions are applied in ascending order. Create
+// new ones with `modelo-mcp migrate create <name>` and never edit a migration
+// once it has been applied anywhere: its checksum is recorded.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
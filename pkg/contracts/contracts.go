@@ -0,0 +1,111 @@
+// Package contracts embeds the JSON schemas that define the messages this
+// service exchanges, so they ship inside the binary. Schemas are versioned
+// as <contract>/v<N>/<kind>.schema.json, e.g. example/v1/request.schema.json
+// is version 1 of the "example.request" contract. The Go types next to each
+// schema are generated from it by cmd/contractgen.
+package contracts
+
+//go:generate go run ../../cmd/contractgen -dir .
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaSuffix is the file suffix of every contract schema.
+const SchemaSuffix = ".schema.json"
+
+//go:embed */v*/*.schema.json
+var FS embed.FS
+
+// Contract identifies one version of a schema inside FS.
+type Contract struct {
+	Name    string // e.g. "example.request"
+	Version int
+	Path    string // path inside FS
+}
+
+// List returns every embedded contract version, ordered by name and version.
+func List() ([]Contract, error) {
+	matches, err := fs.Glob(FS, "*/v*/*"+SchemaSuffix)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Contract, 0, len(matches))
+	for _, m := range matches {
+		c, err := parsePath(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Version < out[j].Version
+	})
+	return out, nil
+}
+
+func parsePath(p string) (Contract, error) {
+	parts := strings.Split(p, "/")
+	if len(parts) != 3 || !strings.HasPrefix(parts[1], "v") {
+		return Contract{}, fmt.Errorf("contract path %q is not <contract>/v<N>/<kind>%s", p, SchemaSuffix)
+	}
+	v, err := strconv.Atoi(strings.TrimPrefix(parts[1], "v"))
+	if err != nil {
+		return Contract{}, fmt.Errorf("contract path %q: invalid version directory", p)
+	}
+	kind := strings.TrimSuffix(path.Base(p), SchemaSuffix)
+	return Contract{Name: parts[0] + "." + kind, Version: v, Path: p}, nil
+}
+
+// Latest returns the newest version of every contract.
+func Latest() ([]Contract, error) {
+	all, err := List()
+	if err != nil {
+		return nil, err
+	}
+	latest := make([]Contract, 0, len(all))
+	for i, c := range all {
+		if i+1 < len(all) && all[i+1].Name == c.Name {
+			continue
+		}
+		latest = append(latest, c)
+	}
+	return latest, nil
+}
+
+// Schema returns the raw schema document of the latest version of name.
+func Schema(name string) ([]byte, error) {
+	latest, err := Latest()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range latest {
+		if c.Name == name {
+			return FS.ReadFile(c.Path)
+		}
+	}
+	return nil, fmt.Errorf("contract %q not found", name)
+}
+
+// SchemaVersion returns the raw schema document of a specific version.
+func SchemaVersion(name string, version int) ([]byte, error) {
+	all, err := List()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range all {
+		if c.Name == name && c.Version == version {
+			return FS.ReadFile(c.Path)
+		}
+	}
+	return nil, fmt.Errorf("contract %q version %d not found", name, version)
+}
@@ -0,0 +1,89 @@
+// Code generated by contractgen. DO NOT EDIT.
+
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ExampleReply is generated from reply.schema.json.
+type ExampleReply struct {
+	Echo    string    `json:"echo"`
+	Service string    `json:"service"`
+	Ts      time.Time `json:"ts"`
+}
+
+// NewExampleReply returns a new ExampleReply with its required fields set.
+func NewExampleReply(echo string, service string, ts time.Time) ExampleReply {
+	return ExampleReply{
+		Echo:    echo,
+		Service: service,
+		Ts:      ts,
+	}
+}
+
+// Validate reports every constraint of the ExampleReply schema that m violates.
+func (m ExampleReply) Validate() error {
+	var errs []error
+	return errors.Join(errs...)
+}
+
+// UnmarshalJSON decodes data into m, rejecting missing required properties.
+func (m *ExampleReply) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var errs []error
+	if v, ok := raw["echo"]; !ok || string(v) == "null" {
+		errs = append(errs, errors.New("echo: is required"))
+	}
+	if v, ok := raw["service"]; !ok || string(v) == "null" {
+		errs = append(errs, errors.New("service: is required"))
+	}
+	if v, ok := raw["ts"]; !ok || string(v) == "null" {
+		errs = append(errs, errors.New("ts: is required"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	type plain ExampleReply
+	return json.Unmarshal(data, (*plain)(m))
+}
+
+// ExampleRequest is generated from request.schema.json.
+type ExampleRequest struct {
+	Message string `json:"message"`
+}
+
+// NewExampleRequest returns a new ExampleRequest with its required fields set.
+func NewExampleRequest(message string) ExampleRequest {
+	return ExampleRequest{
+		Message: message,
+	}
+}
+
+// Validate reports every constraint of the ExampleRequest schema that m violates.
+func (m ExampleRequest) Validate() error {
+	var errs []error
+	return errors.Join(errs...)
+}
+
+// UnmarshalJSON decodes data into m, rejecting missing required properties.
+func (m *ExampleRequest) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var errs []error
+	if v, ok := raw["message"]; !ok || string(v) == "null" {
+		errs = append(errs, errors.New("message: is required"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	type plain ExampleRequest
+	return json.Unmarshal(data, (*plain)(m))
+}
@@ -0,0 +1,4 @@
+// Package v1 holds version 1 of the example request/reply contract
+// (subjects mcp.modelo.example.*). Breaking changes go into a new v2
+// directory; see cmd/contractcompat.
+package v1
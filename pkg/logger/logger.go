@@ -3,6 +3,7 @@ package logger
 import (
 	"context"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -26,8 +27,16 @@ func Init() {
 	})
 
 	// Set log level based on environment
-	level := os.Getenv("LOG_LEVEL")
-	switch level {
+	SetLevel(os.Getenv("LOG_LEVEL"))
+
+	// Output to stdout
+	globalLogger.SetOutput(os.Stdout)
+}
+
+// SetLevel sets the level from "DEBUG", "INFO", "WARN" or "ERROR" (any case);
+// anything else means INFO.
+func SetLevel(level string) {
+	switch strings.ToUpper(level) {
 	case "DEBUG":
 		globalLogger.SetLevel(logrus.DebugLevel)
 	case "INFO":
@@ -39,9 +48,6 @@ func Init() {
 	default:
 		globalLogger.SetLevel(logrus.InfoLevel)
 	}
-
-	// Output to stdout
-	globalLogger.SetOutput(os.Stdout)
 }
 
 // GetLogger returns the global logger instance